@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"Peer-Drop/pkg/client"
+)
+
+// Environment variable holding the admin API token for "peer-drop admin"
+const tokenEnv = "PEERDROP_TOKEN"
+
+// runAdmin implements "peer-drop admin <command>", which moderates a
+// running server through its admin API
+func runAdmin(args []string) {
+	fs := flag.NewFlagSet("admin", flag.ExitOnError)
+	serverURL := fs.String("url", "http://localhost:8080", "Server to manage")
+	token := fs.String("token", os.Getenv(tokenEnv), "Admin API token (default $"+tokenEnv+")")
+	fs.Usage = adminUsage
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		adminUsage()
+		os.Exit(2)
+	}
+
+	c, err := client.New(*serverURL, client.WithToken(*token))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+	switch {
+	case cmd == "stats" && len(rest) == 0:
+		var stats map[string]int
+		if stats, err = c.Stats(ctx); err == nil {
+			keys := make([]string, 0, len(stats))
+			for k := range stats {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, k := range keys {
+				fmt.Fprintf(w, "%s\t%d\n", k, stats[k])
+			}
+			w.Flush()
+		}
+
+	case cmd == "clients" && len(rest) == 0:
+		var clients []client.ClientInfo
+		if clients, err = c.Clients(ctx); err == nil {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tIP\tROOM\tCONNECTED")
+			for _, cl := range clients {
+				room := cl.IPRoom
+				if cl.PublicRoom != "" {
+					room += ", " + cl.PublicRoom
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", cl.ID, cl.Name, cl.IP, room, cl.Connected.Local().Format(time.DateTime))
+			}
+			w.Flush()
+		}
+
+	case cmd == "kick" && len(rest) >= 1:
+		err = c.Kick(ctx, rest[0], strings.Join(rest[1:], " "))
+
+	case cmd == "rooms" && len(rest) == 0:
+		var rooms []client.RoomInfo
+		if rooms, err = c.Rooms(ctx); err == nil {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ROOM\tKIND\tCLIENTS\tLAST ACTIVE")
+			for _, r := range rooms {
+				kind := "ip"
+				if r.Public {
+					kind = "public"
+				}
+				id := r.ID
+				if r.Alias != "" {
+					id += " (" + r.Alias + ")"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", id, kind, len(r.Clients), r.LastActive.Local().Format(time.DateTime))
+			}
+			w.Flush()
+		}
+
+	case cmd == "close-room" && len(rest) == 1:
+		err = c.CloseRoom(ctx, rest[0])
+
+	case cmd == "reload" && len(rest) == 0:
+		var res client.ReloadResult
+		if res, err = c.Reload(ctx); err == nil {
+			fmt.Printf("Applied: %s\n", strings.Join(res.Applied, ", "))
+			if len(res.RestartRequired) > 0 {
+				fmt.Printf("Restart needed for: %s\n", strings.Join(res.RestartRequired, ", "))
+			}
+		}
+
+	case cmd == "restart" && len(rest) == 0:
+		err = c.Restart(ctx)
+
+	default:
+		adminUsage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd, err)
+		os.Exit(1)
+	}
+}
+
+func adminUsage() {
+	fmt.Fprintln(os.Stderr, `usage: peer-drop admin [-url http://host:port] [-token t] <command>
+
+Commands:
+  stats                    Show server counters
+  clients                  List connected clients
+  kick <id> [reason...]    Disconnect a client
+  rooms                    List rooms
+  close-room <code>        Close a public room
+  reload                   Re-read the config file
+  restart                  Restart the server in place`)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"Peer-Drop/internal/config"
+	"Peer-Drop/internal/firewall"
+)
+
+// runFirewall implements "peer-drop firewall <allow|remove|status>"
+func runFirewall(args []string) {
+	fs := flag.NewFlagSet("firewall", flag.ExitOnError)
+	port := fs.Int("port", 0, "Port to open (default: configured port)")
+	public := fs.Bool("public", false, "Also allow connections on public networks")
+
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: peer-drop firewall <allow|remove|status> [-port n] [-public]")
+		os.Exit(2)
+	}
+	action := args[0]
+	fs.Parse(args[1:])
+
+	if !firewall.Supported() {
+		fmt.Fprintln(os.Stderr, firewall.ErrUnsupported)
+		os.Exit(1)
+	}
+
+	if *port == 0 {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+			os.Exit(1)
+		}
+		*port = cfg.Port
+	}
+
+	switch action {
+	case "allow":
+		if err := firewall.Allow(*port, *public); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to add firewall rule: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Inbound rule %q is in place\n", firewall.RuleName(*port))
+
+	case "remove":
+		if err := firewall.Remove(*port); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to remove firewall rule: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed inbound rule %q\n", firewall.RuleName(*port))
+
+	case "status":
+		exists, err := firewall.Status(*port)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to query firewall: %v\n", err)
+			os.Exit(1)
+		}
+		if exists {
+			fmt.Printf("Inbound rule %q: present\n", firewall.RuleName(*port))
+		} else {
+			fmt.Printf("Inbound rule %q: missing\n", firewall.RuleName(*port))
+			fmt.Println("Run 'peer-drop firewall allow' from an administrator prompt to add it.")
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown firewall action %q\n", action)
+		os.Exit(2)
+	}
+}
+
+// warnIfFirewallBlocks prints a hint at startup when the firewall has no
+// inbound rule for port, since other devices then silently fail to connect
+func warnIfFirewallBlocks(port int) {
+	if !firewall.Supported() {
+		return
+	}
+
+	exists, err := firewall.Status(port)
+	if err != nil || exists {
+		return
+	}
+
+	fmt.Printf("\n")
+	fmt.Printf("  ! No firewall rule allows port %d, other devices may not connect.\n", port)
+	fmt.Printf("  ! Run 'peer-drop firewall allow' from an administrator prompt to fix this.\n")
+}
@@ -0,0 +1,48 @@
+// Package api defines the JSON bodies of the HTTP API. The server encodes
+// them and pkg/client decodes them, so the two change together.
+package api
+
+import "Peer-Drop/internal/config"
+
+// Version is the body of GET /api/version
+type Version struct {
+	Version            string   `json:"version"`
+	ProtocolVersion    int      `json:"protocolVersion"`
+	MinProtocolVersion int      `json:"minProtocolVersion"`
+	Capabilities       []string `json:"capabilities"`
+}
+
+// Info is the body of GET /api/info
+type Info struct {
+	Version    string         `json:"version"`
+	Port       int            `json:"port"`
+	TLS        bool           `json:"tls"`
+	Interfaces []NetInterface `json:"interfaces"`
+	URLs       []string       `json:"urls"`
+}
+
+// NetInterface is a network interface the server is reachable on
+type NetInterface struct {
+	Name      string   `json:"name"`
+	Addresses []string `json:"addresses"`
+
+	// Set on the interface of the default route, the one other devices
+	// on the LAN most likely reach this machine through
+	Primary bool `json:"primary,omitempty"`
+}
+
+// Groups is the body of GET /api/groups
+type Groups struct {
+	Groups []config.PeerGroup `json:"groups"`
+}
+
+// GroupMembers is the body of PUT /api/groups/{name}
+type GroupMembers struct {
+	Members []string `json:"members"`
+}
+
+// ReloadResult is the body of POST /api/admin/reload
+type ReloadResult struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
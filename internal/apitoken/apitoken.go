@@ -0,0 +1,46 @@
+// Package apitoken creates and checks the bearer tokens that guard the
+// HTTP API. Only a hash of each token is stored; the token itself is
+// shown once, when it is created.
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// Prefix marks Peer-Drop tokens, so they are recognizable in a
+// password manager or a leaked log
+const Prefix = "pd_"
+
+// Generate returns a new random token and its hash
+func Generate() (token, hash string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	token = Prefix + base64.RawURLEncoding.EncodeToString(b)
+	return token, Hash(token)
+}
+
+// Hash returns the stored form of token. Tokens are random, so a fast
+// hash is enough: there is nothing to guess from it.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Match reports whether token hashes to one of hashes
+func Match(token string, hashes []string) bool {
+	if token == "" {
+		return false
+	}
+	h := []byte(Hash(token))
+	found := false
+	for _, want := range hashes {
+		if subtle.ConstantTimeCompare(h, []byte(want)) == 1 {
+			found = true
+		}
+	}
+	return found
+}
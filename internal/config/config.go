@@ -2,15 +2,169 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
+	"time"
+
+	"Peer-Drop/internal/passhash"
+	"Peer-Drop/internal/paths"
 )
 
+// ICEServer is an external STUN/TURN server advertised to browsers
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// CrashReportConfig enables local crash reports and optional uploads
+type CrashReportConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Secret   string `json:"secret,omitempty"`
+}
+
+// PeerGroup is a named set of devices, identified by device name, that
+// files can be sent to in one go
+type PeerGroup struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+// AccessConfig limits who may open signaling connections. Behind a
+// reverse proxy, list the public origin and the proxy's network here.
+type AccessConfig struct {
+	// Origins allowed besides the server's own, e.g.
+	// "https://drop.example.com"; "*" allows any
+	Origins []string `json:"origins,omitempty"`
+
+	// Source networks in CIDR form, replacing the default of private,
+	// loopback and link-local addresses
+	Subnets []string `json:"subnets,omitempty"`
+}
+
+// APIToken is a bearer token for the HTTP API, managed with
+// "peer-drop token". Only its hash is kept.
+type APIToken struct {
+	Name    string    `json:"name"`
+	Hash    string    `json:"hash"` // hex SHA-256 of the token
+	Created time.Time `json:"created"`
+
+	// Admin tokens may also use the admin API and edit peer groups;
+	// others only reach what the web UI needs
+	Admin bool `json:"admin,omitempty"`
+}
+
+// RoomJoinLimitsConfig throttles failed attempts to join public rooms.
+// Zero values keep the defaults.
+type RoomJoinLimitsConfig struct {
+	ClientFailures int `json:"client_failures,omitempty"`
+	IPFailures     int `json:"ip_failures,omitempty"`
+	WindowSeconds  int `json:"window_seconds,omitempty"`
+	LockoutSeconds int `json:"lockout_seconds,omitempty"`
+}
+
+// RateLimitsConfig caps what one source IP can do. Zero values keep the
+// defaults; -1 removes a limit.
+type RateLimitsConfig struct {
+	ConnectionsPerIP          int `json:"connections_per_ip,omitempty"`
+	TransferRequestsPerMinute int `json:"transfer_requests_per_minute,omitempty"`
+	APIRequestsPerMinute      int `json:"api_requests_per_minute,omitempty"`
+}
+
+// RoomsConfig shapes public room codes and custom aliases. Zero values
+// keep the defaults.
+type RoomsConfig struct {
+	CodeLength      int    `json:"code_length,omitempty"`
+	CodeCharset     string `json:"code_charset,omitempty"`
+	AliasTTLMinutes int    `json:"alias_ttl_minutes,omitempty"`
+
+	// Limits for every public room; creators may only tighten them
+	MaxPeers        int `json:"max_peers,omitempty"`
+	LifetimeMinutes int `json:"lifetime_minutes,omitempty"`
+	IdleMinutes     int `json:"idle_minutes,omitempty"`
+
+	// Chat messages kept per room for peers who join later; 0 keeps none
+	ChatHistory int `json:"chat_history,omitempty"`
+
+	// Let clients keep rooms open after everyone leaves and across
+	// restarts. Off by default, since any client could take every
+	// persistent room slot until an admin closes them.
+	Persistent bool `json:"persistent,omitempty"`
+}
+
+// IPRoomsConfig decides which devices see each other without a room
+// code. Zero values keep the defaults: /24 for IPv4 and /64 for IPv6.
+type IPRoomsConfig struct {
+	// "subnet" (default), "ip" to group by exact address, or "header" to
+	// group by a header set by a reverse proxy listed in trusted_proxies
+	Mode string `json:"mode,omitempty"`
+
+	// Prefix lengths for "subnet" mode, e.g. 22 for a /22 office network
+	IPv4Prefix int `json:"ipv4_prefix,omitempty"`
+	IPv6Prefix int `json:"ipv6_prefix,omitempty"`
+
+	// Header naming the group in "header" mode, e.g. "X-Tenant"
+	Header string `json:"header,omitempty"`
+}
+
 type Config struct {
 	DeviceName  string `json:"device_name"`
 	Port        int    `json:"port"`
 	DownloadDir string `json:"download_dir"`
+	TLS         bool   `json:"tls"`
+	TURN        bool   `json:"turn"`
+	TURNPort    int    `json:"turn_port"`
+
+	// Network interfaces to serve on, e.g. ["eth0"]; empty serves on all.
+	// Loopback is always served.
+	Interfaces []string `json:"interfaces,omitempty"`
+
+	// Extra STUN/TURN servers pushed to clients on join
+	ICEServers []ICEServer `json:"ice_servers,omitempty"`
+
+	// Opt-in reporting of recovered panics
+	CrashReports CrashReportConfig `json:"crash_reports"`
+
+	// Named peer groups for multi-target sends
+	Groups []PeerGroup `json:"groups,omitempty"`
+
+	// Origin and source-address policy for signaling connections
+	Access AccessConfig `json:"access"`
+
+	// Reverse proxies, in CIDR form, whose X-Forwarded-For is believed.
+	// Other requests are placed by their socket address.
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+
+	// Tokens required for the API and signaling; none leaves them open
+	APITokens []APIToken `json:"api_tokens,omitempty"`
+
+	// Salted hash of the password the web UI asks for before showing the
+	// page, set with "peer-drop password"; empty leaves it open to anyone
+	// who can reach the port
+	UIPasswordHash string `json:"ui_password_hash,omitempty"`
+
+	// A password written here by hand; Load replaces it with its hash
+	UIPassword string `json:"ui_password,omitempty"`
+
+	// Brute-force protection for room codes
+	RoomJoinLimits RoomJoinLimitsConfig `json:"room_join_limits"`
+
+	// Per-IP limits on connections, transfer requests and API calls
+	RateLimits RateLimitsConfig `json:"rate_limits"`
+
+	// Largest file and whole transfer, in bytes, senders may offer; 0 for
+	// no limit. Keeps small receivers such as a Raspberry Pi from filling
+	// their disk.
+	MaxFileSize     int64 `json:"max_file_size,omitempty"`
+	MaxTransferSize int64 `json:"max_transfer_size,omitempty"`
+
+	// Public room code format and alias lifetime
+	Rooms RoomsConfig `json:"rooms"`
+
+	// Grouping of devices into rooms by network
+	IPRooms IPRoomsConfig `json:"ip_rooms"`
 }
 
 func DefaultConfig() *Config {
@@ -19,47 +173,23 @@ func DefaultConfig() *Config {
 		hostname = "Peer-Drop Device"
 	}
 
-	downloadDir := getDefaultDownloadDir()
-
 	return &Config{
 		DeviceName:  hostname,
 		Port:        8080,
-		DownloadDir: downloadDir,
+		DownloadDir: paths.DownloadDir(),
+		TURNPort:    3478,
 	}
 }
 
-func getDefaultDownloadDir() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "."
-	}
-	return filepath.Join(home, "Downloads", "PeerDrop")
-}
-
-func getConfigPath() string {
-	var configDir string
-
-	switch runtime.GOOS {
-	case "windows":
-		configDir = os.Getenv("APPDATA")
-		if configDir == "" {
-			configDir = filepath.Join(os.Getenv("USERPROFILE"), "AppData", "Roaming")
-		}
-	default:
-		configDir = os.Getenv("XDG_CONFIG_HOME")
-		if configDir == "" {
-			home, _ := os.UserHomeDir()
-			configDir = filepath.Join(home, ".config")
-		}
-	}
-
-	return filepath.Join(configDir, "peerdrop", "config.json")
+// Dir returns the directory holding config.json and the TLS certificate
+func Dir() string {
+	return paths.ConfigDir()
 }
 
 func Load() (*Config, error) {
 	cfg := DefaultConfig()
 
-	configPath := getConfigPath()
+	configPath := paths.ConfigFile()
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -72,11 +202,36 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	if cfg.UIPassword != "" {
+		if err := cfg.SetUIPassword(cfg.UIPassword); err != nil {
+			return nil, err
+		}
+		if err := cfg.Save(); err != nil {
+			return nil, fmt.Errorf("replacing ui_password with its hash: %w", err)
+		}
+	}
+
 	return cfg, nil
 }
 
+// SetUIPassword stores the hash of password as the UI password; an empty
+// password removes it
+func (c *Config) SetUIPassword(password string) error {
+	c.UIPassword = ""
+	c.UIPasswordHash = ""
+	if password == "" {
+		return nil
+	}
+	hash, err := passhash.Hash(password)
+	if err != nil {
+		return err
+	}
+	c.UIPasswordHash = hash
+	return nil
+}
+
 func (c *Config) Save() error {
-	configPath := getConfigPath()
+	configPath := paths.ConfigFile()
 
 	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
 		return err
@@ -87,7 +242,12 @@ func (c *Config) Save() error {
 		return err
 	}
 
-	return os.WriteFile(configPath, data, 0644)
+	// The file holds credentials, so only the owner may read it. WriteFile
+	// keeps the mode of an existing file, hence the Chmod.
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(configPath, 0600)
 }
 
 func (c *Config) EnsureDownloadDir() error {
@@ -0,0 +1,346 @@
+// Package conformance replays canned signaling conversations against a
+// running hub and checks its answers. The vectors double as executable
+// documentation of the protocol for authors of other clients.
+//
+// A vector connects a number of clients and runs its steps in order. A
+// step either sends a message from one client or waits for one to arrive:
+//
+//	{"client": 0, "send": {"type": "join", "payload": {...}}}
+//	{"client": 1, "expect": {"type": "peer-joined"}, "capture": {"a": "payload.peer.id"}}
+//	{"client": 0, "expectClose": 4001}
+//
+// An expected message matches when every field it has equals the one
+// received; extra fields are ignored, "*" matches any value, and each
+// element of an expected array must match some received element. Messages
+// that don't match are skipped while waiting, since other clients on the
+// hub may cause traffic too. Captured values replace
+// "${name}" in later steps; "${run}" is unique per run, to tell this
+// run's clients from others on the hub.
+package conformance
+
+import (
+	"context"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//go:embed vectors/*.json
+var builtin embed.FS
+
+// How long an expect step waits for its message
+const expectTimeout = 3 * time.Second
+
+// Vector is one replayable conversation
+type Vector struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Clients     int    `json:"clients"`
+	Steps       []Step `json:"steps"`
+}
+
+// Step sends or expects one message
+type Step struct {
+	Client      int               `json:"client"`
+	Send        json.RawMessage   `json:"send,omitempty"`
+	Expect      json.RawMessage   `json:"expect,omitempty"`
+	Capture     map[string]string `json:"capture,omitempty"` // name -> dotted path in the received message
+	ExpectClose int               `json:"expectClose,omitempty"`
+}
+
+// Builtin returns the vectors shipped with this version, sorted by name
+func Builtin() ([]Vector, error) {
+	files, err := fs.Glob(builtin, "vectors/*.json")
+	if err != nil {
+		return nil, err
+	}
+	var vectors []Vector
+	for _, f := range files {
+		data, err := builtin.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		v, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path.Base(f), err)
+		}
+		vectors = append(vectors, v)
+	}
+	sort.Slice(vectors, func(i, j int) bool { return vectors[i].Name < vectors[j].Name })
+	return vectors, nil
+}
+
+// Parse reads and checks a vector
+func Parse(data []byte) (Vector, error) {
+	var v Vector
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, err
+	}
+	if v.Name == "" || v.Clients < 1 {
+		return v, errors.New("vector needs a name and at least one client")
+	}
+	for i, s := range v.Steps {
+		actions := 0
+		for _, set := range []bool{s.Send != nil, s.Expect != nil, s.ExpectClose != 0} {
+			if set {
+				actions++
+			}
+		}
+		if actions != 1 {
+			return v, fmt.Errorf("step %d: needs exactly one of send, expect and expectClose", i)
+		}
+		if s.Client < 0 || s.Client >= v.Clients {
+			return v, fmt.Errorf("step %d: client %d out of range", i, s.Client)
+		}
+	}
+	return v, nil
+}
+
+// received is a text message or the end of a connection
+type received struct {
+	msg       map[string]any
+	closeCode int
+	err       error
+}
+
+// Run replays v against the hub's WebSocket endpoint at url
+func Run(ctx context.Context, url string, v Vector) error {
+	run := make([]byte, 4)
+	rand.Read(run)
+	vars := map[string]string{"run": hex.EncodeToString(run)}
+
+	conns := make([]*websocket.Conn, v.Clients)
+	inbox := make([]chan received, v.Clients)
+	for i := range conns {
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, http.Header{})
+		if err != nil {
+			return fmt.Errorf("client %d: connect: %w", i, err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+		inbox[i] = make(chan received, 256)
+		go readLoop(conn, inbox[i])
+	}
+
+	for i, step := range v.Steps {
+		if err := runStep(ctx, step, conns[step.Client], inbox[step.Client], vars); err != nil {
+			return fmt.Errorf("step %d (client %d): %w", i, step.Client, err)
+		}
+	}
+	return nil
+}
+
+// readLoop splits the hub's newline-batched frames into messages
+func readLoop(conn *websocket.Conn, out chan<- received) {
+	defer close(out)
+	for {
+		kind, data, err := conn.ReadMessage()
+		if err != nil {
+			var ce *websocket.CloseError
+			if errors.As(err, &ce) {
+				out <- received{closeCode: ce.Code}
+			} else {
+				out <- received{err: err}
+			}
+			return
+		}
+		if kind != websocket.TextMessage {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			var msg map[string]any
+			if err := json.Unmarshal([]byte(line), &msg); err != nil {
+				out <- received{err: fmt.Errorf("invalid JSON from hub: %w", err)}
+				return
+			}
+			out <- received{msg: msg}
+		}
+	}
+}
+
+func runStep(ctx context.Context, step Step, conn *websocket.Conn, inbox <-chan received, vars map[string]string) error {
+	switch {
+	case step.Send != nil:
+		return conn.WriteMessage(websocket.TextMessage, []byte(substitute(string(step.Send), vars)))
+
+	case step.ExpectClose != 0:
+		for {
+			r, err := next(ctx, inbox)
+			if err != nil {
+				return fmt.Errorf("waiting for close %d: %w", step.ExpectClose, err)
+			}
+			if r.msg != nil {
+				continue
+			}
+			if r.closeCode != step.ExpectClose {
+				return fmt.Errorf("closed with code %d, want %d", r.closeCode, step.ExpectClose)
+			}
+			return nil
+		}
+
+	default:
+		var want map[string]any
+		if err := json.Unmarshal([]byte(substitute(string(step.Expect), vars)), &want); err != nil {
+			return fmt.Errorf("expect: %w", err)
+		}
+		var mismatch error // last message of the right type that didn't match
+		for {
+			r, err := next(ctx, inbox)
+			if err != nil {
+				if mismatch != nil {
+					return fmt.Errorf("waiting for %v: %w; closest: %w", want["type"], err, mismatch)
+				}
+				return fmt.Errorf("waiting for %v: %w", want["type"], err)
+			}
+			if r.msg == nil {
+				return fmt.Errorf("connection closed with code %d while waiting for %v", r.closeCode, want["type"])
+			}
+			if r.msg["type"] != want["type"] {
+				continue
+			}
+			if err := match(want, r.msg, ""); err != nil {
+				got, _ := json.Marshal(r.msg)
+				mismatch = fmt.Errorf("%w\n  got: %s", err, got)
+				continue
+			}
+			for name, p := range step.Capture {
+				value, ok := lookup(r.msg, p)
+				if !ok {
+					return fmt.Errorf("capture %s: no value at %s", name, p)
+				}
+				vars[name] = fmt.Sprint(value)
+			}
+			return nil
+		}
+	}
+}
+
+// next returns the next item from inbox, failing after expectTimeout
+func next(ctx context.Context, inbox <-chan received) (received, error) {
+	timer := time.NewTimer(expectTimeout)
+	defer timer.Stop()
+	select {
+	case r, ok := <-inbox:
+		if !ok {
+			return r, errors.New("connection gone")
+		}
+		if r.err != nil {
+			return r, r.err
+		}
+		return r, nil
+	case <-timer.C:
+		return received{}, errors.New("timed out")
+	case <-ctx.Done():
+		return received{}, ctx.Err()
+	}
+}
+
+var placeholder = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// substitute replaces ${name} with captured values
+func substitute(s string, vars map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		if v, ok := vars[m[2:len(m)-1]]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// match reports how got differs from the expected subset want
+func match(want, got any, at string) error {
+	if s, ok := want.(string); ok && s == "*" {
+		if got == nil {
+			return fmt.Errorf("%s: missing", field(at))
+		}
+		return nil
+	}
+
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: want an object", field(at))
+		}
+		for k, wv := range w {
+			if err := match(wv, g[k], joinPath(at, k)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			return fmt.Errorf("%s: want an array", field(at))
+		}
+		for i, wv := range w {
+			found := false
+			for _, gv := range g {
+				if match(wv, gv, "") == nil {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s: no element matches", field(joinPath(at, strconv.Itoa(i))))
+			}
+		}
+		return nil
+
+	default:
+		if want != got {
+			return fmt.Errorf("%s: got %v, want %v", field(at), got, want)
+		}
+		return nil
+	}
+}
+
+// lookup follows a dotted path such as "payload.peers.0.id"
+func lookup(v any, p string) (any, bool) {
+	for _, key := range strings.Split(p, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
+func joinPath(at, key string) string {
+	if at == "" {
+		return key
+	}
+	return at + "." + key
+}
+
+func field(at string) string {
+	if at == "" {
+		return "message"
+	}
+	return at
+}
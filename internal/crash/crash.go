@@ -0,0 +1,208 @@
+// Package crash records recovered panics so intermittent failures can be
+// diagnosed after the fact. Reports are written under the state directory
+// and, when an endpoint is configured, POSTed there signed with HMAC-SHA256.
+package crash
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// At most this many reports are kept per rateWindow, and each distinct
+	// panic site is reported once per window
+	maxReportsPerWindow = 10
+	rateWindow          = time.Hour
+
+	postTimeout = 10 * time.Second
+
+	// SignatureHeader carries "sha256=<hex HMAC of the body>" on uploads
+	SignatureHeader = "X-PeerDrop-Signature"
+)
+
+// Options configures a Reporter
+type Options struct {
+	Dir      string // where reports are written
+	Endpoint string // optional URL to POST reports to
+	Secret   string // HMAC key for uploads; unsigned when empty
+	Version  string
+}
+
+// Report is one recovered panic
+type Report struct {
+	Time      time.Time `json:"time"`
+	Version   string    `json:"version"`
+	GoVersion string    `json:"goVersion"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	Where     string    `json:"where"`
+	Panic     string    `json:"panic"`
+	Stack     string    `json:"stack"`
+}
+
+// Reporter writes and uploads crash reports
+type Reporter struct {
+	opts   Options
+	client *http.Client
+	logger *slog.Logger
+
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+	seen        map[string]time.Time // site -> last reported
+}
+
+// New creates a Reporter
+func New(opts Options, logger *slog.Logger) *Reporter {
+	return &Reporter{
+		opts:   opts,
+		client: &http.Client{Timeout: postTimeout},
+		logger: logger,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Report records a recovered panic value v with the stack captured at the
+// point of recovery. where names the component that recovered it.
+func (r *Reporter) Report(where string, v any, stack []byte) {
+	r.logger.Error("recovered panic", "where", where, "panic", fmt.Sprint(v))
+
+	site := panicSite(where, stack)
+	if !r.allow(site) {
+		r.logger.Debug("crash report rate limited", "site", site)
+		return
+	}
+
+	report := Report{
+		Time:      time.Now().UTC(),
+		Version:   r.opts.Version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Where:     where,
+		Panic:     redact(fmt.Sprint(v)),
+		Stack:     redact(string(stack)),
+	}
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		r.logger.Warn("failed to encode crash report", "error", err)
+		return
+	}
+
+	path, err := r.write(report.Time, body)
+	if err != nil {
+		r.logger.Warn("failed to write crash report", "error", err)
+	} else {
+		r.logger.Info("crash report written", "path", path)
+	}
+
+	if r.opts.Endpoint != "" {
+		go r.upload(body)
+	}
+}
+
+// allow applies the global and per-site rate limits
+func (r *Reporter) allow(site string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.windowStart) >= rateWindow {
+		r.windowStart = now
+		r.count = 0
+	}
+	if r.count >= maxReportsPerWindow {
+		return false
+	}
+	if last, ok := r.seen[site]; ok && now.Sub(last) < rateWindow {
+		return false
+	}
+
+	r.count++
+	r.seen[site] = now
+	return true
+}
+
+func (r *Reporter) write(t time.Time, body []byte) (string, error) {
+	if err := os.MkdirAll(r.opts.Dir, 0700); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("crash-%s.json", t.Format("20060102-150405.000"))
+	path := filepath.Join(r.opts.Dir, name)
+	return path, os.WriteFile(path, body, 0600)
+}
+
+func (r *Reporter) upload(body []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), postTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		r.logger.Warn("crash report upload failed", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.opts.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(body, r.opts.Secret))
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		r.logger.Warn("crash report upload failed", "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		r.logger.Warn("crash report upload rejected", "status", resp.StatusCode)
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of body under secret
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// panicSite identifies where a panic happened: the component plus the
+// function that called panic, taken from the frame after runtime's panic()
+func panicSite(where string, stack []byte) string {
+	lines := strings.Split(string(stack), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "panic(") && i+2 < len(lines) {
+			return where + " " + strings.TrimSpace(lines[i+2])
+		}
+	}
+	return where
+}
+
+var (
+	ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Pattern = regexp.MustCompile(`\b(?:[0-9a-fA-F]{1,4}:){2,7}[0-9a-fA-F]{1,4}\b`)
+	homeDir, _  = os.UserHomeDir()
+)
+
+// redact strips addresses and the user's home directory, which are the
+// personal details most likely to end up in panic messages and stacks
+func redact(s string) string {
+	if homeDir != "" {
+		s = strings.ReplaceAll(s, homeDir, "~")
+	}
+	s = ipv4Pattern.ReplaceAllString(s, "<ip>")
+	s = ipv6Pattern.ReplaceAllString(s, "<ip>")
+	return s
+}
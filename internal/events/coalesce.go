@@ -0,0 +1,137 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Keyed is implemented by event data that belongs to a stream of related
+// events, such as one transfer's. Events are coalesced per key.
+type Keyed interface {
+	EventKey() string
+}
+
+// Keys remembered per coalesced kind before old ones are forgotten
+const maxCoalesceKeys = 1024
+
+// Coalesce limits events of kind to perSecond per key for this
+// subscription. Events over the limit are not dropped outright: the
+// latest one is held and delivered once the interval is up, so the
+// subscriber always ends up with the most recent state. Events of other
+// kinds with the same key, such as an answer following progress, are
+// delivered immediately, after any held event for that key. A perSecond
+// of zero or less removes the limit.
+func (s *Subscription) Coalesce(kind Kind, perSecond int) {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if perSecond <= 0 {
+		delete(s.coalesce, kind)
+		return
+	}
+	if s.coalesce == nil {
+		s.coalesce = make(map[Kind]*coalescer)
+	}
+	s.coalesce[kind] = &coalescer{
+		sub:      s,
+		interval: time.Second / time.Duration(perSecond),
+		last:     make(map[string]time.Time),
+		pending:  make(map[string]Event),
+	}
+}
+
+// flushKey delivers the events held for e's key, ahead of e. The caller
+// must hold bus.mu.
+func (s *Subscription) flushKey(e Event) {
+	key := eventKey(e)
+	if key == "" {
+		return
+	}
+	for _, c := range s.coalesce {
+		if held, ok := c.take(key); ok {
+			s.bus.deliver(s, held)
+		}
+	}
+}
+
+// coalescer rate-limits one kind of event for a subscription
+type coalescer struct {
+	sub      *Subscription
+	interval time.Duration
+
+	mu      sync.Mutex
+	last    map[string]time.Time // when each key last had an event delivered
+	pending map[string]Event     // latest held event per key
+}
+
+// admit reports whether e may be delivered now. Otherwise it is held,
+// replacing any earlier held event for its key. The caller must hold
+// bus.mu.
+func (c *coalescer) admit(e Event) bool {
+	key := eventKey(e)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, held := c.pending[key]; held {
+		c.pending[key] = e
+		return false
+	}
+	if wait := c.last[key].Add(c.interval).Sub(e.Time); wait > 0 {
+		c.pending[key] = e
+		time.AfterFunc(wait, func() { c.flush(key) })
+		return false
+	}
+
+	if len(c.last) >= maxCoalesceKeys {
+		for k, t := range c.last {
+			if e.Time.Sub(t) >= c.interval {
+				delete(c.last, k)
+			}
+		}
+	}
+	c.last[key] = e.Time
+	return true
+}
+
+// flush delivers the event held for key, if the subscription is still
+// open and nothing delivered it first
+func (c *coalescer) flush(key string) {
+	bus := c.sub.bus
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+	if _, ok := bus.subs[c.sub]; !ok {
+		return
+	}
+
+	c.mu.Lock()
+	e, ok := c.pending[key]
+	if ok {
+		delete(c.pending, key)
+		c.last[key] = time.Now()
+	}
+	c.mu.Unlock()
+
+	if ok {
+		bus.deliver(c.sub, e)
+	}
+}
+
+// take removes and returns the event held for key, and forgets the key
+// so the next event for it is delivered at once
+func (c *coalescer) take(key string) (Event, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.pending[key]
+	delete(c.pending, key)
+	delete(c.last, key)
+	return e, ok
+}
+
+func eventKey(e Event) string {
+	if k, ok := e.Data.(Keyed); ok {
+		return k.EventKey()
+	}
+	return ""
+}
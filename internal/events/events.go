@@ -0,0 +1,231 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kind identifies what happened
+type Kind string
+
+// Hub event kinds
+const (
+	ClientConnected    Kind = "client.connected"
+	ClientJoined       Kind = "client.joined"
+	ClientDisconnected Kind = "client.disconnected"
+	RoomCreated        Kind = "room.created"
+	RoomJoined         Kind = "room.joined"
+	RoomLeft           Kind = "room.left"
+	RoomClosed         Kind = "room.closed"
+)
+
+// Transfer event kinds. Only the request and answer of a direct WebRTC
+// transfer pass through the hub; progress is published for relayed
+// chunks, once per chunk, which is what Coalesce is for.
+const (
+	TransferRequested Kind = "transfer.requested"
+	TransferAnswered  Kind = "transfer.answered"
+	TransferProgress  Kind = "transfer.progress"
+)
+
+// Event is a single notification published on the bus. Data holds one of
+// the typed payloads below, depending on Kind.
+type Event struct {
+	Kind Kind      `json:"kind"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data,omitempty"`
+}
+
+// ClientEvent describes a signaling client for client.* events
+type ClientEvent struct {
+	ClientID string `json:"clientId"`
+	Name     string `json:"name,omitempty"`
+	Platform string `json:"platform,omitempty"`
+	IP       string `json:"ip,omitempty"`
+	Room     string `json:"room,omitempty"`
+}
+
+// RoomEvent describes a room for room.* events
+type RoomEvent struct {
+	Room     string `json:"room"`
+	Alias    string `json:"alias,omitempty"`
+	Public   bool   `json:"public"`
+	ClientID string `json:"clientId,omitempty"`
+}
+
+// TransferEvent describes a transfer for transfer.* events. Sender and
+// Receiver are the ends of the transfer, not of the message: an answer
+// comes from the Receiver.
+type TransferEvent struct {
+	TransferID string `json:"transferId"`
+	Sender     string `json:"sender"`
+	Receiver   string `json:"receiver"`
+
+	Accepted bool `json:"accepted,omitempty"` // transfer.answered
+
+	// transfer.progress
+	FileIndex   int `json:"fileIndex,omitempty"`
+	Chunk       int `json:"chunk,omitempty"`
+	TotalChunks int `json:"totalChunks,omitempty"`
+}
+
+// EventKey identifies the transfer; IDs are picked by the sender, so
+// they are only unique per sender
+func (e TransferEvent) EventKey() string {
+	return e.Sender + "/" + e.TransferID
+}
+
+// Default buffer size for subscribers that don't pick one
+const DefaultBuffer = 64
+
+// Bus fans events out to subscribers. Publishing never blocks: a
+// subscriber whose buffer is full misses the event and its drop counter
+// is incremented, so one slow consumer can't stall the hub.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+
+	published atomic.Int64
+	dropped   atomic.Int64
+}
+
+// New creates an empty bus
+func New() *Bus {
+	return &Bus{
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives events from a Bus
+type Subscription struct {
+	// C delivers events; it is closed when the subscription or bus closes
+	C <-chan Event
+
+	ch      chan Event
+	kinds   map[Kind]bool
+	bus     *Bus
+	dropped atomic.Int64
+	once    sync.Once
+
+	// Kinds delivered at a limited rate, guarded by bus.mu
+	coalesce map[Kind]*coalescer
+}
+
+// Subscribe registers a subscriber with the given buffer size. If kinds
+// are given only those are delivered, otherwise every event is.
+func (b *Bus) Subscribe(buffer int, kinds ...Kind) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+
+	ch := make(chan Event, buffer)
+	sub := &Subscription{C: ch, ch: ch, bus: b}
+	if len(kinds) > 0 {
+		sub.kinds = make(map[Kind]bool, len(kinds))
+		for _, k := range kinds {
+			sub.kinds[k] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return sub
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers an event to every interested subscriber.
+// A nil bus discards events, so publishers need no nil checks.
+func (b *Bus) Publish(kind Kind, data any) {
+	if b == nil {
+		return
+	}
+
+	e := Event{Kind: kind, Time: time.Now(), Data: data}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+
+	b.published.Add(1)
+	for sub := range b.subs {
+		if sub.kinds != nil && !sub.kinds[kind] {
+			continue
+		}
+		if c := sub.coalesce[kind]; c != nil {
+			if !c.admit(e) {
+				continue
+			}
+		} else if len(sub.coalesce) > 0 {
+			sub.flushKey(e)
+		}
+		b.deliver(sub, e)
+	}
+}
+
+// Wants reports whether any subscriber takes events of kind, so hot paths
+// can skip building events nobody receives
+func (b *Bus) Wants(kind Kind) bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		if sub.kinds == nil || sub.kinds[kind] {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver sends e to sub without blocking. The caller must hold b.mu.
+func (b *Bus) deliver(sub *Subscription, e Event) {
+	select {
+	case sub.ch <- e:
+	default:
+		sub.dropped.Add(1)
+		b.dropped.Add(1)
+	}
+}
+
+// Stats returns the number of events published and dropped across all subscribers
+func (b *Bus) Stats() (published, dropped int64) {
+	return b.published.Load(), b.dropped.Load()
+}
+
+// Close closes every subscription; later publishes are discarded
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for sub := range b.subs {
+		sub.once.Do(func() { close(sub.ch) })
+	}
+	b.subs = nil
+}
+
+// Dropped returns how many events this subscriber missed because its buffer was full
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes C
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	delete(s.bus.subs, s)
+	s.bus.mu.Unlock()
+
+	s.once.Do(func() { close(s.ch) })
+}
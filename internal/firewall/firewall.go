@@ -0,0 +1,17 @@
+// Package firewall manages the inbound firewall rule Peer-Drop needs to be
+// reachable from other devices. Only Windows is supported; other platforms
+// rarely block inbound ports by default.
+package firewall
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupported is returned on platforms without firewall integration
+var ErrUnsupported = errors.New("firewall management is not supported on this platform")
+
+// RuleName returns the name of the inbound rule for port
+func RuleName(port int) string {
+	return fmt.Sprintf("Peer-Drop (TCP %d)", port)
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package firewall
+
+// Supported reports whether firewall rules can be managed on this platform
+func Supported() bool {
+	return false
+}
+
+// Status reports whether the inbound rule for port exists
+func Status(port int) (bool, error) {
+	return false, ErrUnsupported
+}
+
+// Allow creates an inbound rule for port
+func Allow(port int, public bool) error {
+	return ErrUnsupported
+}
+
+// Remove deletes the inbound rule for port
+func Remove(port int) error {
+	return ErrUnsupported
+}
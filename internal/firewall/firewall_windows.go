@@ -0,0 +1,69 @@
+package firewall
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Supported reports whether firewall rules can be managed on this platform
+func Supported() bool {
+	return true
+}
+
+// Status reports whether the inbound rule for port exists
+func Status(port int) (bool, error) {
+	cmd := exec.Command("netsh", "advfirewall", "firewall", "show", "rule", "name="+RuleName(port))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// netsh exits non-zero when no rule matches
+			return false, nil
+		}
+		return false, fmt.Errorf("netsh: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return true, nil
+}
+
+// Allow creates an inbound rule for port. When public is false the rule
+// only applies to private and domain networks.
+func Allow(port int, public bool) error {
+	if exists, err := Status(port); err == nil && exists {
+		return nil
+	}
+
+	profile := "private,domain"
+	if public {
+		profile = "any"
+	}
+
+	cmd := exec.Command("netsh", "advfirewall", "firewall", "add", "rule",
+		"name="+RuleName(port),
+		"dir=in",
+		"action=allow",
+		"protocol=TCP",
+		fmt.Sprintf("localport=%d", port),
+		"profile="+profile,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if strings.Contains(strings.ToLower(msg), "elevation") {
+			return errors.New("adding a firewall rule requires administrator rights, run this from an elevated prompt")
+		}
+		return fmt.Errorf("netsh: %w: %s", err, msg)
+	}
+	return nil
+}
+
+// Remove deletes the inbound rule for port
+func Remove(port int) error {
+	cmd := exec.Command("netsh", "advfirewall", "firewall", "delete", "rule", "name="+RuleName(port))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("netsh: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Package logbuf keeps recent log records in memory and fans new ones out
+// to subscribers, so the admin API can stream what the daemon logs.
+package logbuf
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Record is a captured log record
+type Record struct {
+	Time    time.Time      `json:"time"`
+	Level   slog.Level     `json:"level"`
+	Module  string         `json:"module,omitempty"`
+	Message string         `json:"msg"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+// The attribute whose value is a record's module, as set by
+// logger.With("component", ...)
+const moduleKey = "component"
+
+// Buffer is a fixed-size ring of recent records plus live subscribers.
+// Subscribers with a full buffer miss records rather than block logging.
+type Buffer struct {
+	mu      sync.Mutex
+	records []Record
+	next    int
+	subs    map[chan Record]struct{}
+}
+
+// New creates a buffer that keeps the last size records
+func New(size int) *Buffer {
+	return &Buffer{
+		records: make([]Record, 0, size),
+		subs:    make(map[chan Record]struct{}),
+	}
+}
+
+func (b *Buffer) add(r Record) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.records) < cap(b.records) {
+		b.records = append(b.records, r)
+	} else {
+		b.records[b.next] = r
+		b.next = (b.next + 1) % len(b.records)
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- r:
+		default:
+		}
+	}
+}
+
+// Subscribe returns the buffered records, oldest first, and a channel of
+// the ones logged after. cancel must be called when done.
+func (b *Buffer) Subscribe(buffer int) (recent []Record, ch <-chan Record, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	recent = make([]Record, 0, len(b.records))
+	recent = append(recent, b.records[b.next:]...)
+	recent = append(recent, b.records[:b.next]...)
+
+	c := make(chan Record, buffer)
+	b.subs[c] = struct{}{}
+	return recent, c, func() {
+		b.mu.Lock()
+		delete(b.subs, c)
+		b.mu.Unlock()
+	}
+}
+
+// Handler returns a slog.Handler that records into b and passes every
+// record on to next. Records are captured at the levels next is enabled
+// for, so the stream shows what the console does.
+func (b *Buffer) Handler(next slog.Handler) slog.Handler {
+	return &handler{buf: b, next: next}
+}
+
+type handler struct {
+	buf    *Buffer
+	next   slog.Handler
+	attrs  []slog.Attr // from WithAttrs, keys already prefixed by groups
+	prefix string      // open groups, as "a.b."
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	rec := Record{
+		Time:    r.Time,
+		Level:   r.Level,
+		Message: r.Message,
+	}
+
+	attrs := make(map[string]any, len(h.attrs)+r.NumAttrs())
+	for _, a := range h.attrs {
+		addAttr(attrs, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(attrs, h.prefix, a)
+		return true
+	})
+	if m, ok := attrs[moduleKey].(string); ok {
+		rec.Module = m
+		delete(attrs, moduleKey)
+	}
+	if len(attrs) > 0 {
+		rec.Attrs = attrs
+	}
+
+	h.buf.add(rec)
+	return h.next.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(c.attrs[:len(c.attrs):len(c.attrs)], prefixed(h.prefix, attrs)...)
+	c.next = h.next.WithAttrs(attrs)
+	return &c
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.prefix = h.prefix + name + "."
+	c.next = h.next.WithGroup(name)
+	return &c
+}
+
+func prefixed(prefix string, attrs []slog.Attr) []slog.Attr {
+	out := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		out[i] = slog.Attr{Key: prefix + a.Key, Value: a.Value}
+	}
+	return out
+}
+
+// addAttr flattens a into m under dotted keys, with values that encode
+// sensibly as JSON
+func addAttr(m map[string]any, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		p := prefix
+		if a.Key != "" {
+			p += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			addAttr(m, p, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+
+	switch v.Kind() {
+	case slog.KindString, slog.KindInt64, slog.KindUint64, slog.KindFloat64, slog.KindBool, slog.KindTime:
+		m[prefix+a.Key] = v.Any()
+	default:
+		// Durations, errors and arbitrary values as the console shows them
+		m[prefix+a.Key] = v.String()
+	}
+}
@@ -0,0 +1,69 @@
+// Package passhash stores passwords people pick, such as the UI password,
+// as salted PBKDF2 hashes. Unlike API tokens they can be guessed, so the
+// hash is deliberately slow.
+package passhash
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	scheme     = "pbkdf2-sha256"
+	iterations = 600_000 // per OWASP guidance, as for state archives
+	saltSize   = 16
+	keySize    = 32
+)
+
+// Hash returns the stored form of password:
+// "pbkdf2-sha256$<iterations>$<salt>$<key>", salt and key in base64
+func Hash(password string) (string, error) {
+	salt := make([]byte, saltSize)
+	rand.Read(salt)
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, keySize)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s$%d$%s$%s", scheme, iterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether password matches encoded, a value from Hash
+func Verify(password, encoded string) bool {
+	iter, salt, want, err := parse(encoded)
+	if err != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iter, len(want))
+	return err == nil && subtle.ConstantTimeCompare(key, want) == 1
+}
+
+// Check returns an error unless encoded is in the form Hash produces
+func Check(encoded string) error {
+	_, _, _, err := parse(encoded)
+	return err
+}
+
+func parse(encoded string) (iter int, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != scheme {
+		return 0, nil, nil, fmt.Errorf("not a %s hash", scheme)
+	}
+	if iter, err = strconv.Atoi(parts[1]); err != nil || iter < 1 {
+		return 0, nil, nil, fmt.Errorf("invalid iteration count %q", parts[1])
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return 0, nil, nil, fmt.Errorf("invalid salt: %w", err)
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil || len(key) == 0 {
+		return 0, nil, nil, fmt.Errorf("invalid key")
+	}
+	return iter, salt, key, nil
+}
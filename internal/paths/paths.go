@@ -0,0 +1,75 @@
+// Package paths resolves the per-OS directories Peer-Drop reads and writes.
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const appDir = "peerdrop"
+
+// Layout is a resolved set of directories
+type Layout struct {
+	Config    string // config.json, TLS certificate
+	State     string // persistent runtime state
+	Downloads string // default location for received files
+}
+
+// Current returns the directories in effect
+func Current() Layout {
+	home, _ := os.UserHomeDir()
+	return defaultLayout(runtime.GOOS, os.Getenv, home)
+}
+
+// ConfigDir returns the directory holding config.json
+func ConfigDir() string { return Current().Config }
+
+// ConfigFile returns the path of config.json
+func ConfigFile() string { return filepath.Join(ConfigDir(), "config.json") }
+
+// StateDir returns the directory for persistent runtime state
+func StateDir() string { return Current().State }
+
+// DownloadDir returns the default directory for received files
+func DownloadDir() string { return Current().Downloads }
+
+// defaultLayout computes the platform directories for goos. getenv and home
+// are passed in so every platform's rules can be exercised from any OS.
+func defaultLayout(goos string, getenv func(string) string, home string) Layout {
+	downloads := filepath.Join(home, "Downloads", "PeerDrop")
+	if home == "" {
+		home = "."
+		downloads = "."
+	}
+
+	// Pick the first non-empty value
+	or := func(values ...string) string {
+		for _, v := range values {
+			if v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	var l Layout
+	switch goos {
+	case "windows":
+		roaming := or(getenv("APPDATA"), filepath.Join(or(getenv("USERPROFILE"), home), "AppData", "Roaming"))
+		local := or(getenv("LOCALAPPDATA"), filepath.Join(or(getenv("USERPROFILE"), home), "AppData", "Local"))
+		l.Config = filepath.Join(roaming, appDir)
+		l.State = filepath.Join(local, appDir)
+	case "darwin":
+		// Config stays under XDG_CONFIG_HOME (~/.config) like other Unix
+		// systems, where earlier versions wrote it
+		l.Config = filepath.Join(or(getenv("XDG_CONFIG_HOME"), filepath.Join(home, ".config")), appDir)
+		l.State = filepath.Join(home, "Library", "Application Support", appDir)
+	default:
+		l.Config = filepath.Join(or(getenv("XDG_CONFIG_HOME"), filepath.Join(home, ".config")), appDir)
+		l.State = filepath.Join(or(getenv("XDG_STATE_HOME"), filepath.Join(home, ".local", "state")), appDir)
+	}
+	l.Downloads = downloads
+
+	return l
+}
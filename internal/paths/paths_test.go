@@ -0,0 +1,150 @@
+package paths
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultLayout(t *testing.T) {
+	j := filepath.Join
+	home := j("/", "home", "ann")
+
+	tests := []struct {
+		name string
+		goos string
+		env  map[string]string
+		home string
+		want Layout
+	}{
+		{
+			name: "linux defaults",
+			goos: "linux",
+			home: home,
+			want: Layout{
+				Config:    j(home, ".config", "peerdrop"),
+				State:     j(home, ".local", "state", "peerdrop"),
+				Downloads: j(home, "Downloads", "PeerDrop"),
+			},
+		},
+		{
+			name: "linux XDG",
+			goos: "linux",
+			env: map[string]string{
+				"XDG_CONFIG_HOME": j("/", "xdg", "config"),
+				"XDG_STATE_HOME":  j("/", "xdg", "state"),
+			},
+			home: home,
+			want: Layout{
+				Config:    j("/", "xdg", "config", "peerdrop"),
+				State:     j("/", "xdg", "state", "peerdrop"),
+				Downloads: j(home, "Downloads", "PeerDrop"),
+			},
+		},
+		{
+			name: "freebsd follows XDG",
+			goos: "freebsd",
+			env:  map[string]string{"XDG_STATE_HOME": j("/", "xdg", "state")},
+			home: home,
+			want: Layout{
+				Config:    j(home, ".config", "peerdrop"),
+				State:     j("/", "xdg", "state", "peerdrop"),
+				Downloads: j(home, "Downloads", "PeerDrop"),
+			},
+		},
+		{
+			name: "darwin defaults",
+			goos: "darwin",
+			home: home,
+			want: Layout{
+				Config:    j(home, ".config", "peerdrop"),
+				State:     j(home, "Library", "Application Support", "peerdrop"),
+				Downloads: j(home, "Downloads", "PeerDrop"),
+			},
+		},
+		{
+			name: "darwin keeps config under XDG_CONFIG_HOME",
+			goos: "darwin",
+			env: map[string]string{
+				"XDG_CONFIG_HOME": j("/", "xdg", "config"),
+				"XDG_STATE_HOME":  j("/", "xdg", "state"),
+			},
+			home: home,
+			want: Layout{
+				Config:    j("/", "xdg", "config", "peerdrop"),
+				State:     j(home, "Library", "Application Support", "peerdrop"),
+				Downloads: j(home, "Downloads", "PeerDrop"),
+			},
+		},
+		{
+			name: "windows APPDATA and LOCALAPPDATA",
+			goos: "windows",
+			env: map[string]string{
+				"APPDATA":      j("C:", "Users", "ann", "AppData", "Roaming"),
+				"LOCALAPPDATA": j("C:", "Users", "ann", "AppData", "Local"),
+			},
+			home: j("C:", "Users", "ann"),
+			want: Layout{
+				Config:    j("C:", "Users", "ann", "AppData", "Roaming", "peerdrop"),
+				State:     j("C:", "Users", "ann", "AppData", "Local", "peerdrop"),
+				Downloads: j("C:", "Users", "ann", "Downloads", "PeerDrop"),
+			},
+		},
+		{
+			name: "windows falls back to USERPROFILE",
+			goos: "windows",
+			env:  map[string]string{"USERPROFILE": j("D:", "profile")},
+			home: j("C:", "Users", "ann"),
+			want: Layout{
+				Config:    j("D:", "profile", "AppData", "Roaming", "peerdrop"),
+				State:     j("D:", "profile", "AppData", "Local", "peerdrop"),
+				Downloads: j("C:", "Users", "ann", "Downloads", "PeerDrop"),
+			},
+		},
+		{
+			name: "windows falls back to home",
+			goos: "windows",
+			home: j("C:", "Users", "ann"),
+			want: Layout{
+				Config:    j("C:", "Users", "ann", "AppData", "Roaming", "peerdrop"),
+				State:     j("C:", "Users", "ann", "AppData", "Local", "peerdrop"),
+				Downloads: j("C:", "Users", "ann", "Downloads", "PeerDrop"),
+			},
+		},
+		{
+			name: "no home directory",
+			goos: "linux",
+			want: Layout{
+				Config:    j(".", ".config", "peerdrop"),
+				State:     j(".", ".local", "state", "peerdrop"),
+				Downloads: ".",
+			},
+		},
+		{
+			name: "no home directory on windows",
+			goos: "windows",
+			want: Layout{
+				Config:    j(".", "AppData", "Roaming", "peerdrop"),
+				State:     j(".", "AppData", "Local", "peerdrop"),
+				Downloads: ".",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			if got := defaultLayout(tt.goos, getenv, tt.home); got != tt.want {
+				t.Errorf("defaultLayout(%q) =\n  %+v\nwant\n  %+v", tt.goos, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+
+	if got, want := ConfigFile(), filepath.Join(ConfigDir(), "config.json"); got != want {
+		t.Errorf("ConfigFile() = %q, want %q", got, want)
+	}
+}
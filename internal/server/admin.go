@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"Peer-Drop/internal/apitoken"
+)
+
+// adminOnly restricts a handler to requests from this machine or carrying
+// an admin API token. Admin endpoints expose every client's details, so
+// they are not served to the rest of the LAN; a UI session or a token
+// handed out for using the UI isn't enough either.
+// Browsers are only let in from the server's own pages, so a page from
+// another site open on this machine can't use the loopback exemption.
+func (s *Server) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if crossSite(r) {
+			http.Error(w, "Forbidden: cross-site request", http.StatusForbidden)
+			return
+		}
+		if !s.fromThisMachine(r) && !apitoken.Match(requestToken(r), *s.adminTokens.Load()) {
+			http.Error(w, "Forbidden: needs an admin API token", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// fromThisMachine reports whether r was sent directly from this machine.
+// A request relayed by a reverse proxy also arrives over loopback when the
+// proxy runs here, so one from a trusted proxy or carrying forwarding
+// headers doesn't count. Neither does one addressed to any name but a
+// loopback one: a page on another site can rebind its own name to
+// 127.0.0.1, and then sends its own Host and Origin.
+func (s *Server) fromThisMachine(r *http.Request) bool {
+	if s.hub.FromTrustedProxy(r) {
+		return false
+	}
+	for _, h := range []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"} {
+		if r.Header.Get(h) != "" {
+			return false
+		}
+	}
+	if !loopbackHost(r.Host) {
+		return false
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !loopbackHost(u.Host) {
+			return false
+		}
+	}
+	return isLoopback(r.RemoteAddr)
+}
+
+// loopbackHost reports whether host, with or without a port, names this
+// machine's loopback interface
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	switch strings.ToLower(host) {
+	case "localhost", "127.0.0.1", "[::1]", "::1":
+		return true
+	}
+	return false
+}
+
+// crossSite reports whether a browser sent r for a page from another
+// origin. Tools such as curl and the admin command send neither header.
+func crossSite(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+	default:
+		return true
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopback reports whether remoteAddr is a loopback address. Forwarding
+// headers are ignored since any client can set them.
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// handleAdminStats returns the hub and server counters
+func (s *Server) handleAdminStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.stats())
+}
+
+// handleListClients lists connected clients with their addresses and rooms
+func (s *Server) handleListClients(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.hub.Clients())
+}
+
+// handleKickClient disconnects a client. An optional "reason" query
+// parameter is passed on to it.
+func (s *Server) handleKickClient(w http.ResponseWriter, r *http.Request) {
+	if !s.hub.KickClient(r.PathValue("id"), r.URL.Query().Get("reason")) {
+		http.Error(w, "Client not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListRooms lists public and IP rooms with their occupants
+func (s *Server) handleListRooms(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.hub.Rooms())
+}
+
+func (s *Server) handleCloseRoom(w http.ResponseWriter, r *http.Request) {
+	if !s.hub.CloseRoom(r.PathValue("code")) {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"Peer-Drop/internal/apitoken"
+	"Peer-Drop/internal/config"
+)
+
+// Cookie the web UI keeps its token in; browsers can't set headers on a
+// WebSocket upgrade or an EventSource
+const tokenCookie = "peerdrop_token"
+
+// setAPITokens replaces the tokens authMiddleware and adminOnly accept
+func (s *Server) setAPITokens(tokens []config.APIToken) {
+	hashes := make([]string, len(tokens))
+	var admin []string
+	for i, t := range tokens {
+		hashes[i] = t.Hash
+		if t.Admin {
+			admin = append(admin, t.Hash)
+		}
+	}
+	s.apiTokens.Store(&hashes)
+	s.adminTokens.Store(&admin)
+}
+
+// authMiddleware turns away requests that authorized refuses: the page
+// is sent to the login form, everything else gets a 401
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.authorized(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="peer-drop"`)
+		if s.sessions.enabled() {
+			w.Header().Set("X-Login-URL", "/login")
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// authorized reports whether r may go through. With a UI password set the
+// page needs a session; the API and signaling need a session or a token
+// once either is configured. Static files, the login form and the CA
+// certificate, which a device needs before it can log in without a
+// warning, stay open.
+func (s *Server) authorized(r *http.Request) bool {
+	password := s.sessions.enabled()
+	hashes := *s.apiTokens.Load()
+	switch {
+	case r.URL.Path == "/":
+		if !password {
+			return true
+		}
+	case needsToken(r.URL.Path):
+		if !password && len(hashes) == 0 {
+			return true
+		}
+	default:
+		return true
+	}
+	return s.sessions.valid(r) || apitoken.Match(requestToken(r), hashes)
+}
+
+func needsToken(path string) bool {
+	if path == "/api/cert" {
+		return false
+	}
+	return path == "/ws" || strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/signal/")
+}
+
+// requestToken returns the bearer token from the Authorization header,
+// falling back to the UI's cookie
+func requestToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, token, ok := strings.Cut(h, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if c, err := r.Cookie(tokenCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
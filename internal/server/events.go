@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Peer-Drop/internal/events"
+)
+
+const (
+	// Events queued for a slow event stream before it starts missing some
+	eventStreamBuffer = 256
+
+	// transfer.progress events per second and transfer sent to an event
+	// stream that doesn't ask for another rate
+	defaultProgressRate = 4
+)
+
+// handleEventStream streams hub and transfer events from the event bus
+// as server-sent events named after their kind. "kind" limits the stream
+// to a comma-separated list of kinds. "progress_rate" sets how many
+// transfer.progress events per second each transfer may send, 0 for every
+// relayed chunk; requests and answers are always sent at once.
+func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
+	var kinds []events.Kind
+	for _, k := range strings.Split(r.URL.Query().Get("kind"), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			kinds = append(kinds, events.Kind(k))
+		}
+	}
+	rate := defaultProgressRate
+	if v := r.URL.Query().Get("progress_rate"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid progress_rate", http.StatusBadRequest)
+			return
+		}
+		rate = n
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	sub := s.events.Subscribe(eventStreamBuffer, kinds...)
+	defer sub.Close()
+	sub.Coalesce(events.TransferProgress, rate)
+
+	rc.SetWriteDeadline(time.Now().Add(streamWriteWait))
+	if err := rc.Flush(); err != nil {
+		s.logger.Error("event stream not supported", "error", err)
+		return
+	}
+
+	ticker := time.NewTicker(logStreamKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		// Deadlines are set per write, as in handleLogStream
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			rc.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			writeBusEvent(w, e)
+		case <-ticker.C:
+			rc.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			io.WriteString(w, ": keep-alive\n\n")
+		case <-s.stopping:
+			return
+		case <-r.Context().Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeBusEvent(w io.Writer, e events.Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Kind, data)
+}
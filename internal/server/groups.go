@@ -0,0 +1,166 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"Peer-Drop/internal/api"
+	"Peer-Drop/internal/config"
+)
+
+const (
+	maxGroupNameLength = 64
+	maxGroupMembers    = 64
+	maxGroups          = 64
+)
+
+// errTooManyGroups is returned when adding a group beyond maxGroups
+var errTooManyGroups = errors.New("too many groups")
+
+// groupStore holds the peer groups from config and saves changes back
+type groupStore struct {
+	mu     sync.Mutex
+	groups []config.PeerGroup
+}
+
+func newGroupStore(groups []config.PeerGroup) *groupStore {
+	return &groupStore{groups: slices.Clone(groups)}
+}
+
+func (g *groupStore) list() []config.PeerGroup {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]config.PeerGroup{}, g.groups...)
+}
+
+// replace swaps in groups reloaded from the config file
+func (g *groupStore) replace(groups []config.PeerGroup) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.groups = slices.Clone(groups)
+}
+
+// put creates or replaces a group and persists the result
+func (g *groupStore) put(group config.PeerGroup) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	groups := slices.Clone(g.groups)
+	i := slices.IndexFunc(groups, func(pg config.PeerGroup) bool { return pg.Name == group.Name })
+	switch {
+	case i >= 0:
+		groups[i] = group
+	case len(groups) >= maxGroups:
+		return errTooManyGroups
+	default:
+		groups = append(groups, group)
+	}
+
+	if err := saveGroups(groups); err != nil {
+		return err
+	}
+	g.groups = groups
+	return nil
+}
+
+// remove deletes a group and persists the result. It reports whether the
+// group existed.
+func (g *groupStore) remove(name string) (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	groups := slices.DeleteFunc(slices.Clone(g.groups), func(pg config.PeerGroup) bool { return pg.Name == name })
+	if len(groups) == len(g.groups) {
+		return false, nil
+	}
+
+	if err := saveGroups(groups); err != nil {
+		return true, err
+	}
+	g.groups = groups
+	return true, nil
+}
+
+// saveGroups rewrites the groups in the config file. The file is reloaded
+// first so command-line overrides in the running config aren't persisted.
+func saveGroups(groups []config.PeerGroup) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	cfg.Groups = groups
+	return cfg.Save()
+}
+
+// validateGroup trims and checks a group from a request
+func validateGroup(group *config.PeerGroup) error {
+	group.Name = strings.TrimSpace(group.Name)
+	if group.Name == "" || len(group.Name) > maxGroupNameLength {
+		return errors.New("group name must be 1-64 characters")
+	}
+
+	members := make([]string, 0, len(group.Members))
+	for _, m := range group.Members {
+		m = strings.TrimSpace(m)
+		if m != "" && !slices.Contains(members, m) {
+			members = append(members, m)
+		}
+	}
+	if len(members) == 0 {
+		return errors.New("group needs at least one member")
+	}
+	if len(members) > maxGroupMembers {
+		return errors.New("too many group members")
+	}
+	group.Members = members
+	return nil
+}
+
+func (s *Server) handleListGroups(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.Groups{Groups: s.groups.list()})
+}
+
+func (s *Server) handlePutGroup(w http.ResponseWriter, r *http.Request) {
+	var body api.GroupMembers
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	group := config.PeerGroup{Name: r.PathValue("name"), Members: body.Members}
+	if err := validateGroup(&group); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.groups.put(group); errors.Is(err, errTooManyGroups) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		s.logger.Error("failed to save peer group", "group", group.Name, "error", err)
+		http.Error(w, "Failed to save group", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(group)
+}
+
+func (s *Server) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	found, err := s.groups.remove(r.PathValue("name"))
+	if err != nil {
+		s.logger.Error("failed to save peer groups", "error", err)
+		http.Error(w, "Failed to save groups", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
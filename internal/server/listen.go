@@ -0,0 +1,198 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+
+	"Peer-Drop/internal/api"
+)
+
+// NetInterface is a network interface the server is reachable on
+type NetInterface = api.NetInterface
+
+// resolveInterfaces returns the addresses to listen on for the named
+// interfaces, and the interfaces that serves. With no names the server
+// listens on all of them. Loopback is always served so the local browser
+// keeps working when only e.g. "eth0" is allowed.
+func resolveInterfaces(names []string, port int) ([]string, []NetInterface, error) {
+	if len(names) == 0 {
+		ifaces, err := upInterfaces(nil)
+		return []string{fmt.Sprintf(":%d", port)}, markPrimary(ifaces), err
+	}
+
+	ifaces, err := upInterfaces(names)
+	if err != nil {
+		return nil, nil, err
+	}
+	ifaces = markPrimary(ifaces)
+	found := make(map[string]bool)
+	for _, iface := range ifaces {
+		found[iface.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, nil, fmt.Errorf("interface %q not found or has no addresses", name)
+		}
+	}
+
+	addrs := []string{net.JoinHostPort("127.0.0.1", strconv.Itoa(port))}
+	for _, iface := range ifaces {
+		for _, ip := range iface.Addresses {
+			if parsed := net.ParseIP(ip); parsed != nil && !parsed.IsLoopback() {
+				addrs = append(addrs, net.JoinHostPort(ip, strconv.Itoa(port)))
+			}
+		}
+	}
+	return addrs, ifaces, nil
+}
+
+// upInterfaces lists the interfaces that are up and have usable
+// addresses, keeping only the named ones when names is non-nil. Loopback
+// and IPv6 link-local addresses, which need a zone to dial, are left out.
+func upInterfaces(names []string) ([]NetInterface, error) {
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []NetInterface
+	for _, iface := range all {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if names != nil && !slices.Contains(names, iface.Name) {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		ni := NetInterface{Name: iface.Name}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			ni.Addresses = append(ni.Addresses, ipNet.IP.String())
+		}
+		if len(ni.Addresses) > 0 {
+			out = append(out, ni)
+		}
+	}
+	return out, nil
+}
+
+// ipv4Addresses returns the IPv4 addresses of ifaces, which is all the
+// TURN server listens on
+func ipv4Addresses(ifaces []NetInterface) []net.IP {
+	var ips []net.IP
+	for _, iface := range ifaces {
+		for _, a := range iface.Addresses {
+			if ip := net.ParseIP(a).To4(); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// markPrimary flags the interface holding the default route's source
+// address and moves it first. Without a default route the first
+// interface with an IPv4 address is taken instead.
+func markPrimary(ifaces []NetInterface) []NetInterface {
+	if len(ifaces) == 0 {
+		return ifaces
+	}
+
+	primary := -1
+	if ip := outboundIP(); ip != nil {
+		for i, iface := range ifaces {
+			if slices.Contains(iface.Addresses, ip.String()) {
+				primary = i
+				break
+			}
+		}
+	}
+	if primary < 0 {
+		for i, iface := range ifaces {
+			if slices.ContainsFunc(iface.Addresses, func(a string) bool { return net.ParseIP(a).To4() != nil }) {
+				primary = i
+				break
+			}
+		}
+	}
+	if primary < 0 {
+		return ifaces
+	}
+
+	ifaces[primary].Primary = true
+	out := append([]NetInterface{ifaces[primary]}, ifaces[:primary]...)
+	return append(out, ifaces[primary+1:]...)
+}
+
+// outboundIP returns the source address the kernel picks for traffic
+// leaving through the default route, or nil if there is none. Connecting
+// a UDP socket only looks up the route; nothing is sent.
+func outboundIP() net.IP {
+	for _, target := range []string{"192.0.2.1:9", "[2001:db8::1]:9"} {
+		conn, err := net.Dial("udp", target)
+		if err != nil {
+			continue
+		}
+		addr := conn.LocalAddr().(*net.UDPAddr)
+		conn.Close()
+		return addr.IP
+	}
+	return nil
+}
+
+// listen opens a listener on every address in s.listenAddrs
+func (s *Server) listen() ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(s.listenAddrs))
+	for _, addr := range s.listenAddrs {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// serve serves HTTP or HTTPS on ln until the server shuts down. TLS is
+// decided by the certificate, not TLSConfig, which Serve fills in for
+// HTTP/2 even on plain listeners.
+func (s *Server) serve(ln net.Listener) error {
+	if s.tlsFingerprint != "" {
+		return s.httpServer.ServeTLS(ln, "", "")
+	}
+	return s.httpServer.Serve(ln)
+}
+
+// Interfaces returns the network interfaces the server is reachable on,
+// not counting loopback, the primary one first
+func (s *Server) Interfaces() []NetInterface {
+	return s.interfaces
+}
+
+// URLs returns the addresses other devices can open the web UI at, those
+// of the primary interface first
+func (s *Server) URLs() []string {
+	scheme := "http"
+	if s.tlsFingerprint != "" {
+		scheme = "https"
+	}
+	urls := []string{}
+	for _, iface := range s.interfaces {
+		for _, ip := range iface.Addresses {
+			urls = append(urls, fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(ip, strconv.Itoa(s.port))))
+		}
+	}
+	return urls
+}
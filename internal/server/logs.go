@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"Peer-Drop/internal/logbuf"
+)
+
+const (
+	// Records queued for a slow log stream before it starts missing some
+	logStreamBuffer = 256
+
+	// Comment sent on an idle log stream so proxies keep it open
+	logStreamKeepAlive = 15 * time.Second
+
+	// Time allowed for each write to a log or event stream. A reader
+	// that stalls past it ends the stream and drops its subscription.
+	streamWriteWait = 10 * time.Second
+)
+
+// SetLogBuffer makes the records captured by logs available at
+// /api/admin/logs/stream. It must be called before Run.
+func (s *Server) SetLogBuffer(logs *logbuf.Buffer) {
+	s.logs = logs
+}
+
+// handleLogStream streams the buffered log records followed by new ones
+// as server-sent "log" events. "level" sets the minimum level (debug,
+// info, warn, error) and "module" a comma-separated list of components,
+// such as signaling or turn.
+func (s *Server) handleLogStream(w http.ResponseWriter, r *http.Request) {
+	if s.logs == nil {
+		http.Error(w, "Log streaming not available", http.StatusNotFound)
+		return
+	}
+
+	minLevel := slog.LevelDebug
+	if v := r.URL.Query().Get("level"); v != "" {
+		if err := minLevel.UnmarshalText([]byte(v)); err != nil {
+			http.Error(w, "Invalid level", http.StatusBadRequest)
+			return
+		}
+	}
+	var modules []string
+	for _, m := range strings.Split(r.URL.Query().Get("module"), ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			modules = append(modules, m)
+		}
+	}
+	wanted := func(rec logbuf.Record) bool {
+		return rec.Level >= minLevel && (len(modules) == 0 || slices.Contains(modules, rec.Module))
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	recent, records, cancel := s.logs.Subscribe(logStreamBuffer)
+	defer cancel()
+
+	rc.SetWriteDeadline(time.Now().Add(streamWriteWait))
+	for _, rec := range recent {
+		if wanted(rec) {
+			writeLogEvent(w, rec)
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		s.logger.Error("event stream not supported", "error", err)
+		return
+	}
+
+	ticker := time.NewTicker(logStreamKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		// Deadlines are set per write: one set before the select would
+		// expire while the stream sits idle
+		select {
+		case rec := <-records:
+			if !wanted(rec) {
+				continue
+			}
+			rc.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			writeLogEvent(w, rec)
+		case <-ticker.C:
+			rc.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			io.WriteString(w, ": keep-alive\n\n")
+		case <-s.stopping:
+			return
+		case <-r.Context().Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeLogEvent(w io.Writer, rec logbuf.Record) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
+}
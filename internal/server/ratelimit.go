@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// API requests allowed per IP per minute when rate_limits doesn't say
+const defaultAPIRequestsPerMinute = 600
+
+// apiLimiter caps API requests per IP in fixed one-minute windows
+type apiLimiter struct {
+	perMinute int // 0 disables the limit
+
+	mu        sync.Mutex
+	windows   map[string]*apiWindow
+	lastSweep time.Time
+}
+
+type apiWindow struct {
+	start time.Time
+	count int
+}
+
+func newAPILimiter(perMinute int) *apiLimiter {
+	return &apiLimiter{perMinute: perMinute, windows: make(map[string]*apiWindow)}
+}
+
+// allow counts a request from ip. When over the limit it returns false
+// and how long until the window ends.
+func (l *apiLimiter) allow(ip string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= time.Minute {
+		for key, w := range l.windows {
+			if now.Sub(w.start) >= time.Minute {
+				delete(l.windows, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.windows[ip]
+	if !ok || now.Sub(w.start) >= time.Minute {
+		w = &apiWindow{start: now}
+		l.windows[ip] = w
+	}
+	if w.count >= l.perMinute {
+		return false, w.start.Add(time.Minute).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// rateLimitMiddleware answers API requests over the per-IP limit with a
+// 429. It runs before authentication, so it also slows down token
+// guessing. Requests from this machine are not limited.
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.apiLimiter.perMinute <= 0 || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip := s.hub.ClientIP(r)
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		if isLoopback(ip) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ok, wait := s.apiLimiter.allow(ip); !ok {
+			w.Header().Set("Retry-After", retryAfter(wait))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"Peer-Drop/internal/api"
+	"Peer-Drop/internal/config"
+	"Peer-Drop/internal/passhash"
+	"Peer-Drop/internal/signaling"
+)
+
+// Config sections /api/admin/reload applies to a running server
+var reloadable = []string{"access", "trusted_proxies", "ip_rooms", "ice_servers", "groups", "api_tokens", "ui_password_hash"}
+
+// SetConfigOverrides sets a function applied to the config file on every
+// reload, as command-line flags were applied to it at startup. Without
+// it, a flag such as -port would read as a pending change. It must be
+// called before Run.
+func (s *Server) SetConfigOverrides(override func(*config.Config)) {
+	s.overrides = override
+}
+
+// applyConfig applies the reloadable sections of cfg. Nothing is applied
+// unless all of them are valid.
+func (s *Server) applyConfig(cfg *config.Config) error {
+	policy, err := signaling.NewConnPolicy(cfg.Access.Origins, cfg.Access.Subnets)
+	if err != nil {
+		return fmt.Errorf("access policy: %w", err)
+	}
+	grouping := ipGrouping(cfg.IPRooms)
+	if err := grouping.Validate(); err != nil {
+		return fmt.Errorf("ip_rooms: %w", err)
+	}
+	if grouping.Mode == signaling.GroupByHeader && len(cfg.TrustedProxies) == 0 {
+		return errors.New("ip_rooms: header grouping needs trusted_proxies")
+	}
+	if cfg.UIPasswordHash != "" {
+		if err := passhash.Check(cfg.UIPasswordHash); err != nil {
+			return fmt.Errorf("ui_password_hash: %w", err)
+		}
+	}
+	// Parsing and storing can't be split, so this one goes first and a
+	// later failure can't leave it half-applied
+	if err := s.hub.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return fmt.Errorf("trusted_proxies: %w", err)
+	}
+
+	s.hub.SetConnPolicy(policy)
+	s.hub.SetIPGrouping(grouping)
+
+	var ice []signaling.ICEServer
+	for _, server := range cfg.ICEServers {
+		if len(server.URLs) == 0 {
+			continue
+		}
+		ice = append(ice, signaling.ICEServer{
+			URLs:       server.URLs,
+			Username:   server.Username,
+			Credential: server.Credential,
+		})
+	}
+	s.extraICEServers.Store(&ice)
+
+	s.groups.replace(cfg.Groups)
+	s.setAPITokens(cfg.APITokens)
+	s.sessions.setPassword(cfg.UIPasswordHash)
+	return nil
+}
+
+// restartRequired lists the config sections that differ between old and
+// cfg but only take effect on restart
+func restartRequired(old, cfg *config.Config) []string {
+	changed := []string{}
+	check := func(name string, a, b any) {
+		if !reflect.DeepEqual(a, b) {
+			changed = append(changed, name)
+		}
+	}
+	check("port", old.Port, cfg.Port)
+	check("tls", old.TLS, cfg.TLS)
+	check("turn", old.TURN, cfg.TURN)
+	check("turn_port", old.TURNPort, cfg.TURNPort)
+	check("interfaces", old.Interfaces, cfg.Interfaces)
+	check("crash_reports", old.CrashReports, cfg.CrashReports)
+	check("room_join_limits", old.RoomJoinLimits, cfg.RoomJoinLimits)
+	check("rate_limits", old.RateLimits, cfg.RateLimits)
+	check("max_file_size", old.MaxFileSize, cfg.MaxFileSize)
+	check("max_transfer_size", old.MaxTransferSize, cfg.MaxTransferSize)
+	check("rooms", old.Rooms, cfg.Rooms)
+	return changed
+}
+
+// handleReload re-reads the config file and applies what can be applied
+// without a restart
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+
+	cfg, err := config.Load()
+	if err != nil {
+		http.Error(w, "Failed to load config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if s.overrides != nil {
+		s.overrides(cfg)
+	}
+	if err := s.applyConfig(cfg); err != nil {
+		http.Error(w, "Invalid config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	pending := restartRequired(s.cfg, cfg)
+
+	// Remember what was applied; the rest still runs with the old values
+	applied := *s.cfg
+	applied.Access = cfg.Access
+	applied.TrustedProxies = cfg.TrustedProxies
+	applied.IPRooms = cfg.IPRooms
+	applied.ICEServers = cfg.ICEServers
+	applied.Groups = cfg.Groups
+	applied.APITokens = cfg.APITokens
+	applied.UIPasswordHash = cfg.UIPasswordHash
+	s.cfg = &applied
+
+	s.logger.Info("config reloaded", "restartRequired", pending)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.ReloadResult{
+		Applied:         reloadable,
+		RestartRequired: pending,
+	})
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"Peer-Drop/web"
+)
+
+// Server-rendered pages. Every page goes through html/template, so values
+// that came from peers or the request are escaped for the context they
+// land in; never build HTML with string concatenation or text/template.
+var pages = template.Must(template.ParseFS(web.Assets, "templates/*.html"))
+
+// pageData is what every page template gets
+type pageData struct {
+	Version string
+	Login   bool // a UI password is set, so offer to log out
+}
+
+// page returns the pageData for this server
+func (s *Server) page() pageData {
+	return pageData{Version: s.version, Login: s.sessions.enabled()}
+}
+
+// renderPage executes the named template into w with the given status. It
+// renders to a buffer first so a failing template yields a 500 instead of
+// half a page.
+func (s *Server) renderPage(w http.ResponseWriter, status int, name string, data any) {
+	var buf bytes.Buffer
+	if err := pages.ExecuteTemplate(&buf, name, data); err != nil {
+		s.logger.Error("render page", "page", name, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Names a sender could pick to attack the receiver's UI
+var hostileNames = []string{
+	`<script>alert(1)</script>.txt`,
+	`"><img src=x onerror=alert(1)>.png`,
+	`'; alert(1); '.pdf`,
+	"invoice\u202efdp.exe",
+	"\u2067isolated\u2069 & <b>bold</b>.doc",
+	"🎉 party <🎂>.zip",
+}
+
+func TestRenderPageEscapesText(t *testing.T) {
+	s := &Server{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	for _, name := range hostileNames {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.renderPage(w, http.StatusUnauthorized, "login.html", loginPage{Error: name})
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+			if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+			}
+
+			msg := between(w.Body.String(), `<p class="login-error">`, "</p>")
+			for _, raw := range []string{"<script", "<img", "<b>", "<🎂>", `"`, "'"} {
+				if strings.Contains(msg, raw) {
+					t.Errorf("unescaped %q in %q", raw, msg)
+				}
+			}
+			if strings.Contains(name, "🎉") && !strings.Contains(msg, "🎉") {
+				t.Errorf("emoji lost in %q", msg)
+			}
+		})
+	}
+}
+
+func TestRenderPageEscapesAttributes(t *testing.T) {
+	s := &Server{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	for _, name := range hostileNames {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.renderPage(w, http.StatusOK, "index.html", pageData{Version: name})
+
+			body := w.Body.String()
+			if strings.Count(body, "<script") != strings.Count(body, "</script>") {
+				t.Fatalf("script tags unbalanced")
+			}
+			for _, line := range strings.Split(body, "\n") {
+				if !strings.Contains(line, "?v=") {
+					continue
+				}
+				// The value lands in a URL query: everything but the
+				// safe characters is percent-encoded, bidi controls and
+				// emoji included
+				value := between(line, "?v=", `"`)
+				if strings.ContainsAny(value, "<>'& \u202e\u2067\u2069🎉") {
+					t.Errorf("unescaped value %q in %s", value, strings.TrimSpace(line))
+				}
+			}
+		})
+	}
+}
+
+// between returns the text of s after start and before the next end
+func between(s, start, end string) string {
+	_, after, _ := strings.Cut(s, start)
+	before, _, _ := strings.Cut(after, end)
+	return before
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Environment variable handing listening sockets to the restarted
+// process, as "addr=fd" pairs separated by ";"
+const listenFDsEnv = "PEERDROP_LISTEN_FDS"
+
+// handleRestart restarts the server in place: clients are disconnected
+// and reconnect to the new process, while connection attempts in between
+// wait on the kept listening sockets instead of being refused
+func (s *Server) handleRestart(w http.ResponseWriter, r *http.Request) {
+	if !restartSupported {
+		http.Error(w, "Restart is not supported on this platform", http.StatusNotImplemented)
+		return
+	}
+	select {
+	case s.restart <- struct{}{}:
+	default: // already restarting
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// listenInherited opens listeners like listen, but takes over the sockets
+// passed on by a restart where the address is the same. Inherited
+// sockets for addresses no longer configured are closed.
+func (s *Server) listenInherited() ([]net.Listener, error) {
+	inherited := inheritedListeners()
+	if len(inherited) == 0 {
+		return s.listen()
+	}
+	defer func() {
+		for _, ln := range inherited {
+			ln.Close()
+		}
+	}()
+
+	listeners := make([]net.Listener, 0, len(s.listenAddrs))
+	for _, addr := range s.listenAddrs {
+		if ln, ok := inherited[addr]; ok {
+			delete(inherited, addr)
+			listeners = append(listeners, ln)
+			continue
+		}
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// withoutEnv returns env minus any setting of name
+func withoutEnv(env []string, name string) []string {
+	out := env[:0:0]
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, name+"=") {
+			out = append(out, kv)
+		}
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+//go:build !windows
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const restartSupported = true
+
+// restartProcess shuts the server down and replaces the process with a
+// fresh start of the same executable, keeping its PID and handing over
+// the listening sockets. It only returns on failure.
+func (s *Server) restartProcess(listeners []net.Listener) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("restart: %w", err)
+	}
+
+	// Duplicates of the sockets, kept open across exec, outlive the
+	// listeners the shutdown closes
+	files := make([]*os.File, 0, len(listeners))
+	pairs := make([]string, 0, len(listeners))
+	for i, ln := range listeners {
+		tl, ok := ln.(*net.TCPListener)
+		if !ok {
+			continue
+		}
+		f, err := tl.File()
+		if err != nil {
+			return fmt.Errorf("restart: %w", err)
+		}
+		if _, err := unix.FcntlInt(f.Fd(), unix.F_SETFD, 0); err != nil {
+			return fmt.Errorf("restart: %w", err)
+		}
+		files = append(files, f)
+		pairs = append(pairs, fmt.Sprintf("%s=%d", s.listenAddrs[i], f.Fd()))
+	}
+
+	s.logger.Info("restarting", "executable", exe)
+	s.shutdown()
+
+	env := append(withoutEnv(os.Environ(), listenFDsEnv), listenFDsEnv+"="+strings.Join(pairs, ";"))
+	err = syscall.Exec(exe, os.Args, env)
+	for _, f := range files {
+		f.Close()
+	}
+	return fmt.Errorf("restart: %w", err)
+}
+
+// inheritedListeners takes the listening sockets passed on by a restart,
+// keyed by the address they were opened for
+func inheritedListeners() map[string]net.Listener {
+	spec := os.Getenv(listenFDsEnv)
+	if spec == "" {
+		return nil
+	}
+	os.Unsetenv(listenFDsEnv)
+
+	listeners := make(map[string]net.Listener)
+	for _, pair := range strings.Split(spec, ";") {
+		i := strings.LastIndex(pair, "=")
+		if i < 0 {
+			continue
+		}
+		fd, err := strconv.Atoi(pair[i+1:])
+		if err != nil {
+			continue
+		}
+		f := os.NewFile(uintptr(fd), pair[:i])
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			continue
+		}
+		listeners[pair[:i]] = ln
+	}
+	return listeners
+}
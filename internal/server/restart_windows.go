@@ -0,0 +1,17 @@
+package server
+
+import (
+	"errors"
+	"net"
+)
+
+// Sockets can't be kept across a restart here, and there is no exec
+const restartSupported = false
+
+func (s *Server) restartProcess(listeners []net.Listener) error {
+	return errors.New("restart is not supported on Windows")
+}
+
+func inheritedListeners() map[string]net.Listener {
+	return nil
+}
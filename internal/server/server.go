@@ -2,73 +2,454 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"Peer-Drop/internal/api"
+	"Peer-Drop/internal/config"
+	"Peer-Drop/internal/crash"
+	"Peer-Drop/internal/events"
+	"Peer-Drop/internal/logbuf"
+	"Peer-Drop/internal/paths"
 	"Peer-Drop/internal/signaling"
+	"Peer-Drop/internal/turn"
 	"Peer-Drop/web"
 )
 
 type Server struct {
 	httpServer *http.Server
 	hub        *signaling.Hub
+	events     *events.Bus
 	logger     *slog.Logger
 	port       int
+	version    string
+
+	// Set when serving HTTPS: the local CA that signed the certificate,
+	// and its fingerprint
+	tlsCA          *x509.Certificate
+	tlsFingerprint string
+
+	// Embedded STUN/TURN server, nil unless enabled
+	turn *turn.Server
+
+	// STUN/TURN servers from config, sent to every client
+	extraICEServers atomic.Pointer[[]signaling.ICEServer]
+
+	// Hashes of the tokens the API requires, empty when it is open, and
+	// of the ones among them that adminOnly accepts
+	apiTokens   atomic.Pointer[[]string]
+	adminTokens atomic.Pointer[[]string]
+
+	// UI password and the browser sessions logged in with it
+	sessions *sessionStore
+
+	// Per-IP cap on API requests
+	apiLimiter *apiLimiter
+
+	// Named peer groups, editable through /api/groups
+	groups *groupStore
+
+	// Recent stats samples for /api/admin/timeline
+	timeline *statsTimeline
+
+	// Addresses the HTTP server listens on, and the interfaces they serve
+	listenAddrs []string
+	interfaces  []NetInterface
+
+	// Captured log records for /api/admin/logs/stream, nil if not set
+	logs *logbuf.Buffer
+
+	// Closed when the HTTP server starts shutting down, to end streams
+	stopping chan struct{}
+
+	// Config as applied, updated by /api/admin/reload
+	cfg   *config.Config
+	cfgMu sync.Mutex
+
+	// Command-line overrides reapplied to a reloaded config
+	overrides func(*config.Config)
+
+	// Signalled by /api/admin/restart
+	restart chan struct{}
+
+	hooks   []shutdownHook
+	hooksMu sync.Mutex
 }
 
-func New(port int, logger *slog.Logger) *Server {
-	hub := signaling.NewHub(logger)
+func New(cfg *config.Config, version string, logger *slog.Logger) (*Server, error) {
+	port := cfg.Port
+	bus := events.New()
+	hub := signaling.NewHub(logger.With("component", "signaling"), bus)
+
+	hub.SetJoinLimits(joinLimits(cfg.RoomJoinLimits))
+	hub.SetRateLimits(rateLimits(cfg.RateLimits))
+	hub.SetTransferLimits(signaling.TransferLimits{
+		MaxFileSize:     cfg.MaxFileSize,
+		MaxTransferSize: cfg.MaxTransferSize,
+	})
+
+	roomOpts := roomOptions(cfg.Rooms)
+	if err := roomOpts.Validate(); err != nil {
+		return nil, fmt.Errorf("rooms: %w", err)
+	}
+	hub.SetRoomOptions(roomOpts)
+	if cfg.Rooms.Persistent {
+		if err := hub.SetRoomStore(filepath.Join(paths.StateDir(), "rooms.json")); err != nil {
+			return nil, fmt.Errorf("load persistent rooms: %w", err)
+		}
+	}
+
+	listenAddrs, interfaces, err := resolveInterfaces(cfg.Interfaces, port)
+	if err != nil {
+		return nil, fmt.Errorf("interfaces: %w", err)
+	}
 
 	s := &Server{
-		hub:    hub,
-		logger: logger,
-		port:   port,
+		hub:         hub,
+		events:      bus,
+		logger:      logger,
+		port:        port,
+		version:     version,
+		groups:      newGroupStore(nil),
+		sessions:    newSessionStore(),
+		apiLimiter:  newAPILimiter(limitOrDefault(cfg.RateLimits.APIRequestsPerMinute, defaultAPIRequestsPerMinute)),
+		timeline:    newStatsTimeline(timelineSize),
+		listenAddrs: listenAddrs,
+		interfaces:  interfaces,
+		stopping:    make(chan struct{}),
+		restart:     make(chan struct{}, 1),
+		cfg:         cfg,
+	}
+	if err := s.applyConfig(cfg); err != nil {
+		return nil, err
 	}
 
 	mux := http.NewServeMux()
 	s.setupRoutes(mux)
 
+	handler := corsMiddleware(logMiddleware(s.rateLimitMiddleware(s.authMiddleware(mux)), logger))
+	if cfg.CrashReports.Enabled {
+		reporter := crash.New(crash.Options{
+			Dir:      filepath.Join(paths.StateDir(), "crashes"),
+			Endpoint: cfg.CrashReports.Endpoint,
+			Secret:   cfg.CrashReports.Secret,
+			Version:  version,
+		}, logger)
+		hub.SetPanicHandler(reporter.Report)
+		handler = recoverMiddleware(handler, reporter)
+	}
+
 	s.httpServer = &http.Server{
-		Addr:        fmt.Sprintf(":%d", port),
-		Handler:     corsMiddleware(logMiddleware(mux, logger)),
+		Handler:     handler,
 		ReadTimeout: 30 * time.Second,
 		IdleTimeout: 120 * time.Second,
 	}
+	s.httpServer.RegisterOnShutdown(func() { close(s.stopping) })
+
+	if cfg.TLS {
+		cert, ca, err := loadOrCreateCertificate(tlsDir())
+		if err != nil {
+			return nil, err
+		}
+		s.httpServer.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+		s.tlsCA = ca
+		s.tlsFingerprint = certificateFingerprint(ca.Raw)
+	}
+
+	if cfg.TURN {
+		var turnIPs []net.IP
+		if len(cfg.Interfaces) > 0 {
+			turnIPs = ipv4Addresses(interfaces)
+			if len(turnIPs) == 0 {
+				return nil, errors.New("start TURN server: no IPv4 address on the selected interfaces")
+			}
+		}
+		turnServer, err := turn.Start(cfg.TURNPort, nil, turnIPs, logger)
+		if err != nil {
+			return nil, fmt.Errorf("start TURN server: %w", err)
+		}
+		s.turn = turnServer
+	}
+	hub.SetICEServerProvider(s.iceServers)
 
 	// Start room cleanup
 	hub.StartCleanup(5 * time.Minute)
 
-	return s
+	sampleCtx, stopSampling := context.WithCancel(context.Background())
+	go s.sampleStats(sampleCtx)
+
+	s.OnShutdown("stop stats sampling", time.Second, func(context.Context) error {
+		stopSampling()
+		return nil
+	})
+
+	sweepCtx, stopSweeping := context.WithCancel(context.Background())
+	go s.sessions.sweepEvery(sweepCtx, loginWindow)
+
+	s.OnShutdown("stop session sweeps", time.Second, func(context.Context) error {
+		stopSweeping()
+		return nil
+	})
+	s.OnShutdown("close hub clients", 5*time.Second, hub.Close)
+	s.OnShutdown("close event bus", time.Second, func(context.Context) error {
+		bus.Close()
+		return nil
+	})
+	if s.turn != nil {
+		s.OnShutdown("stop TURN server", 2*time.Second, func(context.Context) error {
+			return s.turn.Close()
+		})
+	}
+
+	return s, nil
+}
+
+// Events returns the bus carrying hub lifecycle and transfer events, also
+// streamed at /api/admin/events/stream
+func (s *Server) Events() *events.Bus {
+	return s.events
+}
+
+// TLSFingerprint returns the SHA-256 fingerprint of the local CA that
+// signs the server certificate, or "" when serving plain HTTP. It stays
+// the same when the certificate is reissued.
+func (s *Server) TLSFingerprint() string {
+	return s.tlsFingerprint
+}
+
+// TURNPort returns the UDP port of the embedded TURN server, or 0 when it
+// is disabled
+func (s *Server) TURNPort() int {
+	if s.turn == nil {
+		return 0
+	}
+	return s.turn.Port()
+}
+
+// iceServers lists the STUN/TURN servers for a client: the embedded server
+// first, reached on the same host as the web UI, then any from config
+func (s *Server) iceServers(host, clientID string) []signaling.ICEServer {
+	var servers []signaling.ICEServer
+
+	if s.turn != nil {
+		creds, err := s.turn.Credentials(clientID)
+		if err != nil {
+			s.logger.Warn("failed to issue TURN credentials", "clientID", clientID, "error", err)
+		} else {
+			stunURL, turnURL := s.turn.URLs(host)
+			servers = append(servers,
+				signaling.ICEServer{URLs: []string{stunURL}},
+				signaling.ICEServer{URLs: []string{turnURL}, Username: creds.Username, Credential: creds.Password},
+			)
+		}
+	}
+
+	return append(servers, *s.extraICEServers.Load()...)
 }
 
 func (s *Server) setupRoutes(mux *http.ServeMux) {
 	// WebSocket endpoint
 	mux.HandleFunc("GET /ws", s.hub.HandleWebSocket)
 
+	// HTTP signaling fallback for networks that block WebSocket
+	mux.HandleFunc("GET /signal/stream", s.hub.HandleSignalStream)
+	mux.HandleFunc("POST /signal/send", s.hub.HandleSignalSend)
+
 	// API routes
 	mux.HandleFunc("GET /api/stats", s.handleStats)
+	mux.HandleFunc("GET /api/version", s.handleVersion)
+	mux.HandleFunc("GET /api/cert", s.handleCert)
+	mux.HandleFunc("GET /api/info", s.handleInfo)
+	mux.HandleFunc("GET /api/groups", s.handleListGroups)
+	// Changing groups rewrites config.json, so it is gated like the admin API
+	mux.HandleFunc("PUT /api/groups/{name}", s.adminOnly(s.handlePutGroup))
+	mux.HandleFunc("DELETE /api/groups/{name}", s.adminOnly(s.handleDeleteGroup))
+
+	// Admin API, local requests or admin tokens only
+	mux.HandleFunc("GET /api/admin/stats", s.adminOnly(s.handleAdminStats))
+	mux.HandleFunc("GET /api/admin/timeline", s.adminOnly(s.handleTimeline))
+	mux.HandleFunc("GET /api/admin/clients", s.adminOnly(s.handleListClients))
+	mux.HandleFunc("DELETE /api/admin/clients/{id}", s.adminOnly(s.handleKickClient))
+	mux.HandleFunc("GET /api/admin/rooms", s.adminOnly(s.handleListRooms))
+	mux.HandleFunc("DELETE /api/admin/rooms/{code}", s.adminOnly(s.handleCloseRoom))
+	mux.HandleFunc("GET /api/admin/logs/stream", s.adminOnly(s.handleLogStream))
+	mux.HandleFunc("GET /api/admin/events/stream", s.adminOnly(s.handleEventStream))
+	mux.HandleFunc("POST /api/admin/reload", s.adminOnly(s.handleReload))
+	mux.HandleFunc("POST /api/admin/restart", s.adminOnly(s.handleRestart))
 
 	// Static files and web UI
 	mux.Handle("GET /static/", http.FileServer(http.FS(web.Assets)))
 	mux.HandleFunc("GET /", s.handleIndex)
+	mux.HandleFunc("GET /login", s.handleLoginPage)
+	mux.HandleFunc("POST /login", s.handleLogin)
+	mux.HandleFunc("POST /logout", s.handleLogout)
 }
 
-func (s *Server) Start() error {
-	s.logger.Info("starting HTTP server", "addr", s.httpServer.Addr)
-	return s.httpServer.ListenAndServe()
+// Run serves HTTP until ctx is cancelled, then runs the shutdown hooks.
+// It returns early with an error if the listener fails. A restart
+// requested through the admin API replaces the process and doesn't
+// return unless it fails.
+func (s *Server) Run(ctx context.Context) error {
+	listeners, err := s.listenInherited()
+	if err != nil {
+		return err
+	}
+
+	errCh := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		if s.tlsFingerprint != "" {
+			s.logger.Info("starting HTTPS server", "addr", ln.Addr().String(), "fingerprint", s.tlsFingerprint)
+		} else {
+			s.logger.Info("starting HTTP server", "addr", ln.Addr().String())
+		}
+		go func() {
+			if err := s.serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- err
+			}
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-s.restart:
+		return s.restartProcess(listeners)
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("shutting down...")
+	s.shutdown()
+	return nil
 }
 
-func (s *Server) Shutdown(ctx context.Context) error {
-	return s.httpServer.Shutdown(ctx)
+// joinLimits fills in the configured room join limits over the defaults
+func joinLimits(c config.RoomJoinLimitsConfig) signaling.JoinLimits {
+	l := signaling.DefaultJoinLimits
+	if c.ClientFailures > 0 {
+		l.ClientFailures = c.ClientFailures
+	}
+	if c.IPFailures > 0 {
+		l.IPFailures = c.IPFailures
+	}
+	if c.WindowSeconds > 0 {
+		l.Window = time.Duration(c.WindowSeconds) * time.Second
+	}
+	if c.LockoutSeconds > 0 {
+		l.Lockout = time.Duration(c.LockoutSeconds) * time.Second
+	}
+	return l
 }
 
-func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+// rateLimits fills in the configured per-IP limits over the defaults
+func rateLimits(c config.RateLimitsConfig) signaling.RateLimits {
+	return signaling.RateLimits{
+		ConnectionsPerIP:          limitOrDefault(c.ConnectionsPerIP, signaling.DefaultRateLimits.ConnectionsPerIP),
+		TransferRequestsPerMinute: limitOrDefault(c.TransferRequestsPerMinute, signaling.DefaultRateLimits.TransferRequestsPerMinute),
+	}
+}
+
+// limitOrDefault reads a configured limit: 0 keeps def and a negative
+// value means no limit, which limiters take as 0
+func limitOrDefault(v, def int) int {
+	switch {
+	case v > 0:
+		return v
+	case v < 0:
+		return 0
+	}
+	return def
+}
+
+// ipGrouping fills in the configured IP room grouping over the defaults
+func ipGrouping(c config.IPRoomsConfig) signaling.IPGrouping {
+	g := signaling.DefaultIPGrouping
+	if c.Mode != "" {
+		g.Mode = strings.ToLower(c.Mode)
+	}
+	if c.IPv4Prefix != 0 {
+		g.IPv4Prefix = c.IPv4Prefix
+	}
+	if c.IPv6Prefix != 0 {
+		g.IPv6Prefix = c.IPv6Prefix
+	}
+	g.Header = c.Header
+	return g
+}
+
+// roomOptions fills in the configured room options over the defaults
+func roomOptions(c config.RoomsConfig) signaling.RoomOptions {
+	o := signaling.DefaultRoomOptions
+	if c.CodeLength > 0 {
+		o.CodeLength = c.CodeLength
+	}
+	if c.CodeCharset != "" {
+		o.CodeCharset = strings.ToUpper(c.CodeCharset)
+	}
+	if c.AliasTTLMinutes > 0 {
+		o.AliasTTL = time.Duration(c.AliasTTLMinutes) * time.Minute
+	}
+	o.Limits = signaling.RoomLimits{
+		MaxPeers:    c.MaxPeers,
+		Lifetime:    time.Duration(c.LifetimeMinutes) * time.Minute,
+		IdleTimeout: time.Duration(c.IdleMinutes) * time.Minute,
+	}
+	o.ChatHistory = c.ChatHistory
+	return o
+}
+
+// stats combines the hub counters with those of the TURN server
+func (s *Server) stats() map[string]int {
 	stats := s.hub.Stats()
+	if s.turn != nil {
+		stats["turn_allocations"] = s.turn.AllocationCount()
+	}
+	return stats
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.stats())
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.Version{
+		Version:            s.version,
+		ProtocolVersion:    signaling.ProtocolVersion,
+		MinProtocolVersion: signaling.MinProtocolVersion,
+		Capabilities:       s.hub.Capabilities(),
+	})
+}
+
+// handleInfo describes this server: its version and where it can be reached
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	interfaces := s.interfaces
+	if interfaces == nil {
+		interfaces = []NetInterface{}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
+	json.NewEncoder(w).Encode(api.Info{
+		Version:    s.version,
+		Port:       s.port,
+		TLS:        s.tlsFingerprint != "",
+		Interfaces: interfaces,
+		URLs:       s.URLs(),
+	})
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -77,18 +458,19 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := web.Assets.ReadFile("templates/index.html")
-	if err != nil {
-		http.Error(w, "Template not found", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Write(data)
+	s.renderPage(w, http.StatusOK, "index.html", s.page())
 }
 
+// corsMiddleware lets other origins use the public API. The admin API is
+// left out: browsers get no CORS headers for it and preflights fall
+// through to the mux, which refuses them.
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
@@ -102,6 +484,25 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// recoverMiddleware reports panics from handlers and answers with a 500
+// instead of letting net/http drop the connection
+func recoverMiddleware(next http.Handler, reporter *crash.Reporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			reporter.Report("http "+r.Method+" "+r.URL.Path, v, debug.Stack())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
 func logMiddleware(next http.Handler, logger *slog.Logger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"Peer-Drop/internal/passhash"
+)
+
+const (
+	// Cookie holding the session of a browser that entered the UI password
+	sessionCookie = "peerdrop_session"
+
+	// How long a login lasts without being renewed
+	sessionLifetime = 7 * 24 * time.Hour
+
+	// Failed logins allowed per IP within loginWindow before it has to
+	// wait for the window to end
+	loginFailures = 5
+	loginWindow   = time.Minute
+
+	// Passwords checked at once per IP and in all. Each check is a full
+	// PBKDF2 run, so a login past these is turned away rather than queued.
+	maxVerifiesPerIP = 1
+	maxVerifies      = 4
+	verifyBusyRetry  = time.Second
+)
+
+// sessionStore holds the UI password and the sessions logged in with it.
+// Sessions live in memory only, so a restart logs everyone out.
+type sessionStore struct {
+	mu       sync.Mutex
+	hash     string // ui_password_hash, "" when unset
+	sessions map[string]time.Time
+	failures map[string]*loginAttempts // by IP
+
+	verifying int // passwords being checked
+}
+
+// loginAttempts counts one IP's logins in the current window. An attempt
+// counts from the moment it starts, so parallel guesses can't all get in
+// before the first one fails; it is taken back if the password was right.
+type loginAttempts struct {
+	windowStart time.Time
+	count       int
+	verifying   int
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{
+		sessions: make(map[string]time.Time),
+		failures: make(map[string]*loginAttempts),
+	}
+}
+
+// setPassword changes the UI password to the one hash was made from.
+// Existing sessions end when it changes, so removing a device's access is
+// a matter of a new password.
+func (st *sessionStore) setPassword(hash string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if hash != st.hash {
+		clear(st.sessions)
+	}
+	st.hash = hash
+}
+
+// enabled reports whether a password is set
+func (st *sessionStore) enabled() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.hash != ""
+}
+
+// valid reports whether r carries a live session
+func (st *sessionStore) valid(r *http.Request) bool {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	expires, ok := st.sessions[c.Value]
+	if ok && time.Now().After(expires) {
+		delete(st.sessions, c.Value)
+		return false
+	}
+	return ok
+}
+
+// login checks password for ip and returns a new session ID. retry is set
+// when ip has failed too often, or too many checks are running, and it
+// must wait.
+func (st *sessionStore) login(ip, password string) (id string, retry time.Duration) {
+	st.mu.Lock()
+	now := time.Now()
+	a := st.failures[ip]
+	if a == nil {
+		a = &loginAttempts{windowStart: now}
+		st.failures[ip] = a
+	} else if now.Sub(a.windowStart) >= loginWindow {
+		a.windowStart, a.count = now, 0
+	}
+	if a.count >= loginFailures {
+		st.mu.Unlock()
+		return "", a.windowStart.Add(loginWindow).Sub(now)
+	}
+	if a.verifying >= maxVerifiesPerIP || st.verifying >= maxVerifies {
+		st.mu.Unlock()
+		return "", verifyBusyRetry
+	}
+	a.count++
+	a.verifying++
+	st.verifying++
+	hash := st.hash
+	st.mu.Unlock()
+
+	// The hash is slow on purpose, so it is checked without the lock
+	ok := hash != "" && passhash.Verify(password, hash)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	a.verifying--
+	st.verifying--
+	if !ok {
+		return "", 0
+	}
+	if a.verifying == 0 {
+		delete(st.failures, ip)
+	} else {
+		a.count--
+	}
+	if hash != st.hash {
+		// The password changed while this one was checked
+		return "", 0
+	}
+
+	b := make([]byte, 32)
+	rand.Read(b)
+	id = hex.EncodeToString(b)
+	st.sessions[id] = now.Add(sessionLifetime)
+	return id, 0
+}
+
+// logout ends the session of r, if any
+func (st *sessionStore) logout(r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		st.mu.Lock()
+		delete(st.sessions, c.Value)
+		st.mu.Unlock()
+	}
+}
+
+// sweepEvery drops expired sessions and finished failure windows every
+// interval until ctx is done
+func (st *sessionStore) sweepEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			st.mu.Lock()
+			st.sweep(now)
+			st.mu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sweep drops expired sessions and finished failure windows. Called with
+// st.mu held.
+func (st *sessionStore) sweep(now time.Time) {
+	for id, expires := range st.sessions {
+		if now.After(expires) {
+			delete(st.sessions, id)
+		}
+	}
+	for ip, a := range st.failures {
+		if now.Sub(a.windowStart) >= loginWindow && a.verifying == 0 {
+			delete(st.failures, ip)
+		}
+	}
+}
+
+// loginPage is the data of templates/login.html
+type loginPage struct {
+	pageData
+	Error string
+}
+
+// handleLoginPage shows the password form
+func (s *Server) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	if !s.sessions.enabled() {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	s.renderPage(w, http.StatusOK, "login.html", loginPage{pageData: s.page()})
+}
+
+// handleLogin checks the posted password and starts a session
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !s.sessions.enabled() {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Behind a trusted proxy every login comes from the proxy, so
+	// throttling by socket address would lock everyone out at once
+	ip := s.hub.ClientIP(r)
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	id, retry := s.sessions.login(ip, r.PostFormValue("password"))
+	if id == "" {
+		page := loginPage{pageData: s.page(), Error: "Wrong password."}
+		status := http.StatusUnauthorized
+		if retry > 0 {
+			page.Error = "Too many attempts. Try again in a minute."
+			status = http.StatusTooManyRequests
+			w.Header().Set("Retry-After", retryAfter(retry))
+		}
+		s.logger.Warn("failed UI login", "remote", ip, "throttled", retry > 0)
+		s.renderPage(w, status, "login.html", page)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(sessionLifetime / time.Second),
+		HttpOnly: true,
+		Secure:   s.tlsFingerprint != "",
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// handleLogout ends the session and returns to the login page
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	s.sessions.logout(r)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.tlsFingerprint != "",
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// retryAfter formats d as whole seconds for a Retry-After header
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(int((d + time.Second - 1) / time.Second))
+}
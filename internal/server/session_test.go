@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"Peer-Drop/internal/passhash"
+)
+
+const testIP = "192.168.1.20"
+
+func newTestSessionStore(t *testing.T) *sessionStore {
+	t.Helper()
+	hash, err := passhash.Hash("right")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := newSessionStore()
+	st.setPassword(hash)
+	return st
+}
+
+func TestLoginThrottle(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		setup    func(st *sessionStore)
+		wantID   bool
+		minRetry time.Duration
+		maxRetry time.Duration
+	}{
+		{
+			name:     "wrong password",
+			password: "wrong",
+		},
+		{
+			name:     "right password",
+			password: "right",
+			wantID:   true,
+		},
+		{
+			name:     "check running for the IP",
+			password: "right",
+			setup: func(st *sessionStore) {
+				st.failures[testIP] = &loginAttempts{windowStart: time.Now(), count: 1, verifying: 1}
+				st.verifying = 1
+			},
+			minRetry: verifyBusyRetry,
+			maxRetry: verifyBusyRetry,
+		},
+		{
+			name:     "checks running for other IPs",
+			password: "right",
+			setup:    func(st *sessionStore) { st.verifying = maxVerifies },
+			minRetry: verifyBusyRetry,
+			maxRetry: verifyBusyRetry,
+		},
+		{
+			name:     "failures used up",
+			password: "right",
+			setup: func(st *sessionStore) {
+				st.failures[testIP] = &loginAttempts{windowStart: time.Now(), count: loginFailures}
+			},
+			minRetry: loginWindow - time.Second,
+			maxRetry: loginWindow,
+		},
+		{
+			name:     "window passed",
+			password: "right",
+			setup: func(st *sessionStore) {
+				st.failures[testIP] = &loginAttempts{windowStart: time.Now().Add(-loginWindow), count: loginFailures}
+			},
+			wantID: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newTestSessionStore(t)
+			if tt.setup != nil {
+				tt.setup(st)
+			}
+
+			id, retry := st.login(testIP, tt.password)
+			if (id != "") != tt.wantID {
+				t.Errorf("id = %q, want one: %v", id, tt.wantID)
+			}
+			if retry < tt.minRetry || retry > tt.maxRetry {
+				t.Errorf("retry = %v, want %v to %v", retry, tt.minRetry, tt.maxRetry)
+			}
+		})
+	}
+}
+
+func TestLoginConcurrentGuesses(t *testing.T) {
+	tests := []struct {
+		name    string
+		ips     int
+		guesses int
+	}{
+		{name: "one IP", ips: 1, guesses: 3 * loginFailures},
+		{name: "many IPs", ips: 3 * maxVerifies, guesses: 3 * maxVerifies},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newTestSessionStore(t)
+
+			var (
+				wg      sync.WaitGroup
+				mu      sync.Mutex
+				checked = make(map[string]int) // guesses that got a full check, by IP
+			)
+			start := make(chan struct{})
+			for i := range tt.guesses {
+				ip := fmt.Sprintf("10.0.0.%d", i%tt.ips+1)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					id, retry := st.login(ip, "wrong")
+					if id != "" {
+						t.Errorf("wrong password logged in")
+					}
+					if retry == 0 {
+						mu.Lock()
+						checked[ip]++
+						mu.Unlock()
+					}
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			for ip, n := range checked {
+				if n > loginFailures {
+					t.Errorf("%s: %d guesses checked, want at most %d", ip, n, loginFailures)
+				}
+			}
+			if len(checked) == 0 {
+				t.Errorf("no guess was checked")
+			}
+			if st.verifying != 0 {
+				t.Errorf("verifying = %d after all logins returned", st.verifying)
+			}
+			for ip, a := range st.failures {
+				if a.verifying != 0 {
+					t.Errorf("%s: verifying = %d after all logins returned", ip, a.verifying)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// Default time allowed for a single shutdown hook
+const defaultHookTimeout = 5 * time.Second
+
+// shutdownHook is a named step run when the server stops
+type shutdownHook struct {
+	name    string
+	timeout time.Duration
+	fn      func(ctx context.Context) error
+}
+
+// OnShutdown registers a hook that runs when the server shuts down.
+// Hooks run one at a time in registration order, each bounded by its own
+// timeout, and always before the HTTP server itself is stopped.
+func (s *Server) OnShutdown(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.hooks = append(s.hooks, shutdownHook{name: name, timeout: timeout, fn: fn})
+}
+
+// shutdown runs all registered hooks followed by the HTTP server shutdown
+func (s *Server) shutdown() {
+	s.hooksMu.Lock()
+	hooks := append([]shutdownHook(nil), s.hooks...)
+	s.hooksMu.Unlock()
+
+	hooks = append(hooks, shutdownHook{
+		name:    "stop http",
+		timeout: 10 * time.Second,
+		fn:      s.httpServer.Shutdown,
+	})
+
+	for _, hook := range hooks {
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), hook.timeout)
+		err := hook.fn(ctx)
+		cancel()
+
+		if err != nil {
+			s.logger.Warn("shutdown hook failed", "hook", hook.name, "error", err, "duration", time.Since(start))
+			continue
+		}
+		s.logger.Debug("shutdown hook done", "hook", hook.name, "duration", time.Since(start))
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// How often stats are sampled for the timeline
+	timelineInterval = 10 * time.Second
+
+	// Samples kept: one hour at timelineInterval
+	timelineSize = int(time.Hour / timelineInterval)
+)
+
+// statsSample is one snapshot of the server stats
+type statsSample struct {
+	Time  time.Time      `json:"time"`
+	Stats map[string]int `json:"stats"`
+}
+
+// statsTimeline is a fixed-size ring of recent stats samples
+type statsTimeline struct {
+	mu      sync.Mutex
+	samples []statsSample
+	next    int
+}
+
+func newStatsTimeline(size int) *statsTimeline {
+	return &statsTimeline{samples: make([]statsSample, 0, size)}
+}
+
+// add records a sample, overwriting the oldest once the ring is full
+func (t *statsTimeline) add(sample statsSample) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.samples) < cap(t.samples) {
+		t.samples = append(t.samples, sample)
+		return
+	}
+	t.samples[t.next] = sample
+	t.next = (t.next + 1) % len(t.samples)
+}
+
+// snapshot returns the samples oldest first
+func (t *statsTimeline) snapshot() []statsSample {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]statsSample, 0, len(t.samples))
+	out = append(out, t.samples[t.next:]...)
+	return append(out, t.samples[:t.next]...)
+}
+
+// sampleStats records the server stats every timelineInterval until ctx is
+// cancelled
+func (s *Server) sampleStats(ctx context.Context) {
+	ticker := time.NewTicker(timelineInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.timeline.add(statsSample{Time: now.UTC(), Stats: s.stats()})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Server) handleTimeline(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"interval": int(timelineInterval / time.Second),
+		"samples":  s.timeline.snapshot(),
+	})
+}
@@ -0,0 +1,375 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"Peer-Drop/internal/config"
+)
+
+const (
+	// Lifetime of generated server certificates
+	certValidity = 365 * 24 * time.Hour
+
+	// Regenerate certificates this long before they expire
+	certRenewBefore = 30 * 24 * time.Hour
+
+	// Lifetime of the local certificate authority. Devices trust it once,
+	// so it outlives many server certificates.
+	caValidity = 10 * 365 * 24 * time.Hour
+)
+
+// Address ranges the local CA may issue for: loopback, private, shared
+// (CGNAT, used by some VPNs) and link-local, plus the machine's own IPv4
+// addresses when it is created. A device that trusts the CA can't be
+// fooled by it about other public addresses, whoever gets its key.
+var caPermittedRanges = []string{
+	"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16",
+	"100.64.0.0/10", "169.254.0.0/16",
+	"::1/128", "fc00::/7", "fe80::/10",
+}
+
+// tlsDir is where the certificates and keys are kept
+func tlsDir() string {
+	return filepath.Join(config.Dir(), "tls")
+}
+
+// CAFile returns the path of the local certificate authority's
+// certificate, creating the authority if there is none yet. It is what
+// devices install to trust the server.
+func CAFile() (string, error) {
+	if _, _, err := loadOrCreateCA(tlsDir()); err != nil {
+		return "", err
+	}
+	return filepath.Join(tlsDir(), "ca.pem"), nil
+}
+
+// loadOrCreateCA returns the local certificate authority in dir that
+// signs the server certificate, creating one when none exists, it is
+// about to expire or it can't issue for the current hostname and IPv4
+// addresses. A new one has to be trusted again on every device, which on
+// a private network only a hostname change brings.
+func loadOrCreateCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath := filepath.Join(dir, "ca.pem")
+	keyPath := filepath.Join(dir, "ca-key.pem")
+
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err == nil {
+		ca, parseErr := x509.ParseCertificate(pair.Certificate[0])
+		key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+		if parseErr == nil && ok && caUsable(ca) {
+			return ca, key, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("load certificate authority: %w", err)
+	}
+
+	certPEM, keyPEM, err := generateCA()
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate certificate authority: %w", err)
+	}
+	if err := writeKeyPair(dir, certPath, keyPath, certPEM, keyPEM); err != nil {
+		return nil, nil, err
+	}
+
+	pair, err = tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return ca, pair.PrivateKey.(*ecdsa.PrivateKey), nil
+}
+
+// caUsable reports whether a cached CA is still valid and may issue for
+// every current host name and IPv4 address
+func caUsable(ca *x509.Certificate) bool {
+	if !ca.IsCA || time.Now().Add(certRenewBefore).After(ca.NotAfter) {
+		return false
+	}
+	hosts, ips := certificateNames()
+	for _, host := range hosts {
+		if !slices.ContainsFunc(ca.PermittedDNSDomains, func(d string) bool {
+			return host == d || strings.HasSuffix(host, "."+d)
+		}) {
+			return false
+		}
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil && !caMayIssue(ca, ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadOrCreateCertificate returns the cached server certificate in dir,
+// generating a new one when none exists, it is about to expire, it wasn't
+// signed by the current CA, or it doesn't cover the machine's current
+// hostname and IPv4 addresses. A new certificate keeps the cached key, so
+// clients that pin the key still recognize the server. The CA goes along
+// in the chain.
+func loadOrCreateCertificate(dir string) (cert tls.Certificate, ca *x509.Certificate, err error) {
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	ca, caKey, err := loadOrCreateCA(dir)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	hosts, ips := certificateNames()
+	ips = slices.DeleteFunc(ips, func(ip net.IP) bool { return !caMayIssue(ca, ip) })
+
+	cert, err = tls.LoadX509KeyPair(certPath, keyPath)
+	if err == nil && certificateUsable(cert, ca, hosts, ips) {
+		return cert, ca, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return tls.Certificate{}, nil, fmt.Errorf("load certificate: %w", err)
+	}
+
+	var key *ecdsa.PrivateKey
+	if err == nil {
+		key, _ = cert.PrivateKey.(*ecdsa.PrivateKey)
+	}
+	certPEM, keyPEM, err := generateCertificate(hosts, ips, key, ca, caKey)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("generate certificate: %w", err)
+	}
+	if err := writeKeyPair(dir, certPath, keyPath, certPEM, keyPEM); err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	cert, err = tls.X509KeyPair(certPEM, keyPEM)
+	return cert, ca, err
+}
+
+// writeKeyPair saves a certificate and its key, the key owner-only
+func writeKeyPair(dir, certPath, keyPath string, certPEM, keyPEM []byte) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(keyPath, keyPEM, 0600)
+}
+
+// certificateUsable reports whether a cached certificate was signed by ca,
+// is still valid and names every current host and IPv4 address the CA
+// may issue for. IPv6 addresses don't count: temporary addresses change
+// every day or so, and a reissue for each would be wasted work. They are
+// still listed whenever a certificate is issued.
+func certificateUsable(cert tls.Certificate, ca *x509.Certificate, hosts []string, ips []net.IP) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return false
+	}
+	if leaf.CheckSignatureFrom(ca) != nil {
+		return false
+	}
+	if time.Now().Add(certRenewBefore).After(leaf.NotAfter) {
+		return false
+	}
+
+	for _, host := range hosts {
+		if !slices.Contains(leaf.DNSNames, host) {
+			return false
+		}
+	}
+	for _, ip := range ips {
+		if ip.To4() == nil && !ip.IsLoopback() {
+			continue
+		}
+		if !slices.ContainsFunc(leaf.IPAddresses, ip.Equal) {
+			return false
+		}
+	}
+	return true
+}
+
+// certificateNames lists the DNS names and IPs the certificate should cover
+func certificateNames() ([]string, []net.IP) {
+	hosts := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		hostname = strings.ToLower(hostname)
+		hosts = append(hosts, hostname)
+		if !strings.HasSuffix(hostname, ".local") {
+			hosts = append(hosts, hostname+".local")
+		}
+	}
+
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	addrs, _ := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		ips = append(ips, ipNet.IP)
+	}
+
+	return hosts, ips
+}
+
+// caMayIssue reports whether ca's name constraints allow ip. Other
+// addresses, such as public IPv6 ones, are left out of the certificate.
+func caMayIssue(ca *x509.Certificate, ip net.IP) bool {
+	return slices.ContainsFunc(ca.PermittedIPRanges, func(n *net.IPNet) bool { return n.Contains(ip) })
+}
+
+// permittedRanges returns caPermittedRanges and each of ips that is an
+// IPv4 address outside them
+func permittedRanges(ips []net.IP) []*net.IPNet {
+	var ranges []*net.IPNet
+	for _, cidr := range caPermittedRanges {
+		_, n, _ := net.ParseCIDR(cidr)
+		ranges = append(ranges, n)
+	}
+	for _, ip := range ips {
+		ip4 := ip.To4()
+		if ip4 != nil && !slices.ContainsFunc(ranges, func(n *net.IPNet) bool { return n.Contains(ip4) }) {
+			ranges = append(ranges, &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)})
+		}
+	}
+	return ranges
+}
+
+// generateCA creates the local certificate authority and its key in PEM
+// form. It is limited by name constraints to this machine's names and to
+// local addresses, so trusting it trusts nothing else.
+func generateCA() (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hosts, ips := certificateNames()
+	name := hosts[len(hosts)-1]
+
+	// "localhost" and "local" cover the first and last names; the bare
+	// hostname needs its own entry
+	domains := []string{"localhost", "local"}
+	if len(hosts) > 1 && !strings.HasSuffix(hosts[1], ".local") {
+		domains = append(domains, hosts[1])
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:                serial,
+		Subject:                     pkix.Name{Organization: []string{"Peer-Drop"}, CommonName: "Peer-Drop local CA (" + name + ")"},
+		NotBefore:                   now.Add(-time.Hour),
+		NotAfter:                    now.Add(caValidity),
+		KeyUsage:                    x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid:       true,
+		IsCA:                        true,
+		MaxPathLenZero:              true,
+		PermittedDNSDomainsCritical: true,
+		PermittedDNSDomains:         domains,
+		PermittedIPRanges:           permittedRanges(ips),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeKeyPair(der, key)
+}
+
+// generateCertificate creates a server certificate for key, or for a new
+// key when it is nil, signed by ca. It returns the certificate followed
+// by the CA's, and the key, in PEM form.
+func generateCertificate(hosts []string, ips []net.IP, key *ecdsa.PrivateKey, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (certPEM, keyPEM []byte, err error) {
+	if key == nil {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Peer-Drop"}, CommonName: hosts[len(hosts)-1]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              hosts,
+		IPAddresses:           ips,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM, keyPEM, err = encodeKeyPair(der, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})...)
+	return certPEM, keyPEM, nil
+}
+
+// encodeKeyPair returns a DER certificate and its key in PEM form
+func encodeKeyPair(der []byte, key *ecdsa.PrivateKey) (certPEM, keyPEM []byte, err error) {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// certificateFingerprint returns the SHA-256 fingerprint of a DER
+// certificate as colon-separated hex, the format browsers display
+func certificateFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// handleCert serves the local CA's certificate for devices to install,
+// after which they trust the server without a warning. It is public: the
+// server sends it in every TLS handshake anyway.
+func (s *Server) handleCert(w http.ResponseWriter, r *http.Request) {
+	if s.tlsCA == nil {
+		http.Error(w, "TLS is not enabled", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+	w.Header().Set("Content-Disposition", `attachment; filename="peer-drop-ca.crt"`)
+	w.Write(s.tlsCA.Raw)
+}
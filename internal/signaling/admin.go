@@ -0,0 +1,118 @@
+package signaling
+
+import (
+	"sort"
+	"time"
+)
+
+// CloseKicked is the WebSocket close code sent to clients an admin
+// disconnected. Clients shouldn't reconnect on their own after it.
+const CloseKicked = 4002
+
+// ClientInfo describes a connected client for moderation
+type ClientInfo struct {
+	PeerInfo
+	IP         string    `json:"ip"`
+	Transport  string    `json:"transport"`
+	Connected  time.Time `json:"connected"`
+	IPRoom     string    `json:"ipRoom,omitempty"`
+	PublicRoom string    `json:"publicRoom,omitempty"`
+}
+
+// RoomInfo describes a room for moderation
+type RoomInfo struct {
+	ID         string    `json:"id"`
+	Alias      string    `json:"alias,omitempty"`
+	Public     bool      `json:"public"`
+	Persistent bool      `json:"persistent,omitempty"`
+	Clients    []string  `json:"clients"`
+	Created    time.Time `json:"created"`
+	LastActive time.Time `json:"lastActive"`
+}
+
+// Clients lists the connected clients, longest connected first
+func (h *Hub) Clients() []ClientInfo {
+	h.clientsMu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for _, c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.clientsMu.RUnlock()
+
+	out := make([]ClientInfo, 0, len(clients))
+	for _, c := range clients {
+		info := ClientInfo{
+			PeerInfo:  c.PeerInfo(),
+			IP:        c.ip,
+			Transport: c.via,
+			Connected: c.connected,
+		}
+		if room := c.ipRoom; room != nil {
+			info.IPRoom = room.ID()
+		}
+		if room := c.publicRoom; room != nil && !room.isClosed() {
+			info.PublicRoom = room.ID()
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Connected.Before(out[j].Connected) })
+	return out
+}
+
+// Rooms lists the IP rooms and public rooms, public ones first
+func (h *Hub) Rooms() []RoomInfo {
+	h.publicRoomsMu.RLock()
+	rooms := make([]*Room, 0, len(h.publicRooms))
+	for _, r := range h.publicRooms {
+		rooms = append(rooms, r)
+	}
+	h.publicRoomsMu.RUnlock()
+
+	h.ipRoomsMu.RLock()
+	for _, r := range h.ipRooms {
+		rooms = append(rooms, r)
+	}
+	h.ipRoomsMu.RUnlock()
+
+	out := make([]RoomInfo, 0, len(rooms))
+	for _, r := range rooms {
+		info := RoomInfo{
+			ID:         r.ID(),
+			Alias:      r.Alias(),
+			Public:     r.IsPublic(),
+			Persistent: r.persistent,
+			Clients:    []string{},
+			Created:    r.created,
+			LastActive: time.Unix(0, r.lastActive.Load()),
+		}
+		for _, c := range r.GetClients() {
+			info.Clients = append(info.Clients, c.id)
+		}
+		sort.Strings(info.Clients)
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Public != out[j].Public {
+			return out[i].Public
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// KickClient disconnects a client, telling it reason. It reports false if
+// no client has that ID.
+func (h *Hub) KickClient(id, reason string) bool {
+	h.clientsMu.RLock()
+	c, ok := h.clients[id]
+	h.clientsMu.RUnlock()
+	if !ok {
+		return false
+	}
+	if reason == "" {
+		reason = "disconnected by the server admin"
+	}
+	h.logger.Info("client kicked", "id", id, "ip", c.ip, "name", c.name)
+	c.closeWithReason(CloseKicked, reason)
+	return true
+}
@@ -0,0 +1,158 @@
+package signaling
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+// CapabilityBinaryRelay is announced in the join message by clients that
+// can send and receive relay chunks as binary WebSocket frames
+const CapabilityBinaryRelay = "binary-relay"
+
+// Capabilities lists the optional protocol features every hub supports
+var Capabilities = []string{CapabilityBinaryRelay}
+
+// Binary relay chunk frame layout (integers are big-endian):
+//
+//	0       frame kind (frameRelayChunk)
+//	1       flags (flagLastChunk, flagEncrypted)
+//	2..17   peer ID: the target when sent to the hub, the sender when
+//	        delivered by the hub
+//	18      transfer ID length n
+//	19..    transfer ID (n bytes)
+//	+0      file index (uint32)
+//	+4      chunk index (uint32)
+//	+8      total chunks in the file (uint32)
+//	+12     CRC-32 (IEEE) of the chunk data (uint32)
+//	+16     chunk data
+//
+// JSON messages always start with '{', so the first byte also tells binary
+// frames apart from text messages in a client's send queue.
+const (
+	frameRelayChunk byte = 0x01
+	flagLastChunk   byte = 1 << 0
+	flagEncrypted   byte = 1 << 1
+
+	framePeerIDOffset = 2
+	framePeerIDSize   = 16
+	frameFixedSize    = 16
+)
+
+var errBadFrame = errors.New("malformed binary frame")
+
+// relayChunkFrame is a parsed binary relay chunk. Data aliases the frame.
+type relayChunkFrame struct {
+	PeerID      string
+	TransferID  string
+	FileIndex   int
+	ChunkIndex  int
+	TotalChunks int
+	CRC         uint32
+	IsLast      bool
+	Encrypted   bool
+	Data        []byte
+}
+
+// isBinaryFrame reports whether a queued message is a binary frame rather
+// than JSON text
+func isBinaryFrame(msg []byte) bool {
+	return len(msg) > 0 && msg[0] == frameRelayChunk
+}
+
+// parseRelayChunkFrame decodes a binary relay chunk frame
+func parseRelayChunkFrame(frame []byte) (relayChunkFrame, error) {
+	const idEnd = framePeerIDOffset + framePeerIDSize
+	if len(frame) < idEnd+1 || frame[0] != frameRelayChunk {
+		return relayChunkFrame{}, errBadFrame
+	}
+
+	tidLen := int(frame[idEnd])
+	fixed := idEnd + 1 + tidLen
+	if len(frame) < fixed+frameFixedSize {
+		return relayChunkFrame{}, errBadFrame
+	}
+
+	return relayChunkFrame{
+		PeerID:      hex.EncodeToString(frame[framePeerIDOffset:idEnd]),
+		TransferID:  string(frame[idEnd+1 : fixed]),
+		FileIndex:   int(binary.BigEndian.Uint32(frame[fixed:])),
+		ChunkIndex:  int(binary.BigEndian.Uint32(frame[fixed+4:])),
+		TotalChunks: int(binary.BigEndian.Uint32(frame[fixed+8:])),
+		CRC:         binary.BigEndian.Uint32(frame[fixed+12:]),
+		IsLast:      frame[1]&flagLastChunk != 0,
+		Encrypted:   frame[1]&flagEncrypted != 0,
+		Data:        frame[fixed+frameFixedSize:],
+	}, nil
+}
+
+// withPeerID returns a copy of frame with the peer ID field replaced
+func withPeerID(frame []byte, peerID string) ([]byte, error) {
+	id, err := hex.DecodeString(peerID)
+	if err != nil || len(id) != framePeerIDSize {
+		return nil, errBadFrame
+	}
+	out := slices.Clone(frame)
+	copy(out[framePeerIDOffset:], id)
+	return out, nil
+}
+
+// relayChunkJSON transcodes a binary frame into a relay-chunk JSON message
+// for clients that did not announce binary relay support
+func relayChunkJSON(f relayChunkFrame, senderID string) ([]byte, error) {
+	payload, err := json.Marshal(RelayChunkPayload{
+		TransferID:  f.TransferID,
+		FileIndex:   f.FileIndex,
+		ChunkIndex:  f.ChunkIndex,
+		TotalChunks: f.TotalChunks,
+		Data:        base64.StdEncoding.EncodeToString(f.Data),
+		CRC:         f.CRC,
+		IsLast:      f.IsLast,
+		Encrypted:   f.Encrypted,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{
+		Type:    TypeRelayChunk,
+		PeerID:  senderID,
+		Payload: payload,
+	})
+}
+
+// handleBinary relays a binary relay chunk frame to its target, rewriting
+// the peer ID to the sender's. Targets without binary support get the
+// chunk as base64 JSON instead.
+func (c *Client) handleBinary(frame []byte) {
+	f, err := parseRelayChunkFrame(frame)
+	if err != nil {
+		c.logger.Warn("dropping binary frame", "clientID", c.id, "error", err)
+		return
+	}
+
+	target := c.findPeer(f.PeerID)
+	if target == nil {
+		c.logger.Debug("relay target not found", "targetID", f.PeerID)
+		return
+	}
+	if !c.spendRelayBudget(f.PeerID, f.TransferID, int64(len(f.Data))) {
+		return
+	}
+
+	var out []byte
+	if target.binaryRelay {
+		out, err = withPeerID(frame, c.id)
+	} else {
+		out, err = relayChunkJSON(f, c.id)
+	}
+	if err != nil {
+		c.logger.Warn("failed to relay binary chunk", "clientID", c.id, "error", err)
+		return
+	}
+	c.hub.relayedBytes.Add(int64(len(out)))
+	target.sendRelay(out)
+	c.publishProgress(target.id, f.TransferID, f.FileIndex, f.ChunkIndex, f.TotalChunks)
+}
@@ -0,0 +1,168 @@
+package signaling
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Room bulletin boards hold a few small postings, like a pinboard for a
+// meeting. Posts expire after boardPostTTL and the oldest is dropped when
+// a board is full, so a room never holds more than about 2MB of them.
+const (
+	maxBoardPosts    = 16
+	maxBoardText     = 4096
+	maxBoardFileSize = 128 * 1024 // decoded bytes
+	maxBoardFileName = 255
+	boardPostTTL     = time.Hour
+)
+
+// handleBoardPost pins a post to the board of the client's room and shows
+// it to everyone there
+func (c *Client) handleBoardPost(payload json.RawMessage) {
+	var post BoardPost
+	if err := json.Unmarshal(payload, &post); err != nil {
+		c.logger.Warn("failed to unmarshal board post payload", "error", err)
+		return
+	}
+
+	room := c.broadcastRoom()
+	if room == nil {
+		return
+	}
+
+	if err := validateBoardPost(&post); err != nil {
+		msg, _ := NewRoomErrorMessage(err.Error())
+		c.Send(msg)
+		return
+	}
+
+	now := time.Now()
+	post.ID = generateClientID()[:12]
+	post.PeerID = c.id
+	post.Name = c.name
+	post.Time = now.UnixMilli()
+	post.Expires = now.Add(boardPostTTL).UnixMilli()
+	post.Own = false
+
+	dropped := room.addBoardPost(post, now)
+
+	out, _ := NewBoardPostMessage(post)
+	room.Broadcast(out, c.id)
+	post.Own = true
+	own, _ := NewBoardPostMessage(post)
+	c.Send(own)
+
+	// Tell everyone about posts pushed off a full board
+	for _, id := range dropped {
+		msg, _ := NewBoardDeleteMessage(id)
+		room.Broadcast(msg, "")
+	}
+
+	c.logger.Debug("board post added", "room", room.ID(), "clientID", c.id, "post", post.ID)
+}
+
+// handleBoardDelete removes one of the client's own board posts
+func (c *Client) handleBoardDelete(payload json.RawMessage) {
+	var del BoardDeletePayload
+	if err := json.Unmarshal(payload, &del); err != nil {
+		c.logger.Warn("failed to unmarshal board delete payload", "error", err)
+		return
+	}
+
+	room := c.broadcastRoom()
+	if room == nil || !room.removeBoardPost(del.ID, c.id) {
+		return
+	}
+
+	msg, _ := NewBoardDeleteMessage(del.ID)
+	room.Broadcast(msg, "")
+}
+
+// validateBoardPost checks the size of a post and tidies its text
+func validateBoardPost(post *BoardPost) error {
+	post.Text = strings.TrimSpace(post.Text)
+	if post.Text == "" && post.File == nil {
+		return errors.New("board post is empty")
+	}
+	if len(post.Text) > maxBoardText || !utf8.ValidString(post.Text) {
+		return errors.New("board post text is too long")
+	}
+
+	if f := post.File; f != nil {
+		if f.Name == "" || len(f.Name) > maxBoardFileName || !utf8.ValidString(f.Name) {
+			return errors.New("board file needs a name")
+		}
+		data, err := base64.StdEncoding.DecodeString(f.Data)
+		if err != nil {
+			return errors.New("board file data is not valid base64")
+		}
+		if len(data) > maxBoardFileSize {
+			return errors.New("board files are limited to 128 KB")
+		}
+	}
+	return nil
+}
+
+// addBoardPost pins a post, dropping expired posts and the oldest if the
+// board is full. It returns the IDs of posts pushed off the board.
+func (r *Room) addBoardPost(post BoardPost, now time.Time) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pruneBoardLocked(now)
+	var dropped []string
+	for len(r.board) >= maxBoardPosts {
+		dropped = append(dropped, r.board[0].ID)
+		r.board = slices.Delete(r.board, 0, 1)
+	}
+	r.board = append(r.board, post)
+	return dropped
+}
+
+// removeBoardPost deletes post id if it was posted by peerID
+func (r *Room) removeBoardPost(id, peerID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.board, func(p BoardPost) bool { return p.ID == id })
+	if i < 0 || r.board[i].PeerID != peerID {
+		return false
+	}
+	r.board = slices.Delete(r.board, i, i+1)
+	return true
+}
+
+// boardPosts returns the unexpired posts, marking those by peerID as own
+func (r *Room) boardPosts(peerID string, now time.Time) []BoardPost {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pruneBoardLocked(now)
+	posts := make([]BoardPost, len(r.board))
+	for i, p := range r.board {
+		p.Own = p.PeerID == peerID
+		posts[i] = p
+	}
+	return posts
+}
+
+// pruneBoardLocked drops expired posts. The caller must hold r.mu.
+func (r *Room) pruneBoardLocked(now time.Time) {
+	ms := now.UnixMilli()
+	r.board = slices.DeleteFunc(r.board, func(p BoardPost) bool { return p.Expires <= ms })
+}
+
+// sendBoard shows a client that just joined room the posts on its board
+func sendBoard(client *Client, room *Room) {
+	posts := room.boardPosts(client.id, time.Now())
+	if len(posts) == 0 {
+		return
+	}
+	msg, _ := NewBoardMessage(room.ID(), posts)
+	client.Send(msg)
+}
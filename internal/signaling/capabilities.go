@@ -0,0 +1,61 @@
+package signaling
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Capabilities peers announce to each other. The hub passes them on in
+// PeerInfo without acting on them, so clients can check what the other
+// side supports before relying on it.
+const (
+	// CapabilityResume is announced by clients that pause and resume
+	// transfers on request
+	CapabilityResume = "resume"
+
+	// CapabilityE2E is announced by clients that can encrypt relayed
+	// transfers end to end
+	CapabilityE2E = "e2e"
+)
+
+const (
+	maxCapabilities      = 16
+	maxCapabilityLength  = 32
+	maxClientVersionSize = 32
+)
+
+// capabilityPattern matches capability names such as "binary-relay"
+var capabilityPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// sanitizeCapabilities keeps the well-formed, distinct capabilities from
+// a join message, up to maxCapabilities. Unknown names are kept: they may
+// mean something to other peers even if not to the hub.
+func sanitizeCapabilities(caps []string) []string {
+	var out []string
+	for _, c := range caps {
+		if len(out) == maxCapabilities {
+			break
+		}
+		if len(c) > maxCapabilityLength || !capabilityPattern.MatchString(c) || slices.Contains(out, c) {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// sanitizeClientVersion trims a client's self-reported version to a
+// printable string of bounded length
+func sanitizeClientVersion(v string) string {
+	v = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(v))
+	if len(v) > maxClientVersionSize {
+		v = strings.ToValidUTF8(v[:maxClientVersionSize], "")
+	}
+	return v
+}
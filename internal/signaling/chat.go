@@ -0,0 +1,99 @@
+package signaling
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Longest chat message relayed, in bytes. Chat is for links and short
+// notes; anything longer should be sent as a file.
+const maxChatLength = 4096
+
+// Upper bound for RoomOptions.ChatHistory
+const maxChatHistory = 500
+
+// handleChat relays a chat message to its target, or to everyone in the
+// client's public room, falling back to its IP room
+func (c *Client) handleChat(msg Message) {
+	var payload ChatPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		c.logger.Warn("failed to unmarshal chat payload", "error", err)
+		return
+	}
+
+	text := strings.TrimSpace(payload.Text)
+	if text == "" || len(text) > maxChatLength || !utf8.ValidString(text) {
+		c.logger.Debug("dropping invalid chat message", "clientID", c.id, "length", len(text))
+		return
+	}
+
+	entry := ChatEntry{
+		PeerID: c.id,
+		ChatPayload: ChatPayload{
+			Text: text,
+			Name: c.name,
+			Time: time.Now().UnixMilli(),
+		},
+	}
+
+	if msg.TargetID != "" {
+		target := c.findPeer(msg.TargetID)
+		if target == nil {
+			c.logger.Debug("chat target not found", "targetID", msg.TargetID)
+			return
+		}
+		entry.Direct = true
+		out, _ := NewChatMessage(entry)
+		target.Send(out)
+		return
+	}
+
+	room := c.broadcastRoom()
+	if room == nil {
+		return
+	}
+	out, _ := NewChatMessage(entry)
+	room.Broadcast(out, c.id)
+	room.addChat(entry, c.hub.roomOpts.ChatHistory)
+}
+
+// broadcastRoom returns the room untargeted messages go to: the client's
+// public room, or its IP room when it has none
+func (c *Client) broadcastRoom() *Room {
+	if c.publicRoom != nil {
+		return c.publicRoom
+	}
+	return c.ipRoom
+}
+
+// addChat appends a message to the room's history, keeping the last limit
+func (r *Room) addChat(entry ChatEntry, limit int) {
+	if limit <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.chat = append(r.chat, entry)
+	if over := len(r.chat) - limit; over > 0 {
+		r.chat = append(r.chat[:0], r.chat[over:]...)
+	}
+}
+
+// chatHistory returns a copy of the room's recent chat messages
+func (r *Room) chatHistory() []ChatEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]ChatEntry(nil), r.chat...)
+}
+
+// sendChatHistory catches a client that just joined room up on its chat
+func sendChatHistory(client *Client, room *Room) {
+	history := room.chatHistory()
+	if len(history) == 0 {
+		return
+	}
+	msg, _ := NewChatHistoryMessage(room.ID(), history)
+	client.Send(msg)
+}
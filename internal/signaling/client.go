@@ -2,53 +2,96 @@ package signaling
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
-const (
-	// Time allowed to write a message to the peer
-	writeWait = 10 * time.Second
-
-	// Time allowed to read the next pong message from the peer
-	pongWait = 60 * time.Second
+// Maximum message size allowed from peer
+const maxMessageSize = 10 * 1024 * 1024 // 10MB for relay chunks
 
-	// Send pings to peer with this period (must be less than pongWait)
-	pingPeriod = (pongWait * 9) / 10
+// transport carries a client's messages. Client logic only queues outgoing
+// messages on send and handles incoming ones; the transport moves them over
+// the wire and unregisters the client when the connection ends.
+type transport interface {
+	// close ends the connection, passing code and reason to the client
+	close(code int, reason string)
 
-	// Maximum message size allowed from peer
-	maxMessageSize = 10 * 1024 * 1024 // 10MB for relay chunks
-)
+	// binary reports whether binary relay frames can be delivered
+	binary() bool
+}
 
-// Client represents a WebSocket connection
+// Client represents a signaling connection, over WebSocket or the HTTP
+// fallback
 type Client struct {
 	id         string
-	conn       *websocket.Conn
+	transport  transport
 	hub        *Hub
 	send       chan []byte
 	ipRoom     *Room
 	publicRoom *Room
 
+	// Guards closing send. Other clients' goroutines send to this one
+	// after looking it up, so it may unregister in between.
+	sendMu     sync.RWMutex
+	sendClosed bool
+
+	// Disk overflow for relay chunks to slow receivers
+	spill *spillQueue
+
 	// Peer info
 	name     string
 	platform string
+	locale   string
 	ip       string
 
+	// What the client announced it speaks and supports
+	version         string
+	protocolVersion int
+	capabilities    []string
+
+	// Host the client used to reach the server
+	host string
+
+	// How and when the client connected
+	via       string // "websocket" or "http"
+	connected time.Time
+
+	// IP room the client joins, decided when it connects
+	ipRoomID string
+
+	// Client accepts relay chunks as binary frames
+	binaryRelay bool
+
+	// Bytes each transfer this client requested may still relay, while
+	// transfer limits are set
+	relayBudgets map[string]*relayBudget
+
+	// When this client last nudged each peer; only touched while handling
+	// the client's messages
+	lastNudge map[string]time.Time
+
 	logger *slog.Logger
 }
 
 // NewClient creates a new client
-func NewClient(id string, conn *websocket.Conn, hub *Hub, ip string, logger *slog.Logger) *Client {
-	return &Client{
-		id:     id,
-		conn:   conn,
-		hub:    hub,
-		send:   make(chan []byte, 256),
-		ip:     ip,
-		logger: logger,
+func NewClient(id string, t transport, hub *Hub, ip string, logger *slog.Logger) *Client {
+	c := &Client{
+		id:        id,
+		transport: t,
+		hub:       hub,
+		send:      make(chan []byte, 256),
+		ip:        ip,
+		connected: time.Now(),
+		lastNudge: make(map[string]time.Time),
+		logger:    logger,
 	}
+	c.spill = newSpillQueue(c, &hub.spillMetrics)
+	return c
 }
 
 // ID returns the client ID
@@ -56,92 +99,65 @@ func (c *Client) ID() string {
 	return c.id
 }
 
+// Locale returns the client's preferred locale, or "" if it sent none
+func (c *Client) Locale() string {
+	return c.locale
+}
+
 // PeerInfo returns the peer information for this client
 func (c *Client) PeerInfo() PeerInfo {
 	return PeerInfo{
 		ID:       c.id,
 		Name:     c.name,
 		Platform: c.platform,
+		Locale:   c.locale,
+
+		Version:         c.version,
+		ProtocolVersion: c.protocolVersion,
+		Capabilities:    c.capabilities,
 	}
 }
 
-// Send queues a message to be sent to the client
+// Send queues a message to be sent to the client. Messages to a client
+// that has unregistered are dropped.
 func (c *Client) Send(msg []byte) {
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+	if c.sendClosed {
+		return
+	}
+
 	select {
 	case c.send <- msg:
 	default:
 		// Channel full, client is slow
-		c.logger.Warn("client send buffer full", "clientID", c.id)
+		c.hub.droppedMessages.Add(1)
+		c.logger.Warn("client send buffer full, message dropped", "clientID", c.id)
 	}
 }
 
-// ReadPump pumps messages from the WebSocket connection to the hub
-func (c *Client) ReadPump() {
-	defer func() {
-		c.hub.Unregister(c)
-		c.conn.Close()
-	}()
-
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
-	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
-		return nil
-	})
-
-	for {
-		_, message, err := c.conn.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				c.logger.Warn("websocket read error", "error", err, "clientID", c.id)
-			}
-			break
-		}
-		c.handleMessage(message)
-	}
-}
-
-// WritePump pumps messages from the hub to the WebSocket connection
-func (c *Client) WritePump() {
-	ticker := time.NewTicker(pingPeriod)
-	defer func() {
-		ticker.Stop()
-		c.conn.Close()
-	}()
-
-	for {
-		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				// Hub closed the channel
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-
-			// Add queued messages to the current websocket message
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
-			}
+// closeSend closes the send channel, ending the transport's writer.
+// Later Sends are dropped.
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if !c.sendClosed {
+		c.sendClosed = true
+		close(c.send)
+	}
+}
 
-			if err := w.Close(); err != nil {
-				return
-			}
+// sendRelay queues a relay chunk, spilling it to disk when the client is
+// falling behind. Once spilling starts, later chunks also go through the
+// spill queue so they stay in order.
+func (c *Client) sendRelay(msg []byte) {
+	if !c.spill.Active() && len(c.send) < relaySpillThreshold {
+		c.Send(msg)
+		return
+	}
 
-		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				return
-			}
-		}
+	if err := c.spill.Push(msg); err != nil {
+		c.logger.Warn("relay spill failed", "clientID", c.id, "error", err)
 	}
 }
 
@@ -153,17 +169,45 @@ func (c *Client) handleMessage(data []byte) {
 		return
 	}
 
+	// Drop a public room the hub has closed; anything but a keep-alive
+	// counts as activity in a live one
+	if room := c.publicRoom; room != nil {
+		if room.isClosed() {
+			c.publicRoom = nil
+		} else if msg.Type != TypePing {
+			room.touch()
+		}
+	}
+
 	switch msg.Type {
 	case TypeJoin:
 		c.handleJoin(msg.Payload)
 	case TypeOffer, TypeAnswer, TypeIceCandidate:
 		c.relayToTarget(msg, data)
-	case TypeTransferRequest, TypeTransferResponse:
+	case TypeTransferRequest:
+		c.handleTransferRequest(msg, data)
+	case TypeTransferResponse:
+		c.handleTransferResponse(msg, data)
+	case TypeTransferPause, TypeTransferResume:
 		c.relayToTarget(msg, data)
 	case TypeRelayChunk:
+		c.handleRelayChunk(msg, data)
+	case TypeRelayNack, TypeRelayAck, TypeRelayKey:
 		c.relayToTarget(msg, data)
+	case TypeNudge:
+		c.handleNudge(msg)
+	case TypeChat:
+		c.handleChat(msg)
+	case TypeClipboard:
+		c.handleClipboard(msg)
+	case TypeCustom:
+		c.handleCustom(msg)
+	case TypeBoardPost:
+		c.handleBoardPost(msg.Payload)
+	case TypeBoardDelete:
+		c.handleBoardDelete(msg.Payload)
 	case TypeCreateRoom:
-		c.handleCreateRoom()
+		c.handleCreateRoom(msg.Payload)
 	case TypeJoinRoom:
 		c.handleJoinRoom(msg.Payload)
 	case TypeLeaveRoom:
@@ -181,23 +225,81 @@ func (c *Client) handleJoin(payload json.RawMessage) {
 		return
 	}
 
+	if joinPayload.ProtocolVersion < MinProtocolVersion {
+		c.logger.Warn("rejecting outdated client", "id", c.id, "protocolVersion", joinPayload.ProtocolVersion)
+		c.closeWithReason(CloseUnsupportedProtocol, fmt.Sprintf(
+			"client protocol %d is older than the minimum supported %d, please reload the page",
+			joinPayload.ProtocolVersion, MinProtocolVersion))
+		return
+	}
+
 	c.name = joinPayload.Name
 	c.platform = joinPayload.Platform
+	c.locale = NormalizeLocale(joinPayload.Locale)
+	c.version = sanitizeClientVersion(joinPayload.Version)
+	c.protocolVersion = joinPayload.ProtocolVersion
+	c.capabilities = sanitizeCapabilities(joinPayload.Capabilities)
+	c.binaryRelay = c.transport.binary() && slices.Contains(joinPayload.Capabilities, CapabilityBinaryRelay)
+
+	// Tell the client about relay servers before it starts dialing peers
+	c.sendIceServers()
 
 	// Join IP-based room
 	c.hub.JoinIPRoom(c)
 
-	c.logger.Info("client joined", "id", c.id, "name", c.name, "platform", c.platform, "ipRoom", c.ipRoom.ID())
+	c.logger.Info("client joined", "id", c.id, "name", c.name, "platform", c.platform, "locale", c.locale, "binaryRelay", c.binaryRelay, "capabilities", c.capabilities, "ipRoom", c.ipRoom.ID())
+}
+
+// sendIceServers sends the client its ice-servers list, if the hub has one
+func (c *Client) sendIceServers() {
+	if c.hub.iceServers == nil {
+		return
+	}
+	servers := c.hub.iceServers(c.host, c.id)
+	if len(servers) == 0 {
+		return
+	}
+	msg, _ := NewIceServersMessage(servers)
+	c.Send(msg)
+}
+
+// closeWithReason ends the connection, telling the client why. The
+// transport notices and unregisters the client.
+func (c *Client) closeWithReason(code int, reason string) {
+	c.transport.close(code, reason)
 }
 
-// handleCreateRoom creates a new public room
-func (c *Client) handleCreateRoom() {
-	code := c.hub.CreatePublicRoom(c)
+// handleCreateRoom creates a new public room, optionally under a custom
+// alias
+func (c *Client) handleCreateRoom(payload json.RawMessage) {
+	var roomPayload CreateRoomPayload
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &roomPayload); err != nil {
+			c.logger.Warn("failed to unmarshal create room payload", "error", err)
+			return
+		}
+	}
 
-	msg, _ := NewRoomCreatedMessage(code)
+	limits := RoomLimits{
+		MaxPeers:    roomPayload.MaxPeers,
+		Lifetime:    time.Duration(roomPayload.Lifetime) * time.Second,
+		IdleTimeout: time.Duration(roomPayload.IdleTimeout) * time.Second,
+	}
+	room, err := c.hub.CreatePublicRoom(c, RoomRequest{
+		Alias:      strings.ToLower(strings.TrimSpace(roomPayload.Alias)),
+		Limits:     limits,
+		Persistent: roomPayload.Persistent,
+	})
+	if err != nil {
+		msg, _ := NewRoomErrorMessage(err.Error())
+		c.Send(msg)
+		return
+	}
+
+	msg, _ := NewRoomCreatedMessage(room.ID(), room.Alias())
 	c.Send(msg)
 
-	c.logger.Info("public room created", "code", code, "creator", c.id)
+	c.logger.Info("public room created", "code", room.ID(), "alias", room.Alias(), "persistent", roomPayload.Persistent, "creator", c.id)
 }
 
 // handleJoinRoom joins a public room by code
@@ -208,7 +310,18 @@ func (c *Client) handleJoinRoom(payload json.RawMessage) {
 		return
 	}
 
+	if wait := c.hub.joinLimiter.check(c); wait > 0 {
+		msg, _ := NewRoomErrorMessage(retryMessage(wait))
+		c.Send(msg)
+		return
+	}
+
 	if err := c.hub.JoinPublicRoom(c, roomPayload.Code); err != nil {
+		if errors.Is(err, ErrRoomNotFound) {
+			if locked := c.hub.joinLimiter.fail(c); len(locked) > 0 {
+				c.logger.Warn("room join attempts locked out", "clientID", c.id, "ip", c.ip, "keys", locked)
+			}
+		}
 		msg, _ := NewRoomErrorMessage(err.Error())
 		c.Send(msg)
 		return
@@ -226,24 +339,45 @@ func (c *Client) handleLeaveRoom() {
 }
 
 // relayToTarget forwards a message to the target peer
-func (c *Client) relayToTarget(msg Message, rawData []byte) {
+func (c *Client) relayToTarget(msg Message, rawData []byte) bool {
 	if msg.TargetID == "" {
-		return
+		return false
+	}
+
+	target := c.findPeer(msg.TargetID)
+	if target == nil {
+		c.logger.Debug("relay target not found", "targetID", msg.TargetID)
+		return false
 	}
 
 	// Add sender ID to the message
 	msg.PeerID = c.id
 	data, _ := json.Marshal(msg)
 
-	// Try to relay in IP room first
-	if c.ipRoom != nil && c.ipRoom.RelayTo(msg.TargetID, data) {
-		return
+	if msg.Type == TypeRelayChunk {
+		c.hub.relayedBytes.Add(int64(len(data)))
+		target.sendRelay(data)
+		return true
+	}
+	target.Send(data)
+	return true
+}
+
+// findPeer looks up a client sharing the IP room or public room with c
+func (c *Client) findPeer(id string) *Client {
+	// Try IP room first
+	if c.ipRoom != nil {
+		if peer := c.ipRoom.GetClient(id); peer != nil {
+			return peer
+		}
 	}
 
 	// Try public room
-	if c.publicRoom != nil && c.publicRoom.RelayTo(msg.TargetID, data) {
-		return
+	if c.publicRoom != nil {
+		if peer := c.publicRoom.GetClient(id); peer != nil {
+			return peer
+		}
 	}
 
-	c.logger.Debug("relay target not found", "targetID", msg.TargetID)
+	return nil
 }
@@ -0,0 +1,39 @@
+package signaling
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+)
+
+// Largest clipboard text relayed, in bytes
+const maxClipboardLength = 64 * 1024
+
+// handleClipboard forwards clipboard text to its target, or to the rest of
+// the client's room. Unlike chat, it is never kept for late joiners.
+func (c *Client) handleClipboard(msg Message) {
+	var payload ClipboardPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		c.logger.Warn("failed to unmarshal clipboard payload", "error", err)
+		return
+	}
+	if payload.Text == "" || len(payload.Text) > maxClipboardLength || !utf8.ValidString(payload.Text) {
+		c.logger.Debug("dropping invalid clipboard message", "clientID", c.id, "length", len(payload.Text))
+		return
+	}
+
+	out, _ := NewClipboardMessage(c.id, c.name, payload.Text)
+
+	if msg.TargetID != "" {
+		target := c.findPeer(msg.TargetID)
+		if target == nil {
+			c.logger.Debug("clipboard target not found", "targetID", msg.TargetID)
+			return
+		}
+		target.Send(out)
+		return
+	}
+
+	if room := c.broadcastRoom(); room != nil {
+		room.Broadcast(out, c.id)
+	}
+}
@@ -0,0 +1,58 @@
+package signaling
+
+import (
+	"encoding/json"
+	"regexp"
+)
+
+// Largest custom payload relayed, in bytes
+const maxCustomPayload = 64 * 1024
+
+// customNamespacePattern matches namespaces such as "com.example.polls".
+// Reverse domain names are suggested so front ends don't collide.
+var customNamespacePattern = regexp.MustCompile(`^[a-z0-9]+([.-][a-z0-9]+)*$`)
+
+// maxCustomNamespaceLength bounds custom message namespaces
+const maxCustomNamespaceLength = 64
+
+// handleCustom relays an application-defined message to its target, or to
+// the rest of the client's room, without looking inside it
+func (c *Client) handleCustom(msg Message) {
+	if len(msg.Payload) > maxCustomPayload {
+		c.logger.Debug("dropping oversized custom message", "clientID", c.id, "size", len(msg.Payload))
+		return
+	}
+
+	var payload CustomPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		c.logger.Warn("failed to unmarshal custom payload", "error", err)
+		return
+	}
+	if len(payload.Namespace) > maxCustomNamespaceLength || !customNamespacePattern.MatchString(payload.Namespace) {
+		c.logger.Debug("dropping custom message with invalid namespace", "clientID", c.id, "namespace", payload.Namespace)
+		return
+	}
+
+	// Re-encode so only the envelope's own fields reach peers
+	data, _ := json.Marshal(payload)
+	out, _ := json.Marshal(Message{
+		Type:     TypeCustom,
+		PeerID:   c.id,
+		TargetID: msg.TargetID,
+		Payload:  data,
+	})
+
+	if msg.TargetID != "" {
+		target := c.findPeer(msg.TargetID)
+		if target == nil {
+			c.logger.Debug("custom message target not found", "targetID", msg.TargetID)
+			return
+		}
+		target.Send(out)
+		return
+	}
+
+	if room := c.broadcastRoom(); room != nil {
+		room.Broadcast(out, c.id)
+	}
+}
@@ -0,0 +1,114 @@
+package signaling
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// How clients are put into IP rooms
+const (
+	// GroupBySubnet groups clients whose addresses share a prefix
+	GroupBySubnet = "subnet"
+
+	// GroupByIP groups clients with the same address, e.g. one public IP
+	// per site behind NAT
+	GroupByIP = "ip"
+
+	// GroupByHeader groups clients by a request header a trusted reverse
+	// proxy sets, falling back to subnets when it is missing
+	GroupByHeader = "header"
+)
+
+// Longest header value used as a room ID
+const maxGroupHeaderValue = 64
+
+// IPGrouping decides which IP room a client lands in
+type IPGrouping struct {
+	Mode       string
+	IPv4Prefix int // prefix length in bits
+	IPv6Prefix int
+	Header     string // for GroupByHeader
+}
+
+// DefaultIPGrouping groups IPv4 clients by /24 and IPv6 clients by /64
+var DefaultIPGrouping = IPGrouping{
+	Mode:       GroupBySubnet,
+	IPv4Prefix: 24,
+	IPv6Prefix: 64,
+}
+
+// Validate reports settings that can't group clients
+func (g IPGrouping) Validate() error {
+	switch g.Mode {
+	case GroupBySubnet, GroupByIP:
+	case GroupByHeader:
+		if g.Header == "" {
+			return errors.New("header grouping needs a header name")
+		}
+	default:
+		return fmt.Errorf("unknown grouping mode %q", g.Mode)
+	}
+	if g.IPv4Prefix < 1 || g.IPv4Prefix > 32 {
+		return fmt.Errorf("IPv4 prefix /%d out of range", g.IPv4Prefix)
+	}
+	if g.IPv6Prefix < 1 || g.IPv6Prefix > 128 {
+		return fmt.Errorf("IPv6 prefix /%d out of range", g.IPv6Prefix)
+	}
+	return nil
+}
+
+// SetIPGrouping replaces how clients are grouped into IP rooms. It may be
+// called while serving; clients keep the room they joined until they
+// reconnect.
+func (h *Hub) SetIPGrouping(g IPGrouping) {
+	h.ipGrouping.Store(&g)
+}
+
+// roomID returns the IP room for a client at addr ("host" or
+// "host:port"). header holds the headers set by a trusted proxy, if the
+// request came through one.
+func (g IPGrouping) roomID(addr string, header http.Header) string {
+	if g.Mode == GroupByHeader {
+		if v := groupHeaderValue(header.Get(g.Header)); v != "" {
+			return "header:" + v
+		}
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return "default"
+	}
+	ip = ip.Unmap().WithZone("")
+
+	bits := g.IPv6Prefix
+	if ip.Is4() {
+		bits = g.IPv4Prefix
+	}
+	if g.Mode == GroupByIP {
+		bits = ip.BitLen()
+	}
+	prefix, _ := ip.Prefix(bits)
+	return prefix.String()
+}
+
+// groupHeaderValue makes a header value safe to use as a room ID
+func groupHeaderValue(v string) string {
+	v = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(v))
+	if len(v) > maxGroupHeaderValue {
+		v = strings.ToValidUTF8(v[:maxGroupHeaderValue], "")
+	}
+	return v
+}
@@ -1,22 +1,30 @@
 package signaling
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"Peer-Drop/internal/events"
 )
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for local network
+		return true // Checked by the hub's ConnPolicy before upgrading
 	},
 }
 
@@ -26,56 +34,204 @@ type Hub struct {
 	ipRooms   map[string]*Room
 	ipRoomsMu sync.RWMutex
 
-	// Public rooms (joined by code)
+	// Public rooms (joined by code or word alias)
 	publicRooms   map[string]*Room
+	roomAliases   map[string]string    // alias -> code
+	aliasExpiry   map[string]time.Time // custom alias -> when it is released
+	roomOpts      RoomOptions
 	publicRoomsMu sync.RWMutex
 
 	// All connected clients
 	clients   map[string]*Client
 	clientsMu sync.RWMutex
 
+	// HTTP fallback clients by session token
+	sessions   map[string]*Client
+	sessionsMu sync.RWMutex
+
+	// Relay disk spill usage
+	spillMetrics spillMetrics
+
+	// Messages dropped because a client's send buffer was full
+	droppedMessages atomic.Int64
+
+	// Relay chunk bytes forwarded between clients
+	relayedBytes atomic.Int64
+
+	// Lifecycle notifications for clients and rooms
+	events *events.Bus
+
+	// Which origins and source addresses may connect
+	policy atomic.Pointer[ConnPolicy]
+
+	// How clients are put into IP rooms
+	ipGrouping atomic.Pointer[IPGrouping]
+
+	// Reverse proxies whose X-Forwarded-For is believed
+	trustedProxies atomic.Pointer[[]netip.Prefix]
+
+	// Saves persistent public rooms; nil disables them
+	roomStore *roomStore
+
+	// Throttles guessing of public room codes
+	joinLimiter *joinLimiter
+
+	// Caps connections and transfer requests per source IP
+	rateLimiter *rateLimiter
+
+	// Largest transfers senders may offer
+	transferLimits TransferLimits
+
+	// Supplies the STUN/TURN servers advertised to clients on join
+	iceServers ICEServerProvider
+
+	// Receives panics recovered in client goroutines; nil lets them crash
+	panicHandler PanicHandler
+
 	logger *slog.Logger
+
+	// Closed when the hub shuts down
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-// NewHub creates a new Hub
-func NewHub(logger *slog.Logger) *Hub {
-	return &Hub{
+// ErrRoomNotFound is returned when joining a room code or alias that
+// doesn't exist
+var ErrRoomNotFound = errors.New("room not found")
+
+// ErrAliasTaken is returned when creating a room with a custom alias that
+// is already in use
+var ErrAliasTaken = errors.New("alias already in use")
+
+// ErrRoomFull is returned when joining a room at its peer limit
+var ErrRoomFull = errors.New("room is full")
+
+// How often public rooms are checked against their lifetime and idle timeout
+const roomExpiryInterval = 30 * time.Second
+
+// Attempts at finding an unused room code before giving up
+const maxCodeAttempts = 100
+
+// ICEServerProvider returns the ICE servers for a client that reached the
+// server at host (without port)
+type ICEServerProvider func(host, clientID string) []ICEServer
+
+// PanicHandler is called with a panic recovered in component where and the
+// stack at the point of recovery
+type PanicHandler func(where string, v any, stack []byte)
+
+// NewHub creates a new Hub that publishes client and room changes on bus
+func NewHub(logger *slog.Logger, bus *events.Bus) *Hub {
+	policy, _ := NewConnPolicy(nil, nil)
+	h := &Hub{
 		ipRooms:     make(map[string]*Room),
 		publicRooms: make(map[string]*Room),
+		roomAliases: make(map[string]string),
+		aliasExpiry: make(map[string]time.Time),
+		roomOpts:    DefaultRoomOptions,
 		clients:     make(map[string]*Client),
+		sessions:    make(map[string]*Client),
+		events:      bus,
+		joinLimiter: newJoinLimiter(DefaultJoinLimits),
+		rateLimiter: newRateLimiter(DefaultRateLimits),
 		logger:      logger,
+		done:        make(chan struct{}),
+	}
+	h.policy.Store(policy)
+	h.SetIPGrouping(DefaultIPGrouping)
+	return h
+}
+
+// SetICEServerProvider sets the source of the ice-servers message sent to
+// each client when it joins. It must be called before serving clients.
+func (h *Hub) SetICEServerProvider(p ICEServerProvider) {
+	h.iceServers = p
+}
+
+// SetConnPolicy replaces the default connection policy (same-origin, private
+// source addresses). It may be called while serving; connections already
+// open are kept.
+func (h *Hub) SetConnPolicy(p *ConnPolicy) {
+	if old := h.policy.Load(); old != nil {
+		p.rejectedOrigin.Add(old.rejectedOrigin.Load())
+		p.rejectedSubnet.Add(old.rejectedSubnet.Load())
+	}
+	h.policy.Store(p)
+}
+
+// SetJoinLimits replaces DefaultJoinLimits. It must be called before
+// serving clients.
+func (h *Hub) SetJoinLimits(l JoinLimits) {
+	h.joinLimiter = newJoinLimiter(l)
+}
+
+// SetRoomOptions replaces DefaultRoomOptions; o must be valid. It must be
+// called before serving clients.
+func (h *Hub) SetRoomOptions(o RoomOptions) {
+	h.roomOpts = o
+}
+
+// SetPanicHandler makes client goroutines recover from panics and pass them
+// to p instead of crashing the process. It must be called before serving
+// clients.
+func (h *Hub) SetPanicHandler(p PanicHandler) {
+	h.panicHandler = p
+}
+
+// recoverPanic must be deferred directly. It recovers a panic and hands it
+// to the panic handler, or lets it propagate when none is set.
+func (h *Hub) recoverPanic(where string) {
+	if h.panicHandler == nil {
+		return
+	}
+	if v := recover(); v != nil {
+		h.panicHandler(where, v, debug.Stack())
 	}
 }
 
 // HandleWebSocket handles WebSocket upgrade and client connection
 func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-h.done:
+		http.Error(w, "Server shutting down", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+	if !h.checkConnPolicy(w, r) {
+		return
+	}
+	ip := h.ClientIP(r)
+	if !h.acquireConn(w, ip) {
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		h.releaseConn(ip)
 		h.logger.Error("websocket upgrade failed", "error", err)
 		return
 	}
 
-	// Generate unique client ID
-	clientID := generateClientID()
-
-	// Get client IP
-	ip := r.Header.Get("X-Forwarded-For")
-	if ip == "" {
-		ip = r.RemoteAddr
-	}
+	t := &wsTransport{conn: conn}
+	client := NewClient(generateClientID(), t, h, ip, h.logger)
+	client.host = requestHost(r)
+	client.ipRoomID = h.ipGrouping.Load().roomID(client.ip, h.forwardedHeader(r))
+	h.register(client, "websocket")
 
-	client := NewClient(clientID, conn, h, ip, h.logger)
+	// Start read/write pumps
+	go t.writePump(client)
+	go t.readPump(client)
+}
 
-	// Register client
+// register adds a newly connected client to the hub
+func (h *Hub) register(client *Client, via string) {
+	client.via = via
 	h.clientsMu.Lock()
-	h.clients[clientID] = client
+	h.clients[client.id] = client
 	h.clientsMu.Unlock()
 
-	h.logger.Info("new client connected", "id", clientID, "ip", ip)
-
-	// Start read/write pumps
-	go client.WritePump()
-	go client.ReadPump()
+	h.logger.Info("new client connected", "id", client.id, "ip", client.ip, "transport", via)
+	h.events.Publish(events.ClientConnected, events.ClientEvent{ClientID: client.id, IP: client.ip})
 }
 
 // Unregister removes a client from all rooms and the hub
@@ -91,23 +247,24 @@ func (h *Hub) Unregister(client *Client) {
 		// Clean up empty IP rooms
 		if client.ipRoom.IsEmpty() {
 			h.ipRoomsMu.Lock()
-			delete(h.ipRooms, client.ipRoom.ID())
+			h.deleteIPRoomLocked(client.ipRoom)
 			h.ipRoomsMu.Unlock()
 		}
 	}
 
-	// Remove from public room
-	if client.publicRoom != nil {
+	// Remove from public room, unless the hub already closed it
+	if client.publicRoom != nil && !client.publicRoom.isClosed() {
 		client.publicRoom.RemoveClient(client.id)
 
 		// Notify other peers in the public room
 		msg, _ := NewPeerLeftMessage(client.id)
 		client.publicRoom.Broadcast(msg, client.id)
+		h.publishRoomEvent(events.RoomLeft, client.publicRoom, client.id)
 
 		// Clean up empty public rooms
-		if client.publicRoom.IsEmpty() {
+		if client.publicRoom.IsEmpty() && !client.publicRoom.persistent {
 			h.publicRoomsMu.Lock()
-			delete(h.publicRooms, client.publicRoom.ID())
+			h.deletePublicRoomLocked(client.publicRoom)
 			h.publicRoomsMu.Unlock()
 		}
 	}
@@ -116,22 +273,31 @@ func (h *Hub) Unregister(client *Client) {
 	h.clientsMu.Lock()
 	delete(h.clients, client.id)
 	h.clientsMu.Unlock()
+	h.releaseConn(client.ip)
 
-	// Close send channel
-	close(client.send)
+	// Stop draining spilled relay chunks, then close send channel
+	client.spill.Close()
+	client.closeSend()
 
 	h.logger.Info("client disconnected", "id", client.id)
+	h.events.Publish(events.ClientDisconnected, events.ClientEvent{
+		ClientID: client.id,
+		Name:     client.name,
+		Platform: client.platform,
+		IP:       client.ip,
+	})
 }
 
 // JoinIPRoom adds a client to their IP-based room
 func (h *Hub) JoinIPRoom(client *Client) {
-	roomID := ExtractIPRoomID(client.ip, "")
+	roomID := client.ipRoomID
 
 	h.ipRoomsMu.Lock()
 	room, exists := h.ipRooms[roomID]
 	if !exists {
 		room = NewRoom(roomID, false)
 		h.ipRooms[roomID] = room
+		h.publishRoomEvent(events.RoomCreated, room, client.id)
 	}
 	h.ipRoomsMu.Unlock()
 
@@ -145,46 +311,179 @@ func (h *Hub) JoinIPRoom(client *Client) {
 	// Send peer list to new client
 	peersMsg, _ := NewPeersMessage(peers)
 	client.Send(peersMsg)
+	sendChatHistory(client, room)
+	sendBoard(client, room)
 
 	// Notify existing peers about new client
 	joinedMsg, _ := NewPeerJoinedMessage(client.PeerInfo())
 	room.Broadcast(joinedMsg, client.id)
+
+	h.events.Publish(events.ClientJoined, events.ClientEvent{
+		ClientID: client.id,
+		Name:     client.name,
+		Platform: client.platform,
+		IP:       client.ip,
+		Room:     roomID,
+	})
+}
+
+// RoomRequest describes the public room a client asks for
+type RoomRequest struct {
+	Alias      string     // custom alias; a word alias is generated when empty
+	Limits     RoomLimits // can tighten the server's room limits
+	Persistent bool       // kept when empty and across restarts
 }
 
 // CreatePublicRoom creates a new public room and adds the client to it
-func (h *Hub) CreatePublicRoom(client *Client) string {
-	// Generate unique room code
+func (h *Hub) CreatePublicRoom(client *Client, req RoomRequest) (*Room, error) {
+	customAlias := req.Alias
+	if customAlias != "" {
+		if err := ValidateCustomAlias(customAlias); err != nil {
+			return nil, err
+		}
+	}
+	if req.Persistent && h.roomStore == nil {
+		return nil, errors.New("persistent rooms are not enabled")
+	}
+
+	h.publicRoomsMu.Lock()
+
+	if req.Persistent && h.persistentRoomCountLocked() >= maxPersistentRooms {
+		h.publicRoomsMu.Unlock()
+		return nil, ErrTooManyPersistentRooms
+	}
+
+	// Generate a unique room code, giving up if the code space is nearly
+	// exhausted
 	var code string
-	for {
-		code = GenerateRoomCode()
-		h.publicRoomsMu.RLock()
-		_, exists := h.publicRooms[code]
-		h.publicRoomsMu.RUnlock()
-		if !exists {
+	for attempt := 0; ; attempt++ {
+		if attempt == maxCodeAttempts {
+			h.publicRoomsMu.Unlock()
+			return nil, errors.New("no free room codes, try again later")
+		}
+		code = generateRoomCode(h.roomOpts)
+		if _, exists := h.publicRooms[code]; !exists {
 			break
 		}
 	}
 
+	alias := customAlias
+	if alias != "" {
+		if _, exists := h.roomAliases[alias]; exists {
+			h.publicRoomsMu.Unlock()
+			return nil, ErrAliasTaken
+		}
+		// A persistent room keeps its alias for as long as it exists
+		if !req.Persistent {
+			h.aliasExpiry[alias] = time.Now().Add(h.roomOpts.AliasTTL)
+		}
+	} else {
+		for attempt := 0; ; attempt++ {
+			if attempt == maxCodeAttempts {
+				h.publicRoomsMu.Unlock()
+				return nil, errors.New("no free room aliases, try again later")
+			}
+			alias = GenerateRoomAlias()
+			if _, exists := h.roomAliases[alias]; !exists {
+				break
+			}
+		}
+	}
+
 	room := NewRoom(code, true)
+	room.alias = alias
+	room.limits = h.roomOpts.limitsFor(req.Limits)
+	room.persistent = req.Persistent
 	room.AddClient(client)
 
-	h.publicRoomsMu.Lock()
 	h.publicRooms[code] = room
+	h.roomAliases[alias] = code
 	h.publicRoomsMu.Unlock()
 
+	if room.persistent {
+		h.saveRooms()
+	}
+
+	h.publishRoomEvent(events.RoomCreated, room, client.id)
+
 	client.publicRoom = room
 
-	return code
+	return room, nil
+}
+
+// expireAliases releases custom aliases past their TTL
+func (h *Hub) expireAliases() {
+	h.publicRoomsMu.Lock()
+	defer h.publicRoomsMu.Unlock()
+
+	now := time.Now()
+	for alias, expires := range h.aliasExpiry {
+		if now.Before(expires) {
+			continue
+		}
+		if room, ok := h.publicRooms[h.roomAliases[alias]]; ok {
+			room.clearAlias()
+		}
+		delete(h.roomAliases, alias)
+		delete(h.aliasExpiry, alias)
+		h.logger.Debug("room alias expired", "alias", alias)
+	}
 }
 
-// JoinPublicRoom adds a client to an existing public room
-func (h *Hub) JoinPublicRoom(client *Client, code string) error {
+// lookupPublicRoom finds a public room by its code or word alias
+func (h *Hub) lookupPublicRoom(key string) (*Room, bool) {
+	key = NormalizeRoomKey(key)
+
 	h.publicRoomsMu.RLock()
-	room, exists := h.publicRooms[code]
-	h.publicRoomsMu.RUnlock()
+	defer h.publicRoomsMu.RUnlock()
+
+	if code, ok := h.roomAliases[key]; ok {
+		key = code
+	}
+	room, exists := h.publicRooms[key]
+	return room, exists
+}
+
+// deletePublicRoomLocked removes a public room and its alias.
+// The caller must hold publicRoomsMu.
+func (h *Hub) deletePublicRoomLocked(room *Room) {
+	// The code may already belong to a newer room
+	if h.publicRooms[room.ID()] != room {
+		return
+	}
+	delete(h.publicRooms, room.ID())
+	if alias := room.Alias(); alias != "" {
+		delete(h.roomAliases, alias)
+		delete(h.aliasExpiry, alias)
+	}
+	h.publishRoomEvent(events.RoomClosed, room, "")
+}
+
+// deleteIPRoomLocked removes an IP room. The caller must hold ipRoomsMu.
+func (h *Hub) deleteIPRoomLocked(room *Room) {
+	delete(h.ipRooms, room.ID())
+	h.publishRoomEvent(events.RoomClosed, room, "")
+}
+
+// publishRoomEvent emits a room.* event on the hub's bus
+func (h *Hub) publishRoomEvent(kind events.Kind, room *Room, clientID string) {
+	h.events.Publish(kind, events.RoomEvent{
+		Room:     room.ID(),
+		Alias:    room.Alias(),
+		Public:   room.IsPublic(),
+		ClientID: clientID,
+	})
+}
 
+// JoinPublicRoom adds a client to an existing public room by code or alias
+func (h *Hub) JoinPublicRoom(client *Client, key string) error {
+	room, exists := h.lookupPublicRoom(key)
 	if !exists {
-		return errors.New("room not found")
+		return ErrRoomNotFound
+	}
+
+	if room.isFull() {
+		return ErrRoomFull
 	}
 
 	// Leave current public room if any
@@ -192,21 +491,31 @@ func (h *Hub) JoinPublicRoom(client *Client, code string) error {
 		h.LeavePublicRoom(client)
 	}
 
-	// Add to new room
-	room.AddClient(client)
+	// Add to new room, which may have filled up or closed meanwhile
+	if !room.tryAddClient(client) {
+		if room.isClosed() {
+			return ErrRoomNotFound
+		}
+		return ErrRoomFull
+	}
 	client.publicRoom = room
+	room.touch()
 
 	// Get existing peers in the public room
 	peers := room.GetPeerInfos(client.id)
 
 	// Send room joined message with peer list
-	joinedMsg, _ := NewRoomJoinedMessage(code, peers)
+	joinedMsg, _ := NewRoomJoinedMessage(room.ID(), room.Alias(), peers)
 	client.Send(joinedMsg)
+	sendChatHistory(client, room)
+	sendBoard(client, room)
 
 	// Notify existing peers about new client
 	peerJoinedMsg, _ := NewPeerJoinedMessage(client.PeerInfo())
 	room.Broadcast(peerJoinedMsg, client.id)
 
+	h.publishRoomEvent(events.RoomJoined, room, client.id)
+
 	return nil
 }
 
@@ -217,16 +526,21 @@ func (h *Hub) LeavePublicRoom(client *Client) {
 	}
 
 	room := client.publicRoom
+	if room.isClosed() {
+		client.publicRoom = nil
+		return
+	}
 	room.RemoveClient(client.id)
 
 	// Notify other peers
 	msg, _ := NewPeerLeftMessage(client.id)
 	room.Broadcast(msg, client.id)
+	h.publishRoomEvent(events.RoomLeft, room, client.id)
 
 	// Clean up empty public rooms
-	if room.IsEmpty() {
+	if room.IsEmpty() && !room.persistent {
 		h.publicRoomsMu.Lock()
-		delete(h.publicRooms, room.ID())
+		h.deletePublicRoomLocked(room)
 		h.publicRoomsMu.Unlock()
 	}
 
@@ -239,27 +553,145 @@ func (h *Hub) StartCleanup(interval time.Duration) {
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			h.cleanupEmptyRooms()
+		// Room lifetimes and idle timeouts need a finer check
+		expiry := time.NewTicker(roomExpiryInterval)
+		defer expiry.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				h.cleanupEmptyRooms()
+				h.joinLimiter.sweep()
+				h.rateLimiter.sweep()
+				h.expireAliases()
+			case <-expiry.C:
+				h.expireRooms()
+			case <-h.done:
+				return
+			}
 		}
 	}()
 }
 
+// expireRooms closes public rooms past their lifetime or idle timeout
+func (h *Hub) expireRooms() {
+	now := time.Now()
+	type expiredRoom struct {
+		room   *Room
+		reason string
+	}
+	var expired []expiredRoom
+
+	h.publicRoomsMu.Lock()
+	for _, room := range h.publicRooms {
+		if reason := room.expiryReason(now); reason != "" {
+			h.deletePublicRoomLocked(room)
+			expired = append(expired, expiredRoom{room, reason})
+		}
+	}
+	h.publicRoomsMu.Unlock()
+
+	save := false
+	for _, e := range expired {
+		h.closePublicRoom(e.room, e.reason)
+		save = save || e.room.persistent
+	}
+	if save {
+		h.saveRooms()
+	}
+}
+
+// CloseRoom closes a public room by code, persistent or not, and
+// reports whether it existed
+func (h *Hub) CloseRoom(code string) bool {
+	h.publicRoomsMu.Lock()
+	room, ok := h.publicRooms[NormalizeRoomKey(code)]
+	if ok {
+		h.deletePublicRoomLocked(room)
+	}
+	h.publicRoomsMu.Unlock()
+	if !ok {
+		return false
+	}
+
+	h.closePublicRoom(room, roomClosedByAdmin)
+	if room.persistent {
+		h.saveRooms()
+	}
+	return true
+}
+
+// closePublicRoom empties a room already removed from the hub and tells
+// its occupants. Each also sees the others leave, unless they share an IP
+// room and stay visible there.
+func (h *Hub) closePublicRoom(room *Room, reason string) {
+	occupants := room.close()
+	closedMsg, _ := NewRoomClosedMessage(room.ID(), reason)
+
+	for _, c := range occupants {
+		c.Send(closedMsg)
+		for _, other := range occupants {
+			if other == c || c.ipRoom != nil && c.ipRoom.GetClient(other.id) != nil {
+				continue
+			}
+			left, _ := NewPeerLeftMessage(other.id)
+			c.Send(left)
+		}
+	}
+
+	h.logger.Info("public room closed", "code", room.ID(), "reason", reason, "occupants", len(occupants))
+}
+
+// Close stops background work and disconnects every client with a
+// going-away close frame. It waits until all clients have unregistered
+// or ctx expires.
+func (h *Hub) Close(ctx context.Context) error {
+	h.closeOnce.Do(func() { close(h.done) })
+
+	h.clientsMu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for _, c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.clientsMu.RUnlock()
+
+	for _, c := range clients {
+		c.closeWithReason(websocket.CloseGoingAway, "server shutting down")
+	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		h.clientsMu.RLock()
+		remaining := len(h.clients)
+		h.clientsMu.RUnlock()
+		if remaining == 0 {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 func (h *Hub) cleanupEmptyRooms() {
 	// Clean IP rooms
 	h.ipRoomsMu.Lock()
-	for id, room := range h.ipRooms {
+	for _, room := range h.ipRooms {
 		if room.IsEmpty() {
-			delete(h.ipRooms, id)
+			h.deleteIPRoomLocked(room)
 		}
 	}
 	h.ipRoomsMu.Unlock()
 
 	// Clean public rooms
 	h.publicRoomsMu.Lock()
-	for id, room := range h.publicRooms {
-		if room.IsEmpty() {
-			delete(h.publicRooms, id)
+	for _, room := range h.publicRooms {
+		if room.IsEmpty() && !room.persistent {
+			h.deletePublicRoomLocked(room)
 		}
 	}
 	h.publicRoomsMu.Unlock()
@@ -280,10 +712,31 @@ func (h *Hub) Stats() map[string]int {
 	h.publicRoomsMu.RUnlock()
 
 	return map[string]int{
-		"clients":      clientCount,
-		"ip_rooms":     ipRoomCount,
-		"public_rooms": publicRoomCount,
+		"clients":                clientCount,
+		"ip_rooms":               ipRoomCount,
+		"public_rooms":           publicRoomCount,
+		"relay_spilled_messages": int(h.spillMetrics.spilledMessages.Load()),
+		"relay_spilled_bytes":    int(h.spillMetrics.spilledBytes.Load()),
+		"relay_spill_disk_bytes": int(h.spillMetrics.diskBytes.Load()),
+		"send_dropped_messages":  int(h.droppedMessages.Load()),
+		"relay_bytes":            int(h.relayedBytes.Load()),
+		"rejected_origin":        int(h.policy.Load().rejectedOrigin.Load()),
+		"rejected_subnet":        int(h.policy.Load().rejectedSubnet.Load()),
+		"join_throttled":         int(h.joinLimiter.throttled.Load()),
+		"join_lockouts":          int(h.joinLimiter.lockouts.Load()),
+		"rate_limited_conns":     int(h.rateLimiter.rejectedConns.Load()),
+		"rate_limited_transfers": int(h.rateLimiter.rejectedTransfers.Load()),
+	}
+}
+
+// requestHost returns the host the client used to reach the server, without
+// the port
+func requestHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		return strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]")
 	}
+	return host
 }
 
 func generateClientID() string {
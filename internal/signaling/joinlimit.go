@@ -0,0 +1,124 @@
+package signaling
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JoinLimits throttles join-room attempts with unknown codes, so the room
+// code space can't be enumerated. Failures are counted per client and per
+// source IP; crossing either limit within Window locks that key out.
+type JoinLimits struct {
+	ClientFailures int // failed joins allowed per client per window
+	IPFailures     int // failed joins allowed per IP; higher since devices behind NAT share one
+	Window         time.Duration
+	Lockout        time.Duration
+}
+
+// DefaultJoinLimits are used when none are configured
+var DefaultJoinLimits = JoinLimits{
+	ClientFailures: 5,
+	IPFailures:     20,
+	Window:         time.Minute,
+	Lockout:        5 * time.Minute,
+}
+
+// joinAttempts is the failure count of one client or IP
+type joinAttempts struct {
+	windowStart time.Time
+	failures    int
+	lockedUntil time.Time
+}
+
+// joinLimiter tracks failed join-room attempts
+type joinLimiter struct {
+	limits JoinLimits
+
+	mu       sync.Mutex
+	attempts map[string]*joinAttempts // "client:<id>" or "ip:<addr>"
+
+	throttled atomic.Int64 // attempts refused while locked out
+	lockouts  atomic.Int64
+}
+
+func newJoinLimiter(limits JoinLimits) *joinLimiter {
+	return &joinLimiter{limits: limits, attempts: make(map[string]*joinAttempts)}
+}
+
+// check returns how long c must wait before trying again, or 0
+func (l *joinLimiter) check(c *Client) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range joinKeys(c) {
+		if a, ok := l.attempts[key]; ok {
+			wait = max(wait, a.lockedUntil.Sub(now))
+		}
+	}
+	if wait > 0 {
+		l.throttled.Add(1)
+	}
+	return wait
+}
+
+// fail records a failed attempt by c and reports the keys it locked out
+func (l *joinLimiter) fail(c *Client) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var locked []string
+	for _, key := range joinKeys(c) {
+		a, ok := l.attempts[key]
+		if !ok || now.Sub(a.windowStart) >= l.limits.Window {
+			a = &joinAttempts{windowStart: now}
+			l.attempts[key] = a
+		}
+		a.failures++
+
+		limit := l.limits.ClientFailures
+		if strings.HasPrefix(key, "ip:") {
+			limit = l.limits.IPFailures
+		}
+		if a.failures >= limit && now.After(a.lockedUntil) {
+			a.lockedUntil = now.Add(l.limits.Lockout)
+			l.lockouts.Add(1)
+			locked = append(locked, key)
+		}
+	}
+	return locked
+}
+
+// sweep forgets entries whose window and lockout have both passed
+func (l *joinLimiter) sweep() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for key, a := range l.attempts {
+		if now.Sub(a.windowStart) >= l.limits.Window && now.After(a.lockedUntil) {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+// joinKeys returns the keys a client's attempts are counted under
+func joinKeys(c *Client) []string {
+	ip := c.ip
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return []string{"client:" + c.id, "ip:" + ip}
+}
+
+// retryMessage formats a lockout for the room-error sent to the client
+func retryMessage(wait time.Duration) string {
+	return fmt.Sprintf("too many attempts, try again in %d seconds", int(math.Ceil(wait.Seconds())))
+}
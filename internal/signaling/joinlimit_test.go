@@ -0,0 +1,86 @@
+package signaling
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testJoinLimits = JoinLimits{
+	ClientFailures: 3,
+	IPFailures:     5,
+	Window:         time.Minute,
+	Lockout:        5 * time.Minute,
+}
+
+func TestJoinLockout(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures map[string]int // failed joins by client ID, all from one IP
+		client   string
+		locked   bool
+	}{
+		{name: "under the client limit", failures: map[string]int{"a": 2}, client: "a"},
+		{name: "client limit reached", failures: map[string]int{"a": 3}, client: "a", locked: true},
+		{name: "other client on the IP", failures: map[string]int{"a": 3, "b": 1}, client: "b"},
+		{name: "IP limit reached", failures: map[string]int{"a": 3, "b": 2}, client: "b", locked: true},
+		{name: "new client on a locked IP", failures: map[string]int{"a": 3, "b": 2}, client: "c", locked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newJoinLimiter(testJoinLimits)
+			port := 40000
+			for id, n := range tt.failures {
+				// Each client connects from its own port
+				c := &Client{id: id, ip: "192.168.1.20:" + strconv.Itoa(port)}
+				port++
+				for range n {
+					l.fail(c)
+				}
+			}
+
+			wait := l.check(&Client{id: tt.client, ip: "192.168.1.20:50000"})
+			if tt.locked && (wait <= 0 || wait > testJoinLimits.Lockout) {
+				t.Errorf("wait = %v, want up to %v", wait, testJoinLimits.Lockout)
+			}
+			if !tt.locked && wait != 0 {
+				t.Errorf("wait = %v, want 0", wait)
+			}
+		})
+	}
+}
+
+func TestJoinSweep(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		attempts joinAttempts
+		kept     bool
+	}{
+		{name: "window open", attempts: joinAttempts{windowStart: now, failures: 1}, kept: true},
+		{name: "window passed", attempts: joinAttempts{windowStart: now.Add(-time.Minute), failures: 1}},
+		{
+			name:     "locked out",
+			attempts: joinAttempts{windowStart: now.Add(-time.Minute), failures: 3, lockedUntil: now.Add(time.Minute)},
+			kept:     true,
+		},
+		{
+			name:     "lockout over",
+			attempts: joinAttempts{windowStart: now.Add(-time.Hour), failures: 3, lockedUntil: now.Add(-time.Second)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newJoinLimiter(testJoinLimits)
+			a := tt.attempts
+			l.attempts["client:a"] = &a
+
+			l.sweep()
+			if _, ok := l.attempts["client:a"]; ok != tt.kept {
+				t.Errorf("kept = %v, want %v", ok, tt.kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package signaling
+
+import "strings"
+
+// Longest locale tag we keep; real BCP 47 tags in the wild are far shorter
+const maxLocaleLength = 35
+
+// NormalizeLocale sanitizes a client-supplied BCP 47 language tag.
+// It accepts letters, digits and '-' or '_' separators, canonicalizes
+// separators to '-', lower-cases the language and upper-cases a two-letter
+// region ("en_us" becomes "en-US"). Anything else yields "".
+func NormalizeLocale(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if tag == "" || len(tag) > maxLocaleLength {
+		return ""
+	}
+
+	parts := strings.FieldsFunc(tag, func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) == 0 {
+		return ""
+	}
+
+	for i, part := range parts {
+		for _, r := range part {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+				return ""
+			}
+		}
+
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(part)
+		case len(part) == 2:
+			parts[i] = strings.ToUpper(part)
+		}
+	}
+
+	return strings.Join(parts, "-")
+}
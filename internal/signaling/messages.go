@@ -2,6 +2,19 @@ package signaling
 
 import "encoding/json"
 
+// Signaling protocol versions understood by the hub
+const (
+	// ProtocolVersion is the protocol spoken by this hub and its bundled web UI
+	ProtocolVersion = 1
+
+	// MinProtocolVersion is the oldest client protocol the hub still accepts
+	MinProtocolVersion = 1
+)
+
+// CloseUnsupportedProtocol is the WebSocket close code sent to clients
+// whose protocol version is below MinProtocolVersion
+const CloseUnsupportedProtocol = 4001
+
 // Message types
 const (
 	TypeJoin             = "join"
@@ -13,6 +26,8 @@ const (
 	TypeIceCandidate     = "ice-candidate"
 	TypeTransferRequest  = "transfer-request"
 	TypeTransferResponse = "transfer-response"
+	TypeTransferPause    = "transfer-pause"
+	TypeTransferResume   = "transfer-resume"
 	TypePing             = "ping"
 	TypePong             = "pong"
 	TypeCreateRoom       = "create-room"
@@ -22,7 +37,21 @@ const (
 	TypeRoomJoined       = "room-joined"
 	TypeRoomLeft         = "room-left"
 	TypeRoomError        = "room-error"
+	TypeRoomClosed       = "room-closed"
 	TypeRelayChunk       = "relay-chunk"
+	TypeRelayNack        = "relay-nack"
+	TypeRelayAck         = "relay-ack"
+	TypeRelayKey         = "relay-key"
+	TypeIceServers       = "ice-servers"
+	TypeNudge            = "nudge"
+	TypeNudgeRejected    = "nudge-rejected"
+	TypeChat             = "chat"
+	TypeChatHistory      = "chat-history"
+	TypeClipboard        = "clipboard"
+	TypeCustom           = "custom"
+	TypeBoard            = "board"
+	TypeBoardPost        = "board-post"
+	TypeBoardDelete      = "board-delete"
 )
 
 // Message is the base structure for all WebSocket messages
@@ -35,15 +64,28 @@ type Message struct {
 
 // JoinPayload is sent when a client connects
 type JoinPayload struct {
-	Name     string `json:"name"`
-	Platform string `json:"platform"`
+	Name            string `json:"name"`
+	Platform        string `json:"platform"`
+	Locale          string `json:"locale,omitempty"` // BCP 47 tag, e.g. "de-DE"
+	ProtocolVersion int    `json:"protocolVersion"`
+	Version         string `json:"version,omitempty"` // client build, informational
+
+	// Optional features the client supports, e.g. CapabilityBinaryRelay
+	// or CapabilityE2E
+	Capabilities []string `json:"capabilities,omitempty"`
 }
 
-// PeerInfo represents a peer in the network
+// PeerInfo represents a peer in the network. ProtocolVersion and
+// Capabilities come from the peer's join message, so peers can agree on
+// features before a transfer rather than fail during one.
 type PeerInfo struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Platform string `json:"platform"`
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Platform        string   `json:"platform"`
+	Locale          string   `json:"locale,omitempty"`
+	Version         string   `json:"version,omitempty"`
+	ProtocolVersion int      `json:"protocolVersion,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty"`
 }
 
 // PeersPayload is the list of peers in a room
@@ -79,33 +121,211 @@ type FileInfo struct {
 	Name string `json:"name"`
 	Size int64  `json:"size"`
 	Type string `json:"type"`
+	Path string `json:"path,omitempty"` // relative path inside a sent folder, "/"-separated
 }
 
 // TransferRequestPayload is sent to request a file transfer
 type TransferRequestPayload struct {
-	TransferID string     `json:"transferId"`
-	Files      []FileInfo `json:"files"`
-	TotalSize  int64      `json:"totalSize"`
+	TransferID string           `json:"transferId"`
+	Files      []FileInfo       `json:"files"`
+	TotalSize  int64            `json:"totalSize"`
+	Summary    *TransferSummary `json:"summary,omitempty"` // set for multi-file sends
+	Previews   []FilePreview    `json:"previews,omitempty"`
+}
+
+// FilePreview is a small thumbnail of an offered image, shown to the
+// receiver before accepting
+type FilePreview struct {
+	FileIndex int    `json:"fileIndex"`
+	Type      string `json:"type"` // image/jpeg, image/png or image/webp
+	Data      string `json:"data"` // base64 encoded
+}
+
+// TransferSummary describes a multi-file or folder send so the receiver can
+// judge it before accepting. The sender computes it; the hub checks that it
+// matches the file list.
+type TransferSummary struct {
+	FileCount   int         `json:"fileCount"`
+	TotalSize   int64       `json:"totalSize"`
+	LargestName string      `json:"largestName"`
+	LargestSize int64       `json:"largestSize"`
+	Types       []TypeCount `json:"types"` // per category, e.g. "image", "video"
+}
+
+// TypeCount is the number and total size of files in one category
+type TypeCount struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+	Size     int64  `json:"size"`
 }
 
 // TransferResponsePayload is the response to a transfer request
 type TransferResponsePayload struct {
 	TransferID string `json:"transferId"`
 	Accepted   bool   `json:"accepted"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// TransferControlPayload pauses or resumes a transfer in progress. The
+// receiver sends it; the sender stops or continues from its current offset.
+type TransferControlPayload struct {
+	TransferID string `json:"transferId"`
+}
+
+// CreateRoomPayload optionally names the room to create, e.g.
+// "marketing-standup", tightens the server's room limits, or asks for the
+// room to be kept
+type CreateRoomPayload struct {
+	Alias       string `json:"alias,omitempty"`
+	MaxPeers    int    `json:"maxPeers,omitempty"`
+	Lifetime    int    `json:"lifetime,omitempty"`    // seconds
+	IdleTimeout int    `json:"idleTimeout,omitempty"` // seconds
+	Persistent  bool   `json:"persistent,omitempty"`  // survive emptying and restarts
+}
+
+// RoomClosedPayload tells occupants the hub closed their public room
+type RoomClosedPayload struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"` // "expired", "idle" or "closed"
 }
 
 // RoomCodePayload for public room operations
 type RoomCodePayload struct {
-	Code string `json:"code"`
+	Code  string `json:"code"`
+	Alias string `json:"alias,omitempty"`
 }
 
 // RelayChunkPayload for WebSocket relay fallback
 type RelayChunkPayload struct {
+	TransferID  string `json:"transferId"`
+	FileIndex   int    `json:"fileIndex"`
+	ChunkIndex  int    `json:"chunkIndex"`
+	TotalChunks int    `json:"totalChunks"`
+	Data        string `json:"data"` // base64 encoded
+	CRC         uint32 `json:"crc"`  // CRC-32 (IEEE) of the decoded chunk
+	IsLast      bool   `json:"isLast"`
+	Encrypted   bool   `json:"encrypted,omitempty"` // Data is AES-GCM ciphertext
+}
+
+// ChunkRange is an inclusive range of chunk indexes within one file
+type ChunkRange struct {
+	FileIndex int `json:"fileIndex"`
+	Start     int `json:"start"`
+	End       int `json:"end"`
+}
+
+// RelayNackPayload asks the sender to resend relay chunks that were
+// missing or failed their CRC check
+type RelayNackPayload struct {
+	TransferID string       `json:"transferId"`
+	Missing    []ChunkRange `json:"missing"`
+}
+
+// RelayAckPayload reports how many distinct chunks of a relayed transfer
+// the receiver holds. Senders keep a bounded window of unacknowledged
+// chunks, so a slow receiver slows the sender down instead of piling up
+// chunks in the hub.
+type RelayAckPayload struct {
+	TransferID string `json:"transferId"`
+	Received   int    `json:"received"`
+}
+
+// RelayKeyPayload carries a peer's ephemeral X25519 public key for a
+// relayed transfer. Both sides derive an AES-GCM key from the exchange and
+// relay only ciphertext, so the hub cannot read the files. A reply without
+// a key means the receiver cannot decrypt and chunks go out in the clear.
+type RelayKeyPayload struct {
 	TransferID string `json:"transferId"`
-	FileIndex  int    `json:"fileIndex"`
-	ChunkIndex int    `json:"chunkIndex"`
-	Data       string `json:"data"` // base64 encoded
-	IsLast     bool   `json:"isLast"`
+	PublicKey  string `json:"publicKey,omitempty"` // base64 raw key
+}
+
+// ICEServer is one entry of an RTCPeerConnection iceServers list
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// IceServersPayload tells a client which STUN/TURN servers to use
+type IceServersPayload struct {
+	IceServers []ICEServer `json:"iceServers"`
+}
+
+// NudgePayload asks the target's user to look at a pending transfer
+type NudgePayload struct {
+	TransferID string `json:"transferId,omitempty"`
+}
+
+// NudgeRejectedPayload tells a sender how long to wait before nudging again
+type NudgeRejectedPayload struct {
+	RetryAfter int `json:"retryAfter"` // seconds
+}
+
+// ChatPayload is a short text message. Clients send only the text; the
+// hub fills in the rest so senders can't impersonate each other.
+type ChatPayload struct {
+	Text   string `json:"text"`
+	Name   string `json:"name,omitempty"`   // sender's device name
+	Time   int64  `json:"time,omitempty"`   // unix milliseconds
+	Direct bool   `json:"direct,omitempty"` // sent to one peer, not the room
+}
+
+// ChatEntry is a chat message kept in a room's history
+type ChatEntry struct {
+	PeerID string `json:"peerId"`
+	ChatPayload
+}
+
+// ChatHistoryPayload holds a room's recent chat messages, oldest first
+type ChatHistoryPayload struct {
+	Room     string      `json:"room"`
+	Messages []ChatEntry `json:"messages"`
+}
+
+// ClipboardPayload is clipboard text pushed to a peer or room. Receivers
+// decide whether to apply it; the hub only checks its size and sets Name.
+type ClipboardPayload struct {
+	Text string `json:"text"`
+	Name string `json:"name,omitempty"` // sender's device name
+}
+
+// CustomPayload is an application-defined message, letting other front
+// ends build features like polls or reactions on the hub. The hub checks
+// the namespace and size and relays Data untouched.
+type CustomPayload struct {
+	Namespace string          `json:"namespace"` // e.g. "com.example.polls"
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// BoardPost is a posting on a room's bulletin board. Clients send Text,
+// File or both; the hub fills in the rest.
+type BoardPost struct {
+	ID      string     `json:"id,omitempty"`
+	PeerID  string     `json:"peerId,omitempty"`
+	Name    string     `json:"name,omitempty"`
+	Text    string     `json:"text,omitempty"`
+	File    *BoardFile `json:"file,omitempty"`
+	Time    int64      `json:"time,omitempty"`    // unix milliseconds
+	Expires int64      `json:"expires,omitempty"` // unix milliseconds
+	Own     bool       `json:"own,omitempty"`     // set for the recipient's own posts
+}
+
+// BoardFile is a small file attached to a board post
+type BoardFile struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+	Data string `json:"data"` // base64 encoded
+}
+
+// BoardPayload lists a room's current board posts, oldest first
+type BoardPayload struct {
+	Room  string      `json:"room"`
+	Posts []BoardPost `json:"posts"`
+}
+
+// BoardDeletePayload removes a board post
+type BoardDeletePayload struct {
+	ID string `json:"id"`
 }
 
 // Helper functions to create messages
@@ -134,19 +354,20 @@ func NewPeerLeftMessage(peerID string) ([]byte, error) {
 	})
 }
 
-func NewRoomCreatedMessage(code string) ([]byte, error) {
-	payload, _ := json.Marshal(RoomCodePayload{Code: code})
+func NewRoomCreatedMessage(code, alias string) ([]byte, error) {
+	payload, _ := json.Marshal(RoomCodePayload{Code: code, Alias: alias})
 	return json.Marshal(Message{
 		Type:    TypeRoomCreated,
 		Payload: payload,
 	})
 }
 
-func NewRoomJoinedMessage(code string, peers []PeerInfo) ([]byte, error) {
+func NewRoomJoinedMessage(code, alias string, peers []PeerInfo) ([]byte, error) {
 	payload, _ := json.Marshal(struct {
 		Code  string     `json:"code"`
+		Alias string     `json:"alias,omitempty"`
 		Peers []PeerInfo `json:"peers"`
-	}{Code: code, Peers: peers})
+	}{Code: code, Alias: alias, Peers: peers})
 	return json.Marshal(Message{
 		Type:    TypeRoomJoined,
 		Payload: payload,
@@ -163,6 +384,107 @@ func NewRoomErrorMessage(err string) ([]byte, error) {
 	})
 }
 
+// NewRoomClosedMessage tells an occupant why their public room was closed
+func NewRoomClosedMessage(code, reason string) ([]byte, error) {
+	payload, _ := json.Marshal(RoomClosedPayload{Code: code, Reason: reason})
+	return json.Marshal(Message{
+		Type:    TypeRoomClosed,
+		Payload: payload,
+	})
+}
+
+// NewTransferRejectedMessage builds a transfer-response rejecting a request
+// on behalf of targetID, used when the hub refuses to relay it
+func NewTransferRejectedMessage(targetID, transferID, reason string) ([]byte, error) {
+	payload, _ := json.Marshal(TransferResponsePayload{
+		TransferID: transferID,
+		Accepted:   false,
+		Reason:     reason,
+	})
+	return json.Marshal(Message{
+		Type:    TypeTransferResponse,
+		PeerID:  targetID,
+		Payload: payload,
+	})
+}
+
+func NewIceServersMessage(servers []ICEServer) ([]byte, error) {
+	payload, _ := json.Marshal(IceServersPayload{IceServers: servers})
+	return json.Marshal(Message{
+		Type:    TypeIceServers,
+		Payload: payload,
+	})
+}
+
+func NewNudgeMessage(fromID, transferID string) ([]byte, error) {
+	payload, _ := json.Marshal(NudgePayload{TransferID: transferID})
+	return json.Marshal(Message{
+		Type:    TypeNudge,
+		PeerID:  fromID,
+		Payload: payload,
+	})
+}
+
+func NewNudgeRejectedMessage(targetID string, retryAfter int) ([]byte, error) {
+	payload, _ := json.Marshal(NudgeRejectedPayload{RetryAfter: retryAfter})
+	return json.Marshal(Message{
+		Type:    TypeNudgeRejected,
+		PeerID:  targetID,
+		Payload: payload,
+	})
+}
+
+func NewChatMessage(entry ChatEntry) ([]byte, error) {
+	payload, _ := json.Marshal(entry.ChatPayload)
+	return json.Marshal(Message{
+		Type:    TypeChat,
+		PeerID:  entry.PeerID,
+		Payload: payload,
+	})
+}
+
+func NewChatHistoryMessage(room string, entries []ChatEntry) ([]byte, error) {
+	payload, _ := json.Marshal(ChatHistoryPayload{Room: room, Messages: entries})
+	return json.Marshal(Message{
+		Type:    TypeChatHistory,
+		Payload: payload,
+	})
+}
+
+func NewClipboardMessage(fromID, name, text string) ([]byte, error) {
+	payload, _ := json.Marshal(ClipboardPayload{Text: text, Name: name})
+	return json.Marshal(Message{
+		Type:    TypeClipboard,
+		PeerID:  fromID,
+		Payload: payload,
+	})
+}
+
+func NewBoardMessage(room string, posts []BoardPost) ([]byte, error) {
+	payload, _ := json.Marshal(BoardPayload{Room: room, Posts: posts})
+	return json.Marshal(Message{
+		Type:    TypeBoard,
+		Payload: payload,
+	})
+}
+
+func NewBoardPostMessage(post BoardPost) ([]byte, error) {
+	payload, _ := json.Marshal(post)
+	return json.Marshal(Message{
+		Type:    TypeBoardPost,
+		PeerID:  post.PeerID,
+		Payload: payload,
+	})
+}
+
+func NewBoardDeleteMessage(id string) ([]byte, error) {
+	payload, _ := json.Marshal(BoardDeletePayload{ID: id})
+	return json.Marshal(Message{
+		Type:    TypeBoardDelete,
+		Payload: payload,
+	})
+}
+
 func NewPongMessage() []byte {
 	msg, _ := json.Marshal(Message{Type: TypePong})
 	return msg
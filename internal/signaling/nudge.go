@@ -0,0 +1,48 @@
+package signaling
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// A client may nudge the same peer at most once per nudgeInterval
+const nudgeInterval = 15 * time.Second
+
+// handleNudge forwards a nudge to its target, rate limited per target so a
+// sender can't flood someone with notifications
+func (c *Client) handleNudge(msg Message) {
+	if msg.TargetID == "" {
+		return
+	}
+
+	var payload NudgePayload
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			c.logger.Warn("failed to unmarshal nudge payload", "error", err)
+			return
+		}
+	}
+
+	target := c.findPeer(msg.TargetID)
+	if target == nil {
+		c.logger.Debug("nudge target not found", "targetID", msg.TargetID)
+		return
+	}
+
+	now := time.Now()
+	if last, ok := c.lastNudge[target.id]; ok {
+		if wait := nudgeInterval - now.Sub(last); wait > 0 {
+			rejected, _ := NewNudgeRejectedMessage(target.id, int(math.Ceil(wait.Seconds())))
+			c.Send(rejected)
+			return
+		}
+	}
+	c.lastNudge[target.id] = now
+
+	// Only the transfer ID is forwarded
+	nudge, _ := NewNudgeMessage(c.id, payload.TransferID)
+	target.Send(nudge)
+
+	c.logger.Debug("nudge sent", "from", c.id, "to", target.id)
+}
@@ -0,0 +1,136 @@
+package signaling
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultSubnets are the source networks allowed to connect when none are
+// configured: RFC 1918, loopback, link-local and IPv6 unique local addresses
+var DefaultSubnets = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"::1/128",
+	"fe80::/10",
+	"fc00::/7",
+}
+
+// ConnPolicy decides which requests may open a signaling connection. A
+// request must come from an allowed subnet and, when it carries an Origin
+// header, from the server's own origin or one listed explicitly.
+type ConnPolicy struct {
+	origins    map[string]bool
+	anyOrigin  bool
+	subnets    []*net.IPNet
+	anySubnets bool
+
+	rejectedOrigin atomic.Int64
+	rejectedSubnet atomic.Int64
+}
+
+// NewConnPolicy builds a policy from extra allowed origins ("*" for any)
+// and source subnets in CIDR form. No subnets means DefaultSubnets.
+func NewConnPolicy(origins, subnets []string) (*ConnPolicy, error) {
+	p := &ConnPolicy{origins: make(map[string]bool)}
+
+	for _, o := range origins {
+		if o == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid origin %q: want scheme://host[:port]", o)
+		}
+		p.origins[normalizeOrigin(u)] = true
+	}
+
+	if len(subnets) == 0 {
+		subnets = DefaultSubnets
+	}
+	for _, s := range subnets {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet %q: %w", s, err)
+		}
+		if ones, _ := ipNet.Mask.Size(); ones == 0 {
+			p.anySubnets = true
+		}
+		p.subnets = append(p.subnets, ipNet)
+	}
+
+	return p, nil
+}
+
+// allow checks r against the policy, counting rejections
+func (p *ConnPolicy) allow(r *http.Request) error {
+	if !p.allowSource(r.RemoteAddr) {
+		p.rejectedSubnet.Add(1)
+		return fmt.Errorf("source address %s not allowed", r.RemoteAddr)
+	}
+	if origin := r.Header.Get("Origin"); origin != "" && !p.allowOrigin(origin, r.Host) {
+		p.rejectedOrigin.Add(1)
+		return fmt.Errorf("origin %s not allowed", origin)
+	}
+	return nil
+}
+
+// allowSource checks the address of the TCP peer. X-Forwarded-For is not
+// consulted since any client can set it; behind a reverse proxy this is
+// the proxy's address.
+func (p *ConnPolicy) allowSource(remoteAddr string) bool {
+	if p.anySubnets {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range p.subnets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowOrigin accepts the server's own origin and the configured ones
+func (p *ConnPolicy) allowOrigin(origin, host string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, host) {
+		return true
+	}
+	return p.origins[normalizeOrigin(u)]
+}
+
+func normalizeOrigin(u *url.URL) string {
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// checkConnPolicy rejects requests the hub's connection policy does not
+// allow, answering with 403
+func (h *Hub) checkConnPolicy(w http.ResponseWriter, r *http.Request) bool {
+	if err := h.policy.Load().allow(r); err != nil {
+		h.logger.Warn("signaling connection rejected", "remote", r.RemoteAddr, "error", err)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package signaling
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// SetTrustedProxies sets the reverse proxies, in CIDR form, whose
+// forwarding headers are believed. Requests from anywhere else are
+// placed by their socket address, since any client can send the headers.
+// It may be called while serving.
+func (h *Hub) SetTrustedProxies(cidrs []string) error {
+	var prefixes []netip.Prefix
+	for _, c := range cidrs {
+		p, err := netip.ParsePrefix(c)
+		if err != nil {
+			// Allow a bare address for a single proxy
+			addr, aerr := netip.ParseAddr(c)
+			if aerr != nil {
+				return fmt.Errorf("invalid trusted proxy %q: %w", c, err)
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	h.trustedProxies.Store(&prefixes)
+	return nil
+}
+
+// isTrustedProxy reports whether addr ("host" or "host:port") is one of
+// the trusted proxies
+func (h *Hub) isTrustedProxy(addr string) bool {
+	trusted := h.trustedProxies.Load()
+	if trusted == nil || len(*trusted) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap().WithZone("")
+	for _, p := range *trusted {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromTrustedProxy reports whether r was relayed by a trusted proxy
+func (h *Hub) FromTrustedProxy(r *http.Request) bool {
+	return h.isTrustedProxy(r.RemoteAddr)
+}
+
+// ClientIP returns the address a request came from. X-Forwarded-For is
+// only read when the request comes from a trusted proxy, and then from
+// the right, skipping further trusted proxies, so a client can't prepend
+// an address of its choosing.
+func (h *Hub) ClientIP(r *http.Request) string {
+	if !h.isTrustedProxy(r.RemoteAddr) {
+		return r.RemoteAddr
+	}
+
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	client := r.RemoteAddr
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		client = hop
+		if !h.isTrustedProxy(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedHeader returns the headers a trusted proxy set on r, or nil
+// when r didn't come through one
+func (h *Hub) forwardedHeader(r *http.Request) http.Header {
+	if !h.isTrustedProxy(r.RemoteAddr) {
+		return nil
+	}
+	return r.Header
+}
@@ -0,0 +1,86 @@
+package signaling
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted []string
+		remote  string
+		xff     []string // X-Forwarded-For header lines
+		want    string
+	}{
+		{name: "no proxies trusted", remote: "203.0.113.5:4000", xff: []string{"1.2.3.4"}, want: "203.0.113.5:4000"},
+		{
+			name:    "untrusted sender",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "203.0.113.5:4000",
+			xff:     []string{"1.2.3.4"},
+			want:    "203.0.113.5:4000",
+		},
+		{
+			name:    "trusted proxy",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "10.0.0.1:4000",
+			xff:     []string{"1.2.3.4"},
+			want:    "1.2.3.4",
+		},
+		{
+			name:    "spoofed address prepended",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "10.0.0.1:4000",
+			xff:     []string{"6.6.6.6, 1.2.3.4"},
+			want:    "1.2.3.4",
+		},
+		{
+			name:    "chain of trusted proxies",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "10.0.0.1:4000",
+			xff:     []string{"6.6.6.6, 1.2.3.4", "10.0.0.2"},
+			want:    "1.2.3.4",
+		},
+		{
+			name:    "bare proxy address",
+			trusted: []string{"10.0.0.1"},
+			remote:  "10.0.0.1:4000",
+			xff:     []string{"1.2.3.4"},
+			want:    "1.2.3.4",
+		},
+		{
+			name:    "IPv4-mapped proxy",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "[::ffff:10.0.0.1]:4000",
+			xff:     []string{"2001:db8::1"},
+			want:    "2001:db8::1",
+		},
+		{
+			name:    "garbage hop",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "10.0.0.1:4000",
+			xff:     []string{"1.2.3.4, not-an-ip"},
+			want:    "10.0.0.1:4000",
+		},
+		{name: "no header", trusted: []string{"10.0.0.0/8"}, remote: "10.0.0.1:4000", want: "10.0.0.1:4000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Hub{}
+			if err := h.SetTrustedProxies(tt.trusted); err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest("GET", "/ws", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			if got := h.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
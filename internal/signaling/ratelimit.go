@@ -0,0 +1,134 @@
+package signaling
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimits bound what a single source IP can do on the hub, so one
+// misbehaving device can't crowd out the rest. Zero values mean no limit.
+type RateLimits struct {
+	ConnectionsPerIP          int // open WebSocket and HTTP fallback connections
+	TransferRequestsPerMinute int // transfer requests sent, from all of the IP's clients
+}
+
+// DefaultRateLimits are used when none are configured. They leave room
+// for a household behind one NAT address.
+var DefaultRateLimits = RateLimits{
+	ConnectionsPerIP:          32,
+	TransferRequestsPerMinute: 60,
+}
+
+// rateLimiter counts connections and transfer requests per IP
+type rateLimiter struct {
+	limits RateLimits
+
+	mu        sync.Mutex
+	conns     map[string]int
+	transfers map[string]*rateWindow
+
+	rejectedConns     atomic.Int64
+	rejectedTransfers atomic.Int64
+}
+
+// rateWindow counts events in the minute starting at start
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limits RateLimits) *rateLimiter {
+	return &rateLimiter{
+		limits:    limits,
+		conns:     make(map[string]int),
+		transfers: make(map[string]*rateWindow),
+	}
+}
+
+// SetRateLimits replaces DefaultRateLimits. It must be called before
+// serving clients.
+func (h *Hub) SetRateLimits(l RateLimits) {
+	h.rateLimiter = newRateLimiter(l)
+}
+
+// acquireConn counts a new connection from addr, refusing it with a 429
+// when the IP already has its limit open. Each acquired connection must
+// be released.
+func (h *Hub) acquireConn(w http.ResponseWriter, addr string) bool {
+	l := h.rateLimiter
+	ip := ipOf(addr)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limits.ConnectionsPerIP > 0 && l.conns[ip] >= l.limits.ConnectionsPerIP {
+		l.rejectedConns.Add(1)
+		h.logger.Warn("too many connections", "ip", ip, "limit", l.limits.ConnectionsPerIP)
+		http.Error(w, "Too many connections from your address", http.StatusTooManyRequests)
+		return false
+	}
+	l.conns[ip]++
+	return true
+}
+
+// releaseConn forgets a connection counted by acquireConn
+func (h *Hub) releaseConn(addr string) {
+	l := h.rateLimiter
+	ip := ipOf(addr)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns[ip] <= 1 {
+		delete(l.conns, ip)
+	} else {
+		l.conns[ip]--
+	}
+}
+
+// allowTransfer counts a transfer request from addr and reports whether
+// it is within the per-minute limit
+func (l *rateLimiter) allowTransfer(addr string) bool {
+	if l.limits.TransferRequestsPerMinute <= 0 {
+		return true
+	}
+	ip := ipOf(addr)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	win, ok := l.transfers[ip]
+	if !ok || now.Sub(win.start) >= time.Minute {
+		win = &rateWindow{start: now}
+		l.transfers[ip] = win
+	}
+	if win.count >= l.limits.TransferRequestsPerMinute {
+		l.rejectedTransfers.Add(1)
+		return false
+	}
+	win.count++
+	return true
+}
+
+// sweep forgets transfer windows that have ended
+func (l *rateLimiter) sweep() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for ip, win := range l.transfers {
+		if now.Sub(win.start) >= time.Minute {
+			delete(l.transfers, ip)
+		}
+	}
+}
+
+// ipOf drops the port from a client address, so a device's connections
+// are counted together
+func ipOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
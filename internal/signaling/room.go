@@ -2,27 +2,114 @@ package signaling
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
-	"net"
+	"math/big"
+	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Room represents a group of peers that can see each other
 type Room struct {
 	id       string
+	alias    string
 	isPublic bool
 	clients  map[string]*Client
 	mu       sync.RWMutex
+
+	// Settings of a public room, fixed at creation
+	limits     RoomLimits
+	persistent bool // kept when empty and saved across restarts
+	created    time.Time
+
+	lastActive atomic.Int64 // unix nanoseconds
+	closed     atomic.Bool
+
+	// Recent chat messages, oldest first; guarded by mu
+	chat []ChatEntry
+
+	// Bulletin board posts, oldest first; guarded by mu
+	board []BoardPost
+}
+
+// RoomLimits bound a public room. Zero values mean no limit.
+type RoomLimits struct {
+	MaxPeers    int
+	Lifetime    time.Duration // closed this long after creation
+	IdleTimeout time.Duration // closed after this long without activity
 }
 
+// Reasons sent with room-closed
+const (
+	roomClosedExpired = "expired"
+	roomClosedIdle    = "idle"
+	roomClosedByAdmin = "closed"
+)
+
 // NewRoom creates a new room
 func NewRoom(id string, isPublic bool) *Room {
-	return &Room{
+	r := &Room{
 		id:       id,
 		isPublic: isPublic,
 		clients:  make(map[string]*Client),
+		created:  time.Now(),
+	}
+	r.touch()
+	return r
+}
+
+// touch records activity, postponing idle expiry
+func (r *Room) touch() {
+	r.lastActive.Store(time.Now().UnixNano())
+}
+
+// expiryReason returns why the room should be closed at now, or ""
+func (r *Room) expiryReason(now time.Time) string {
+	if r.limits.Lifetime > 0 && now.Sub(r.created) >= r.limits.Lifetime {
+		return roomClosedExpired
+	}
+	if r.limits.IdleTimeout > 0 && now.Sub(time.Unix(0, r.lastActive.Load())) >= r.limits.IdleTimeout {
+		return roomClosedIdle
+	}
+	return ""
+}
+
+// isFull reports whether the room has reached its peer limit
+func (r *Room) isFull() bool {
+	return r.limits.MaxPeers > 0 && r.ClientCount() >= r.limits.MaxPeers
+}
+
+// tryAddClient adds a client unless the room is full or closed
+func (r *Room) tryAddClient(client *Client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed.Load() || r.limits.MaxPeers > 0 && len(r.clients) >= r.limits.MaxPeers {
+		return false
 	}
+	r.clients[client.id] = client
+	return true
+}
+
+// close empties the room and returns who was in it. Occupants' own
+// goroutines notice through isClosed and drop their reference.
+func (r *Room) close() []*Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed.Store(true)
+	clients := make([]*Client, 0, len(r.clients))
+	for _, c := range r.clients {
+		clients = append(clients, c)
+	}
+	clear(r.clients)
+	return clients
+}
+
+// isClosed reports whether the hub has closed the room
+func (r *Room) isClosed() bool {
+	return r.closed.Load()
 }
 
 // ID returns the room identifier
@@ -30,6 +117,20 @@ func (r *Room) ID() string {
 	return r.id
 }
 
+// Alias returns the human-friendly word alias of a public room, if any
+func (r *Room) Alias() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.alias
+}
+
+// clearAlias drops the room's alias once it has expired
+func (r *Room) clearAlias() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alias = ""
+}
+
 // IsPublic returns whether this is a public room
 func (r *Room) IsPublic() bool {
 	return r.isPublic
@@ -116,8 +217,10 @@ func (r *Room) ClientCount() int {
 	return len(r.clients)
 }
 
-// ExtractIPRoomID determines the room ID based on client IP
-// Devices on the same /24 subnet (IPv4) or /64 subnet (IPv6) are grouped together
+// ExtractIPRoomID determines the room ID based on client IP with the
+// default grouping: devices on the same /24 subnet (IPv4) or /64 subnet
+// (IPv6) are grouped together. xForwardedFor must only be passed when it
+// was set by a trusted proxy. The hub uses its configured IPGrouping.
 func ExtractIPRoomID(remoteAddr string, xForwardedFor string) string {
 	ip := remoteAddr
 
@@ -126,37 +229,133 @@ func ExtractIPRoomID(remoteAddr string, xForwardedFor string) string {
 		parts := strings.Split(xForwardedFor, ",")
 		ip = strings.TrimSpace(parts[0])
 	}
+	return DefaultIPGrouping.roomID(ip, nil)
+}
 
-	// Extract host part (remove port)
-	host, _, err := net.SplitHostPort(ip)
-	if err == nil {
-		ip = host
-	}
+// RoomOptions shapes public room codes and custom aliases
+type RoomOptions struct {
+	CodeLength  int
+	CodeCharset string // upper-case letters and digits
 
-	parsed := net.ParseIP(ip)
-	if parsed == nil {
-		return "default"
-	}
+	// How long a custom alias stays reserved. Rooms outliving it remain
+	// joinable by code.
+	AliasTTL time.Duration
+
+	// Server-wide limits; rooms may ask for tighter ones
+	Limits RoomLimits
 
-	// IPv4: Use /24 subnet (first 3 octets)
-	if v4 := parsed.To4(); v4 != nil {
-		return fmt.Sprintf("%d.%d.%d", v4[0], v4[1], v4[2])
+	// Chat messages each room, IP rooms included, keeps for peers who
+	// join later; 0 keeps none
+	ChatHistory int
+}
+
+// limitsFor returns the limits for a room created with requested limits.
+// A request can tighten a server limit but not lift it.
+func (o RoomOptions) limitsFor(requested RoomLimits) RoomLimits {
+	tighter := func(server, req int64) int64 {
+		if req > 0 && (server == 0 || req < server) {
+			return req
+		}
+		return server
 	}
+	return RoomLimits{
+		MaxPeers:    int(tighter(int64(o.Limits.MaxPeers), int64(requested.MaxPeers))),
+		Lifetime:    time.Duration(tighter(int64(o.Limits.Lifetime), int64(requested.Lifetime))),
+		IdleTimeout: time.Duration(tighter(int64(o.Limits.IdleTimeout), int64(requested.IdleTimeout))),
+	}
+}
 
-	// IPv6: Use /64 subnet (first 4 segments)
-	// IPv6 addresses are 16 bytes, we take first 8 bytes
-	return fmt.Sprintf("%02x%02x:%02x%02x:%02x%02x:%02x%02x",
-		parsed[0], parsed[1], parsed[2], parsed[3],
-		parsed[4], parsed[5], parsed[6], parsed[7])
+// DefaultRoomOptions gives 5-character codes without ambiguous characters
+// (0, O, I, 1)
+var DefaultRoomOptions = RoomOptions{
+	CodeLength:  5,
+	CodeCharset: "ABCDEFGHJKLMNPQRSTUVWXYZ23456789",
+	AliasTTL:    12 * time.Hour,
 }
 
-// GenerateRoomCode creates a random 5-character room code
+// Validate checks that codes can be generated and typed back in
+func (o RoomOptions) Validate() error {
+	if o.CodeLength < 4 || o.CodeLength > 16 {
+		return fmt.Errorf("room code length %d out of range 4-16", o.CodeLength)
+	}
+	if len(o.CodeCharset) < 2 {
+		return errors.New("room code charset needs at least 2 characters")
+	}
+	for _, r := range o.CodeCharset {
+		if !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return fmt.Errorf("room code charset may only hold A-Z and 0-9, got %q", r)
+		}
+		if strings.Count(o.CodeCharset, string(r)) > 1 {
+			return fmt.Errorf("room code charset repeats %q", r)
+		}
+	}
+	if o.AliasTTL <= 0 {
+		return errors.New("room alias TTL must be positive")
+	}
+	if o.Limits.MaxPeers < 0 || o.Limits.Lifetime < 0 || o.Limits.IdleTimeout < 0 {
+		return errors.New("room limits must not be negative")
+	}
+	if o.ChatHistory < 0 || o.ChatHistory > maxChatHistory {
+		return fmt.Errorf("room chat history %d out of range 0-%d", o.ChatHistory, maxChatHistory)
+	}
+	return nil
+}
+
+// GenerateRoomCode creates a random room code with the default options
 func GenerateRoomCode() string {
-	const charset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // Removed ambiguous chars (0, O, I, 1)
-	b := make([]byte, 5)
-	rand.Read(b)
+	return generateRoomCode(DefaultRoomOptions)
+}
+
+func generateRoomCode(o RoomOptions) string {
+	b := make([]byte, o.CodeLength)
 	for i := range b {
-		b[i] = charset[int(b[i])%len(charset)]
+		b[i] = o.CodeCharset[randomInt(len(o.CodeCharset))]
 	}
 	return string(b)
 }
+
+// customAliasPattern matches aliases chosen by users. The hyphen is
+// required: NormalizeRoomKey tells aliases from codes by it.
+var customAliasPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)+$`)
+
+// maxCustomAliasLength bounds user-chosen aliases
+const maxCustomAliasLength = 48
+
+// ValidateCustomAlias checks a user-chosen alias such as "marketing-standup"
+func ValidateCustomAlias(alias string) error {
+	if len(alias) > maxCustomAliasLength || !customAliasPattern.MatchString(alias) {
+		return fmt.Errorf("alias must be lowercase words joined by hyphens, at most %d characters", maxCustomAliasLength)
+	}
+	return nil
+}
+
+// GenerateRoomAlias creates a random word-based room alias like
+// "swift-amber-otter-417". Two adjectives, a noun and a three-digit number
+// give 48*48*48*900 (about 99M) aliases, more than the 32^5 (about 33M)
+// room codes, so an alias is no easier to guess than the code it stands
+// for.
+func GenerateRoomAlias() string {
+	return fmt.Sprintf("%s-%s-%s-%d",
+		aliasAdjectives[randomInt(len(aliasAdjectives))],
+		aliasAdjectives[randomInt(len(aliasAdjectives))],
+		aliasNouns[randomInt(len(aliasNouns))],
+		100+randomInt(900))
+}
+
+// NormalizeRoomKey canonicalizes user input that may be either a room code
+// or a word alias. Codes are upper-cased, aliases lower-cased.
+func NormalizeRoomKey(key string) string {
+	key = strings.TrimSpace(key)
+	if strings.Contains(key, "-") {
+		return strings.ToLower(key)
+	}
+	return strings.ToUpper(key)
+}
+
+func randomInt(max int) int {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0
+	}
+	return int(n.Int64())
+}
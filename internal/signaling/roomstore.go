@@ -0,0 +1,138 @@
+package signaling
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// At most this many public rooms may be persistent, so clients can't fill
+// the disk or the code space with rooms that never go away
+const maxPersistentRooms = 32
+
+// CapabilityPersistentRooms is announced by a hub with a room store, so
+// the UI only offers to keep rooms where it can
+const CapabilityPersistentRooms = "persistent-rooms"
+
+// ErrTooManyPersistentRooms is returned when creating a persistent room
+// beyond maxPersistentRooms
+var ErrTooManyPersistentRooms = errors.New("too many persistent rooms")
+
+// persistedRoom is the saved form of a persistent public room
+type persistedRoom struct {
+	Code        string    `json:"code"`
+	Alias       string    `json:"alias,omitempty"`
+	Created     time.Time `json:"created"`
+	MaxPeers    int       `json:"max_peers,omitempty"`
+	Lifetime    int       `json:"lifetime,omitempty"`     // seconds
+	IdleTimeout int       `json:"idle_timeout,omitempty"` // seconds
+}
+
+// roomStore saves persistent rooms to a JSON file
+type roomStore struct {
+	path string
+	mu   sync.Mutex // orders writes
+}
+
+// SetRoomStore loads the persistent rooms saved at path and keeps saving
+// them there. Without a store, rooms can't be made persistent. It must
+// be called before serving clients.
+func (h *Hub) SetRoomStore(path string) error {
+	h.roomStore = &roomStore{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var saved []persistedRoom
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+
+	h.publicRoomsMu.Lock()
+	defer h.publicRoomsMu.Unlock()
+	for _, p := range saved {
+		room := NewRoom(p.Code, true)
+		room.alias = p.Alias
+		room.persistent = true
+		room.created = p.Created
+		room.limits = RoomLimits{
+			MaxPeers:    p.MaxPeers,
+			Lifetime:    time.Duration(p.Lifetime) * time.Second,
+			IdleTimeout: time.Duration(p.IdleTimeout) * time.Second,
+		}
+		h.publicRooms[room.id] = room
+		if room.alias != "" {
+			h.roomAliases[room.alias] = room.id
+		}
+	}
+	h.logger.Info("restored persistent rooms", "count", len(saved))
+	return nil
+}
+
+// Capabilities lists the optional protocol features this hub supports:
+// the fixed ones, and persistent rooms when it has a room store
+func (h *Hub) Capabilities() []string {
+	caps := slices.Clone(Capabilities)
+	if h.roomStore != nil {
+		caps = append(caps, CapabilityPersistentRooms)
+	}
+	return caps
+}
+
+// persistentRoomCountLocked counts persistent rooms. The caller must hold
+// publicRoomsMu.
+func (h *Hub) persistentRoomCountLocked() int {
+	n := 0
+	for _, room := range h.publicRooms {
+		if room.persistent {
+			n++
+		}
+	}
+	return n
+}
+
+// saveRooms writes every persistent room to the store
+func (h *Hub) saveRooms() {
+	s := h.roomStore
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h.publicRoomsMu.RLock()
+	saved := []persistedRoom{}
+	for _, room := range h.publicRooms {
+		if !room.persistent {
+			continue
+		}
+		saved = append(saved, persistedRoom{
+			Code:        room.id,
+			Alias:       room.Alias(),
+			Created:     room.created,
+			MaxPeers:    room.limits.MaxPeers,
+			Lifetime:    int(room.limits.Lifetime / time.Second),
+			IdleTimeout: int(room.limits.IdleTimeout / time.Second),
+		})
+	}
+	h.publicRoomsMu.RUnlock()
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(s.path), 0700); err == nil {
+			err = os.WriteFile(s.path, data, 0600)
+		}
+	}
+	if err != nil {
+		h.logger.Warn("failed to save persistent rooms", "error", err)
+	}
+}
@@ -0,0 +1,153 @@
+package signaling
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// TransferLimits cap the sizes senders may declare in a transfer request.
+// Zero means no limit.
+type TransferLimits struct {
+	MaxFileSize     int64 // bytes per file
+	MaxTransferSize int64 // bytes per request, all files together
+}
+
+// Relay chunks may add up to this much more than a transfer declared,
+// for chunks resent after a NACK and encryption overhead
+const relayAllowanceDivisor = 4
+
+// Transfers per sender whose relayed bytes are tracked at once. Requests
+// beyond it are refused until a tracked transfer goes idle.
+const maxRelayBudgets = 64
+
+// How long a tracked transfer may go without relaying a chunk before its
+// budget can be dropped to make room; long enough for the receiver to
+// answer the request
+const relayBudgetIdle = 10 * time.Minute
+
+// errTooManyTransfers is returned for a request while the sender already
+// has maxRelayBudgets transfers in progress
+var errTooManyTransfers = errors.New("too many transfers in progress, try again later")
+
+// relayBudget is what a tracked transfer may still relay
+type relayBudget struct {
+	left int64 // bytes; negative once cut off
+	used time.Time
+}
+
+// SetTransferLimits sets the limits on declared transfer sizes. It must
+// be called before serving clients.
+func (h *Hub) SetTransferLimits(l TransferLimits) {
+	h.transferLimits = l
+}
+
+// enabled reports whether any limit is set
+func (l TransferLimits) enabled() bool {
+	return l.MaxFileSize > 0 || l.MaxTransferSize > 0
+}
+
+// check rejects a request declaring sizes over the limits, and replaces
+// its total with the sum of its files so the receiver isn't shown a
+// smaller figure than what is coming
+func (l TransferLimits) check(req *TransferRequestPayload) error {
+	var total int64
+	for i, f := range req.Files {
+		if f.Size < 0 {
+			return fmt.Errorf("file %d: negative size", i)
+		}
+		if l.MaxFileSize > 0 && f.Size > l.MaxFileSize {
+			return fmt.Errorf("file %d is larger than the %s this server allows", i, formatBytes(l.MaxFileSize))
+		}
+		// Stop once over the limit, before the sum can overflow
+		if f.Size > math.MaxInt64-total {
+			return errors.New("transfer size out of range")
+		}
+		total += f.Size
+		if l.MaxTransferSize > 0 && total > l.MaxTransferSize {
+			return fmt.Errorf("transfer is larger than the %s this server allows", formatBytes(l.MaxTransferSize))
+		}
+	}
+	req.TotalSize = total
+	return nil
+}
+
+// setRelayBudget starts tracking the bytes c relays for a transfer it
+// requested. Only used while transfer limits are set; budgets are touched
+// by c's own goroutine only. With maxRelayBudgets transfers tracked,
+// idle ones make room; if none are idle the request is refused, so
+// piling up requests can't push out the budget of one in progress.
+func (c *Client) setRelayBudget(transferID string, declared int64) error {
+	if !c.hub.transferLimits.enabled() {
+		return nil
+	}
+	if c.relayBudgets == nil {
+		c.relayBudgets = make(map[string]*relayBudget)
+	}
+	now := time.Now()
+	if _, exists := c.relayBudgets[transferID]; !exists && len(c.relayBudgets) >= maxRelayBudgets {
+		for id, b := range c.relayBudgets {
+			if now.Sub(b.used) >= relayBudgetIdle {
+				delete(c.relayBudgets, id)
+			}
+		}
+		if len(c.relayBudgets) >= maxRelayBudgets {
+			return errTooManyTransfers
+		}
+	}
+	c.relayBudgets[transferID] = &relayBudget{
+		left: declared + declared/relayAllowanceDivisor + 1<<20,
+		used: now,
+	}
+	return nil
+}
+
+// dropRelayBudget stops tracking a transfer whose request went nowhere
+func (c *Client) dropRelayBudget(transferID string) {
+	delete(c.relayBudgets, transferID)
+}
+
+// spendRelayBudget counts n relayed bytes of a transfer and reports
+// whether the chunk may go through. While transfer limits are set, only
+// transfers requested on the sender's current connection may relay. A
+// transfer that runs over its budget is cut off and its sender told why.
+func (c *Client) spendRelayBudget(targetID, transferID string, n int64) bool {
+	if !c.hub.transferLimits.enabled() {
+		return true
+	}
+
+	b, ok := c.relayBudgets[transferID]
+	if !ok {
+		c.logger.Debug("dropping relay chunk of unrequested transfer", "clientID", c.id, "transferId", transferID)
+		msg, _ := NewTransferRejectedMessage(targetID, transferID, "transfer was not requested on this connection")
+		c.Send(msg)
+		return false
+	}
+	if b.left < 0 {
+		return false
+	}
+	b.used = time.Now()
+	b.left -= n
+	if b.left >= 0 {
+		return true
+	}
+
+	b.left = -1
+	c.logger.Warn("cutting off relay over declared size", "clientID", c.id, "transferId", transferID)
+	msg, _ := NewTransferRejectedMessage(targetID, transferID, "sent more data than the transfer declared")
+	c.Send(msg)
+	return false
+}
+
+// formatBytes renders a byte count for error messages, e.g. "2 GiB"
+func formatBytes(n int64) string {
+	units := []string{"bytes", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for n >= 1024 && n%1024 == 0 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	return strconv.FormatInt(n, 10) + " " + units[i]
+}
@@ -0,0 +1,109 @@
+package signaling
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newBudgetClient() *Client {
+	hub := &Hub{transferLimits: TransferLimits{MaxTransferSize: 1 << 30}}
+	return &Client{
+		id:     "sender",
+		hub:    hub,
+		send:   make(chan []byte, 16),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func TestRelayBudget(t *testing.T) {
+	const declared = 4 << 20
+	allowed := int64(declared + declared/relayAllowanceDivisor + 1<<20)
+
+	tests := []struct {
+		name     string
+		chunks   []int64
+		want     []bool
+		rejected int // transfer-rejected messages sent
+	}{
+		{name: "declared size", chunks: []int64{declared}, want: []bool{true}},
+		{name: "up to the allowance", chunks: []int64{declared, allowed - declared}, want: []bool{true, true}},
+		{
+			name:     "over the allowance",
+			chunks:   []int64{allowed, 1, 1},
+			want:     []bool{true, false, false},
+			rejected: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newBudgetClient()
+			if err := c.setRelayBudget("t1", declared); err != nil {
+				t.Fatal(err)
+			}
+			for i, n := range tt.chunks {
+				if got := c.spendRelayBudget("receiver", "t1", n); got != tt.want[i] {
+					t.Errorf("chunk %d: allowed = %v, want %v", i, got, tt.want[i])
+				}
+			}
+			if len(c.send) != tt.rejected {
+				t.Errorf("%d messages sent, want %d", len(c.send), tt.rejected)
+			}
+		})
+	}
+}
+
+func TestRelayBudgetUnrequested(t *testing.T) {
+	c := newBudgetClient()
+	if c.spendRelayBudget("receiver", "unknown", 1) {
+		t.Errorf("chunk of an unrequested transfer allowed")
+	}
+	if len(c.send) != 1 {
+		t.Errorf("%d messages sent, want 1", len(c.send))
+	}
+}
+
+func TestRelayBudgetEviction(t *testing.T) {
+	tests := []struct {
+		name    string
+		idle    int // tracked transfers idle long enough to be dropped
+		id      string
+		wantErr error
+	}{
+		{name: "none idle", id: "new", wantErr: errTooManyTransfers},
+		{name: "one idle", idle: 1, id: "new"},
+		{name: "tracked transfer", id: "t0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newBudgetClient()
+			for i := range maxRelayBudgets {
+				if err := c.setRelayBudget("t"+strconv.Itoa(i), 1); err != nil {
+					t.Fatalf("transfer %d: %v", i, err)
+				}
+			}
+			for i := range tt.idle {
+				c.relayBudgets["t"+strconv.Itoa(i)].used = time.Now().Add(-relayBudgetIdle)
+			}
+
+			err := c.setRelayBudget(tt.id, 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(c.relayBudgets) > maxRelayBudgets {
+				t.Errorf("%d transfers tracked, want at most %d", len(c.relayBudgets), maxRelayBudgets)
+			}
+			if err == nil && c.relayBudgets[tt.id] == nil {
+				t.Errorf("%s not tracked", tt.id)
+			}
+			if tt.idle > 0 && c.relayBudgets["t0"] != nil {
+				t.Errorf("idle transfer still tracked")
+			}
+		})
+	}
+}
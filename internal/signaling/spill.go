@@ -0,0 +1,255 @@
+package signaling
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Relay chunks are spilled to disk once a client's send buffer holds
+	// this many messages, so slow receivers don't pin hub memory
+	relaySpillThreshold = 64
+
+	// Maximum bytes a single client may have spilled to disk
+	maxSpillBytes = 2 << 30 // 2GB
+
+	// Maximum bytes spilled to disk by all clients together, so receivers
+	// that never read can't fill the temp disk between them
+	maxHubSpillBytes = 4 << 30 // 4GB
+)
+
+var (
+	errSpillClosed  = errors.New("spill queue closed")
+	errSpillFull    = errors.New("spill queue full")
+	errHubSpillFull = errors.New("relay spill space used up on this server")
+)
+
+// spillMetrics tracks disk spill usage across all clients
+type spillMetrics struct {
+	spilledMessages atomic.Int64
+	spilledBytes    atomic.Int64
+	diskBytes       atomic.Int64
+}
+
+// spillQueue is a disk-backed FIFO of relay messages for one client.
+// Messages are appended to a temp file as length-prefixed records and a
+// drain goroutine feeds them back into the client's send channel as it
+// empties.
+type spillQueue struct {
+	client  *Client
+	metrics *spillMetrics
+
+	mu       sync.Mutex
+	file     *os.File
+	readOff  int64
+	writeOff int64
+	queued   int // records on disk
+	pending  int // records on disk or popped but not yet delivered
+	started  bool
+	closed   bool
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newSpillQueue(client *Client, metrics *spillMetrics) *spillQueue {
+	return &spillQueue{
+		client:  client,
+		metrics: metrics,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// Active reports whether messages are still waiting to be delivered from disk
+func (q *spillQueue) Active() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pending > 0
+}
+
+// Push appends a message to the queue, creating the backing file on first use
+func (q *spillQueue) Push(msg []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return errSpillClosed
+	}
+	size := int64(4 + len(msg))
+	if q.writeOff-q.readOff+size > maxSpillBytes {
+		return errSpillFull
+	}
+	// Reserve the space up front, so clients spilling at once can't
+	// all pass the check
+	if q.metrics.diskBytes.Add(size) > maxHubSpillBytes {
+		q.metrics.diskBytes.Add(-size)
+		return errHubSpillFull
+	}
+
+	if err := q.write(msg); err != nil {
+		q.metrics.diskBytes.Add(-size)
+		return err
+	}
+
+	q.writeOff += size
+	q.queued++
+	q.pending++
+	q.metrics.spilledMessages.Add(1)
+	q.metrics.spilledBytes.Add(int64(len(msg)))
+
+	if !q.started {
+		q.started = true
+		q.wg.Add(1)
+		go q.drain()
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// write appends msg as a record at writeOff, creating the backing file on
+// first use. Called with q.mu held.
+func (q *spillQueue) write(msg []byte) error {
+	if q.file == nil {
+		f, err := os.CreateTemp("", "peerdrop-spill-*")
+		if err != nil {
+			return err
+		}
+		q.file = f
+	}
+
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(msg)))
+	if _, err := q.file.WriteAt(header[:], q.writeOff); err != nil {
+		return err
+	}
+	_, err := q.file.WriteAt(msg, q.writeOff+4)
+	return err
+}
+
+// pop reads the oldest message from disk. It returns nil when the queue
+// is empty.
+func (q *spillQueue) pop() ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.queued == 0 || q.file == nil {
+		return nil, nil
+	}
+
+	var header [4]byte
+	if _, err := q.file.ReadAt(header[:], q.readOff); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := q.file.ReadAt(msg, q.readOff+4); err != nil {
+		return nil, err
+	}
+
+	size := int64(4 + len(msg))
+	q.readOff += size
+	q.queued--
+	q.metrics.diskBytes.Add(-size)
+
+	// Reclaim disk space once the queue has been fully read
+	if q.queued == 0 {
+		q.file.Truncate(0)
+		q.readOff, q.writeOff = 0, 0
+	}
+
+	return msg, nil
+}
+
+// delivered marks a popped message as handed to the send channel
+func (q *spillQueue) delivered() {
+	q.mu.Lock()
+	q.pending--
+	q.mu.Unlock()
+}
+
+// drain moves spilled messages back into the client's send channel
+func (q *spillQueue) drain() {
+	defer q.wg.Done()
+	defer q.client.hub.recoverPanic("relay spill")
+
+	for {
+		msg, err := q.pop()
+		if err != nil {
+			// The rest of the queue can't be delivered in order, and
+			// while it is pending every later chunk would queue up
+			// behind it, so the client is dropped
+			q.client.logger.Warn("spill read failed", "clientID", q.client.id, "error", err)
+			q.discard()
+			q.client.closeWithReason(websocket.CloseInternalServerErr, "relay spill failed")
+			return
+		}
+		if msg == nil {
+			select {
+			case <-q.wake:
+				continue
+			case <-q.done:
+				return
+			}
+		}
+
+		select {
+		case q.client.send <- msg:
+			q.delivered()
+		case <-q.done:
+			return
+		}
+	}
+}
+
+// Close stops the drain goroutine and removes the backing file.
+// It must be called before the client's send channel is closed.
+func (q *spillQueue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.done)
+	q.mu.Unlock()
+
+	q.wg.Wait()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.removeFile()
+}
+
+// discard closes the queue from the drain goroutine, dropping whatever
+// is still on disk. Later Pushes fail and Active reports false.
+func (q *spillQueue) discard() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.done)
+	}
+	q.queued, q.pending = 0, 0
+	q.removeFile()
+}
+
+// removeFile deletes the backing file and gives its space back to the
+// hub. Called with q.mu held.
+func (q *spillQueue) removeFile() {
+	if q.file != nil {
+		q.metrics.diskBytes.Add(-(q.writeOff - q.readOff))
+		q.file.Close()
+		os.Remove(q.file.Name())
+		q.file = nil
+	}
+}
@@ -0,0 +1,208 @@
+package signaling
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTP fallback for networks where WebSocket upgrades fail, such as some
+// corporate proxies. The client opens GET /signal/stream, a server-sent
+// event stream whose first "session" event carries a token, then POSTs
+// messages to /signal/send?session=<token>, one JSON message per line.
+// Closing the stream ends the session.
+
+// Comment line written to idle streams so proxies keep them open
+const streamKeepAlive = 25 * time.Second
+
+// httpTransport carries a client's messages over an event stream and POSTs
+type httpTransport struct {
+	// Serializes message handling, which WebSocket clients get from their
+	// single read pump. gone is set under it once the client unregisters.
+	inbound sync.Mutex
+	gone    bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+	code      int
+	reason    string
+}
+
+func newHTTPTransport() *httpTransport {
+	return &httpTransport{done: make(chan struct{})}
+}
+
+// Event streams are text only; relay chunks are transcoded to JSON
+func (t *httpTransport) binary() bool { return false }
+
+// close ends the stream with a close event carrying code and reason
+func (t *httpTransport) close(code int, reason string) {
+	t.closeOnce.Do(func() {
+		t.code, t.reason = code, reason
+		close(t.done)
+	})
+}
+
+// streamClosePayload is the data of the close event
+type streamClosePayload struct {
+	Code   int    `json:"code"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// HandleSignalStream opens an HTTP fallback session and streams the
+// client's messages to it as server-sent events
+func (h *Hub) HandleSignalStream(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-h.done:
+		http.Error(w, "Server shutting down", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+	if !h.checkConnPolicy(w, r) {
+		return
+	}
+	ip := h.ClientIP(r)
+	if !h.acquireConn(w, ip) {
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // keep nginx from buffering events
+
+	// Session tokens are generated like client IDs but never shown to peers
+	token := generateClientID()
+	fmt.Fprintf(w, "event: session\ndata: {\"session\":%q}\n\n", token)
+	if err := rc.Flush(); err != nil {
+		h.releaseConn(ip)
+		h.logger.Error("event stream not supported", "error", err)
+		return
+	}
+
+	t := newHTTPTransport()
+	client := NewClient(generateClientID(), t, h, ip, h.logger)
+	client.host = requestHost(r)
+	client.ipRoomID = h.ipGrouping.Load().roomID(client.ip, h.forwardedHeader(r))
+
+	h.sessionsMu.Lock()
+	h.sessions[token] = client
+	h.sessionsMu.Unlock()
+	h.register(client, "http")
+
+	defer func() {
+		h.sessionsMu.Lock()
+		delete(h.sessions, token)
+		h.sessionsMu.Unlock()
+
+		t.inbound.Lock()
+		t.gone = true
+		h.Unregister(client)
+		t.inbound.Unlock()
+	}()
+	defer h.recoverPanic("event stream")
+
+	t.stream(r.Context(), w, rc, client)
+}
+
+// stream writes queued messages as events until the request ends, the hub
+// unregisters the client or the transport is closed
+func (t *httpTransport) stream(ctx context.Context, w io.Writer, rc *http.ResponseController, c *Client) {
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		// Deadlines are set per write: one set before the select would
+		// expire while the stream sits idle
+		select {
+		case message, ok := <-c.send:
+			rc.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				return
+			}
+			writeEvent(w, message)
+
+			// Add queued messages to the same flush
+			n := len(c.send)
+			for i := 0; i < n; i++ {
+				writeEvent(w, <-c.send)
+			}
+
+		case <-ticker.C:
+			rc.SetWriteDeadline(time.Now().Add(writeWait))
+			io.WriteString(w, ": keep-alive\n\n")
+
+		case <-t.done:
+			rc.SetWriteDeadline(time.Now().Add(writeWait))
+			data, _ := json.Marshal(streamClosePayload{Code: t.code, Reason: t.reason})
+			fmt.Fprintf(w, "event: close\ndata: %s\n\n", data)
+			rc.Flush()
+			return
+
+		case <-ctx.Done():
+			return
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes msg as an unnamed event. Every line gets its own data
+// field, which the browser joins back together with newlines.
+func writeEvent(w io.Writer, msg []byte) {
+	if isBinaryFrame(msg) {
+		return
+	}
+	for line := range bytes.SplitSeq(msg, []byte{'\n'}) {
+		io.WriteString(w, "data: ")
+		w.Write(line)
+		io.WriteString(w, "\n")
+	}
+	io.WriteString(w, "\n")
+}
+
+// HandleSignalSend handles messages POSTed by an HTTP fallback client
+func (h *Hub) HandleSignalSend(w http.ResponseWriter, r *http.Request) {
+	if !h.checkConnPolicy(w, r) {
+		return
+	}
+
+	h.sessionsMu.RLock()
+	client := h.sessions[r.URL.Query().Get("session")]
+	h.sessionsMu.RUnlock()
+	if client == nil {
+		http.Error(w, "Unknown session", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, "Message too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	t := client.transport.(*httpTransport)
+	t.inbound.Lock()
+	defer t.inbound.Unlock()
+	if t.gone {
+		http.Error(w, "Unknown session", http.StatusNotFound)
+		return
+	}
+
+	func() {
+		defer h.recoverPanic("signal send")
+		for line := range bytes.SplitSeq(body, []byte{'\n'}) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				client.handleMessage(line)
+			}
+		}
+	}()
+	w.WriteHeader(http.StatusNoContent)
+}
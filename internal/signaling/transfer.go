@@ -0,0 +1,282 @@
+package signaling
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"Peer-Drop/internal/events"
+)
+
+const (
+	// Limits on sender-supplied relative paths in folder transfers
+	maxPathLength = 1024
+	maxPathDepth  = 32
+
+	// Limit on categories in a transfer summary's type breakdown
+	maxSummaryTypes = 32
+
+	// Limits on image previews in a transfer request
+	maxPreviews    = 8
+	maxPreviewSize = 16 * 1024 // decoded bytes per preview
+)
+
+// previewTypes are the image formats a preview may use
+var previewTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// SanitizeRelativePath validates a sender-supplied relative file path from
+// a folder transfer and returns it in canonical "/"-separated form.
+// Subdirectories are allowed; absolute paths, drive letters, ".."
+// components and control characters are rejected.
+func SanitizeRelativePath(p string) (string, error) {
+	if len(p) > maxPathLength {
+		return "", errors.New("path too long")
+	}
+
+	p = strings.ReplaceAll(p, "\\", "/")
+	if strings.HasPrefix(p, "/") {
+		return "", errors.New("absolute path not allowed")
+	}
+
+	parts := make([]string, 0, strings.Count(p, "/")+1)
+	for _, part := range strings.Split(p, "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			return "", errors.New("path traversal not allowed")
+		}
+
+		for _, r := range part {
+			if r < 0x20 || r == 0x7f || r == ':' {
+				return "", fmt.Errorf("invalid character %q in path", r)
+			}
+		}
+		parts = append(parts, part)
+	}
+
+	if len(parts) == 0 {
+		return "", errors.New("empty path")
+	}
+	if len(parts) > maxPathDepth {
+		return "", errors.New("path too deep")
+	}
+
+	return strings.Join(parts, "/"), nil
+}
+
+// handleTransferRequest validates a transfer request before relaying it.
+// Folder paths are sanitized so receivers only ever see safe relative
+// paths; invalid requests are rejected back to the sender.
+func (c *Client) handleTransferRequest(msg Message, rawData []byte) {
+	var req TransferRequestPayload
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		c.logger.Warn("failed to unmarshal transfer request", "error", err)
+		return
+	}
+
+	if !c.hub.rateLimiter.allowTransfer(c.ip) {
+		c.logger.Warn("rejecting transfer request over rate limit", "clientID", c.id, "ip", c.ip)
+		reject, _ := NewTransferRejectedMessage(msg.TargetID, req.TransferID, "too many transfer requests, try again in a minute")
+		c.Send(reject)
+		return
+	}
+
+	err := sanitizeTransferRequest(&req)
+	if err == nil {
+		err = c.hub.transferLimits.check(&req)
+	}
+	if err != nil {
+		c.logger.Warn("rejecting transfer request", "clientID", c.id, "transferId", req.TransferID, "error", err)
+		reject, _ := NewTransferRejectedMessage(msg.TargetID, req.TransferID, err.Error())
+		c.Send(reject)
+		return
+	}
+
+	// Make room for the transfer's budget before the receiver sees it
+	if err := c.setRelayBudget(req.TransferID, req.TotalSize); err != nil {
+		c.logger.Warn("rejecting transfer request", "clientID", c.id, "transferId", req.TransferID, "error", err)
+		reject, _ := NewTransferRejectedMessage(msg.TargetID, req.TransferID, err.Error())
+		c.Send(reject)
+		return
+	}
+
+	// Re-encode so the receiver gets the sanitized paths
+	msg.Payload, _ = json.Marshal(req)
+	if !c.relayToTarget(msg, rawData) {
+		c.dropRelayBudget(req.TransferID)
+		return
+	}
+	c.hub.events.Publish(events.TransferRequested, events.TransferEvent{
+		TransferID: req.TransferID,
+		Sender:     c.id,
+		Receiver:   msg.TargetID,
+	})
+}
+
+// handleTransferResponse relays the receiver's answer to the sender
+func (c *Client) handleTransferResponse(msg Message, rawData []byte) {
+	var resp TransferResponsePayload
+	if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+		c.logger.Warn("failed to unmarshal transfer response", "error", err)
+		return
+	}
+	if c.relayToTarget(msg, rawData) {
+		c.hub.events.Publish(events.TransferAnswered, events.TransferEvent{
+			TransferID: resp.TransferID,
+			Sender:     msg.TargetID,
+			Receiver:   c.id,
+			Accepted:   resp.Accepted,
+		})
+	}
+}
+
+// relayChunkProgress is the part of a relay chunk progress events and
+// size limits need; the data is only measured, not decoded
+type relayChunkProgress struct {
+	TransferID  string `json:"transferId"`
+	FileIndex   int    `json:"fileIndex"`
+	ChunkIndex  int    `json:"chunkIndex"`
+	TotalChunks int    `json:"totalChunks"`
+	Data        string `json:"data"`
+}
+
+// handleRelayChunk relays a JSON relay chunk and reports its progress
+func (c *Client) handleRelayChunk(msg Message, rawData []byte) {
+	var p relayChunkProgress
+	parsed := json.Unmarshal(msg.Payload, &p) == nil
+	if c.findPeer(msg.TargetID) == nil {
+		c.logger.Debug("relay target not found", "targetID", msg.TargetID)
+		return
+	}
+	if c.hub.transferLimits.enabled() {
+		// A chunk that can't be measured can't be counted either
+		if !parsed || !c.spendRelayBudget(msg.TargetID, p.TransferID, int64(base64.StdEncoding.DecodedLen(len(p.Data)))) {
+			return
+		}
+	}
+	if !c.relayToTarget(msg, rawData) {
+		return
+	}
+	if parsed {
+		c.publishProgress(msg.TargetID, p.TransferID, p.FileIndex, p.ChunkIndex, p.TotalChunks)
+	}
+}
+
+// publishProgress reports a relayed chunk on the event bus. It runs for
+// every chunk, so nothing is built unless someone is listening.
+func (c *Client) publishProgress(targetID, transferID string, fileIndex, chunk, totalChunks int) {
+	if !c.hub.events.Wants(events.TransferProgress) {
+		return
+	}
+	c.hub.events.Publish(events.TransferProgress, events.TransferEvent{
+		TransferID:  transferID,
+		Sender:      c.id,
+		Receiver:    targetID,
+		FileIndex:   fileIndex,
+		Chunk:       chunk,
+		TotalChunks: totalChunks,
+	})
+}
+
+// sanitizeTransferRequest canonicalizes the relative paths of all files
+// and checks the summary, if any, against the file list
+func sanitizeTransferRequest(req *TransferRequestPayload) error {
+	for i := range req.Files {
+		if req.Files[i].Path == "" {
+			continue
+		}
+
+		clean, err := SanitizeRelativePath(req.Files[i].Path)
+		if err != nil {
+			return fmt.Errorf("file %d: %w", i, err)
+		}
+		req.Files[i].Path = clean
+	}
+	req.Previews = filterPreviews(req.Previews, len(req.Files))
+	return checkTransferSummary(req)
+}
+
+// filterPreviews keeps the previews within limits whose content matches
+// their declared image type. Previews are optional, so bad ones are
+// dropped rather than failing the transfer.
+func filterPreviews(previews []FilePreview, fileCount int) []FilePreview {
+	seen := make(map[int]bool)
+	kept := previews[:0]
+	for _, p := range previews {
+		if len(kept) == maxPreviews {
+			break
+		}
+		if p.FileIndex < 0 || p.FileIndex >= fileCount || seen[p.FileIndex] || !previewTypes[p.Type] {
+			continue
+		}
+		if base64.StdEncoding.DecodedLen(len(p.Data)) > maxPreviewSize+2 {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(p.Data)
+		if err != nil || len(data) > maxPreviewSize || http.DetectContentType(data) != p.Type {
+			continue
+		}
+		seen[p.FileIndex] = true
+		kept = append(kept, p)
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
+
+// checkTransferSummary rejects a summary that disagrees with the files it
+// describes, so receivers can rely on what the prompt shows. It must run
+// after the paths have been sanitized.
+func checkTransferSummary(req *TransferRequestPayload) error {
+	sum := req.Summary
+	if sum == nil {
+		return nil
+	}
+
+	var total, largest int64
+	var largestName string
+	for _, f := range req.Files {
+		total += f.Size
+		if f.Size > largest || largestName == "" {
+			largest = f.Size
+			largestName = cmp.Or(f.Path, f.Name)
+		}
+	}
+
+	if sum.FileCount != len(req.Files) {
+		return errors.New("summary file count does not match files")
+	}
+	if sum.TotalSize != total {
+		return errors.New("summary total size does not match files")
+	}
+	if sum.LargestSize != largest {
+		return errors.New("summary largest file does not match files")
+	}
+	if len(sum.Types) > maxSummaryTypes {
+		return errors.New("summary has too many types")
+	}
+
+	var typeCount int
+	var typeSize int64
+	for _, t := range sum.Types {
+		typeCount += t.Count
+		typeSize += t.Size
+	}
+	if typeCount != sum.FileCount || typeSize != sum.TotalSize {
+		return errors.New("summary type breakdown does not match files")
+	}
+
+	// Show the sanitized path rather than whatever the sender wrote
+	sum.LargestName = largestName
+	return nil
+}
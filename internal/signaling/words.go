@@ -0,0 +1,21 @@
+package signaling
+
+// Word lists for human-friendly room aliases. Words are short, common and
+// hard to mishear when read aloud.
+var aliasAdjectives = []string{
+	"amber", "brave", "bright", "calm", "clever", "cosy", "crisp", "eager",
+	"fancy", "fast", "gentle", "giant", "golden", "happy", "jolly", "kind",
+	"lucky", "mellow", "merry", "mighty", "noble", "proud", "quick", "quiet",
+	"rapid", "royal", "shiny", "silent", "silver", "smart", "sunny", "swift",
+	"tidy", "tiny", "vivid", "warm", "wild", "wise", "witty", "young",
+	"blue", "green", "orange", "purple", "red", "yellow", "pink", "teal",
+}
+
+var aliasNouns = []string{
+	"badger", "bear", "beaver", "bison", "camel", "cobra", "crane", "dolphin",
+	"eagle", "falcon", "ferret", "fox", "gecko", "heron", "hippo", "horse",
+	"koala", "lemur", "lion", "llama", "lynx", "moose", "otter", "owl",
+	"panda", "parrot", "penguin", "puffin", "rabbit", "raven", "robin", "salmon",
+	"seal", "shark", "sloth", "spider", "swan", "tiger", "toucan", "turtle",
+	"walrus", "whale", "wolf", "wombat", "yak", "zebra", "moth", "finch",
+}
@@ -0,0 +1,146 @@
+package signaling
+
+import (
+	"io"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong message from the peer
+	pongWait = 60 * time.Second
+
+	// Send pings to peer with this period (must be less than pongWait)
+	pingPeriod = (pongWait * 9) / 10
+)
+
+// wsTransport carries a client's messages over a WebSocket connection
+type wsTransport struct {
+	conn *websocket.Conn
+}
+
+func (t *wsTransport) binary() bool { return true }
+
+// close sends a close frame with the given code and reason, then closes the
+// connection. The read pump notices and unregisters the client.
+func (t *wsTransport) close(code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	t.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+	t.conn.Close()
+}
+
+// readPump pumps messages from the WebSocket connection to the hub
+func (t *wsTransport) readPump(c *Client) {
+	defer func() {
+		c.hub.Unregister(c)
+		t.conn.Close()
+	}()
+	defer c.hub.recoverPanic("read pump")
+
+	t.conn.SetReadLimit(maxMessageSize)
+	t.conn.SetReadDeadline(time.Now().Add(pongWait))
+	t.conn.SetPongHandler(func(string) error {
+		t.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		messageType, message, err := t.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				c.logger.Warn("websocket read error", "error", err, "clientID", c.id)
+			}
+			break
+		}
+		if messageType == websocket.BinaryMessage {
+			c.handleBinary(message)
+			continue
+		}
+		c.handleMessage(message)
+	}
+}
+
+// writePump pumps messages from the hub to the WebSocket connection
+func (t *wsTransport) writePump(c *Client) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		t.conn.Close()
+	}()
+	defer c.hub.recoverPanic("write pump")
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			t.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// Hub closed the channel
+				t.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			if err := t.writeQueued(c, message); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			t.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := t.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeQueued writes msg and any other messages already queued. Consecutive
+// text messages are batched into one frame separated by newlines; binary
+// relay chunks go out as frames of their own.
+func (t *wsTransport) writeQueued(c *Client, msg []byte) error {
+	var w io.WriteCloser
+	flush := func() error {
+		if w == nil {
+			return nil
+		}
+		err := w.Close()
+		w = nil
+		return err
+	}
+
+	write := func(m []byte) error {
+		if isBinaryFrame(m) {
+			if err := flush(); err != nil {
+				return err
+			}
+			return t.conn.WriteMessage(websocket.BinaryMessage, m)
+		}
+
+		if w == nil {
+			var err error
+			if w, err = t.conn.NextWriter(websocket.TextMessage); err != nil {
+				return err
+			}
+		} else {
+			w.Write([]byte{'\n'})
+		}
+		_, err := w.Write(m)
+		return err
+	}
+
+	if err := write(msg); err != nil {
+		return err
+	}
+
+	// Add queued messages to the current websocket message
+	n := len(c.send)
+	for i := 0; i < n; i++ {
+		if err := write(<-c.send); err != nil {
+			return err
+		}
+	}
+
+	return flush()
+}
@@ -0,0 +1,253 @@
+// Package statearchive moves Peer-Drop's configuration and state between
+// machines. An archive holds the config directory (config.json, the TLS
+// certificate) and the state directory (persistent rooms), minus crash
+// reports and partial downloads, as a passphrase-encrypted tar.gz.
+package statearchive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	magic = "PDSTATE1"
+
+	saltSize   = 16
+	iterations = 600_000 // PBKDF2-HMAC-SHA256, per OWASP guidance
+
+	// Bounds what an archive may expand to on import
+	maxArchiveSize = 64 << 20
+)
+
+// ErrBadPassphrase is returned when an archive can't be decrypted, either
+// because the passphrase is wrong or the file was modified
+var ErrBadPassphrase = errors.New("wrong passphrase or corrupted archive")
+
+// Dirs are the directories an archive is taken from and restored to
+type Dirs struct {
+	Config string
+	State  string
+}
+
+// State subdirectories that are not worth moving
+var skipped = []string{"crashes", "partials"}
+
+// Export writes an archive of dirs encrypted with passphrase to w
+func Export(w io.Writer, dirs Dirs, passphrase string) error {
+	var plain bytes.Buffer
+	gz := gzip.NewWriter(&plain)
+	tw := tar.NewWriter(gz)
+
+	if err := addDir(tw, "config", dirs.Config, nil); err != nil {
+		return err
+	}
+	if err := addDir(tw, "state", dirs.State, skipped); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	salt := make([]byte, saltSize)
+	rand.Read(salt)
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+
+	header := append([]byte(magic), salt...)
+	out := append(bytes.Clone(header), nonce...)
+	out = aead.Seal(out, nonce, plain.Bytes(), header)
+	_, err = w.Write(out)
+	return err
+}
+
+// addDir adds the regular files under dir to tw as prefix/<relative path>,
+// skipping the named top-level subdirectories. A missing dir adds nothing.
+func addDir(tw *tar.Writer, prefix, dir string, skip []string) error {
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if d.IsDir() {
+			for _, s := range skip {
+				if rel == s {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Name:    path.Join(prefix, filepath.ToSlash(rel)),
+			Mode:    int64(info.Mode().Perm()),
+			Size:    int64(len(data)),
+			ModTime: info.ModTime(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Import restores an archive read from r into dirs. Existing files are
+// only replaced when overwrite is set; otherwise nothing is written if any
+// would be. It returns the paths written.
+func Import(r io.Reader, dirs Dirs, passphrase string, overwrite bool) ([]string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxArchiveSize))
+	if err != nil {
+		return nil, err
+	}
+	headerSize := len(magic) + saltSize
+	if len(data) < headerSize || string(data[:len(magic)]) != magic {
+		return nil, errors.New("not a Peer-Drop state archive")
+	}
+
+	header := data[:headerSize]
+	aead, err := newAEAD(passphrase, data[len(magic):headerSize])
+	if err != nil {
+		return nil, err
+	}
+	rest := data[headerSize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, ErrBadPassphrase
+	}
+	plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+
+	files, err := readFiles(plain, dirs)
+	if err != nil {
+		return nil, err
+	}
+
+	if !overwrite {
+		for _, f := range files {
+			if _, err := os.Stat(f.path); err == nil {
+				return nil, fmt.Errorf("%s already exists", f.path)
+			}
+		}
+	}
+
+	written := make([]string, 0, len(files))
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(f.path, f.data, f.mode); err != nil {
+			return written, err
+		}
+		written = append(written, f.path)
+	}
+	return written, nil
+}
+
+// archivedFile is a file from an archive and where it goes
+type archivedFile struct {
+	path string
+	mode fs.FileMode
+	data []byte
+}
+
+// readFiles unpacks the tar.gz in plain, mapping each entry into dirs and
+// rejecting any that would land outside them
+func readFiles(plain []byte, dirs Dirs) ([]archivedFile, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(io.LimitReader(gz, maxArchiveSize))
+
+	var files []archivedFile
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		top, rel, _ := strings.Cut(hdr.Name, "/")
+		var base string
+		switch top {
+		case "config":
+			base = dirs.Config
+		case "state":
+			base = dirs.State
+		default:
+			return nil, fmt.Errorf("unexpected archive entry %q", hdr.Name)
+		}
+		// ValidPath takes a backslash for an ordinary character, so on
+		// Windows "..\x" or a drive letter would pass it; IsLocal checks
+		// the name as this OS will read it
+		local := filepath.FromSlash(rel)
+		if !fs.ValidPath(rel) || rel == "." || !filepath.IsLocal(local) {
+			return nil, fmt.Errorf("unsafe archive entry %q", hdr.Name)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, archivedFile{
+			path: filepath.Join(base, local),
+			mode: fs.FileMode(hdr.Mode).Perm()&0700 | 0600, // owner only
+			data: data,
+		})
+	}
+}
+
+// newAEAD derives the archive key from passphrase and salt
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,24 @@
+// Package truststore adds Peer-Drop's local certificate authority to the
+// operating system's trust store, so browsers on this machine accept the
+// server's certificate. Browsers with their own store, such as Firefox,
+// need it imported there by hand.
+package truststore
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ErrUnsupported is returned on platforms without trust store integration
+var ErrUnsupported = errors.New("installing certificates is not supported on this platform")
+
+// run runs a trust store tool, wrapping its output into the error
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
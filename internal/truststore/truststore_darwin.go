@@ -0,0 +1,17 @@
+package truststore
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Install adds the certificate at certPath to the login keychain as a
+// trusted root. macOS asks for the user's password to confirm.
+func Install(certPath string) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	keychain := filepath.Join(home, "Library", "Keychains", "login.keychain-db")
+	return run("security", "add-trusted-cert", "-r", "trustRoot", "-k", keychain, certPath)
+}
@@ -0,0 +1,49 @@
+package truststore
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Distribution trust store layouts: where anchors go and the tool that
+// rebuilds the bundle from them
+var stores = []struct {
+	dir    string
+	update string
+}{
+	{"/usr/local/share/ca-certificates", "update-ca-certificates"}, // Debian, Ubuntu, Alpine
+	{"/etc/pki/ca-trust/source/anchors", "update-ca-trust"},        // Fedora, RHEL
+	{"/etc/ca-certificates/trust-source/anchors", "trust"},         // Arch
+}
+
+// Install adds the certificate at certPath to the system trust store.
+// It needs root.
+func Install(certPath string) error {
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range stores {
+		if _, err := os.Stat(s.dir); err != nil {
+			continue
+		}
+		if _, err := exec.LookPath(s.update); err != nil {
+			continue
+		}
+
+		if err := os.WriteFile(filepath.Join(s.dir, "peer-drop.crt"), data, 0644); err != nil {
+			if errors.Is(err, os.ErrPermission) {
+				return errors.New("installing a certificate requires root, run this with sudo")
+			}
+			return err
+		}
+		if s.update == "trust" {
+			return run("trust", "extract-compat")
+		}
+		return run(s.update)
+	}
+	return ErrUnsupported
+}
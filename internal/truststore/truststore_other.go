@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package truststore
+
+// Install adds the certificate at certPath to the system trust store
+func Install(certPath string) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,7 @@
+package truststore
+
+// Install adds the certificate at certPath to the current user's trusted
+// root store. Windows asks for confirmation.
+func Install(certPath string) error {
+	return run("certutil", "-user", "-addstore", "Root", certPath)
+}
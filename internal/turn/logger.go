@@ -0,0 +1,46 @@
+package turn
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/pion/logging"
+)
+
+// loggerFactory routes pion's logging through slog. Pion's trace output
+// is dropped and its info output is demoted to debug, since it logs every
+// allocation and permission.
+type loggerFactory struct {
+	logger *slog.Logger
+}
+
+func (f *loggerFactory) NewLogger(scope string) logging.LeveledLogger {
+	return &leveledLogger{logger: f.logger.With("scope", scope)}
+}
+
+type leveledLogger struct {
+	logger *slog.Logger
+}
+
+func (l *leveledLogger) Trace(string)          {}
+func (l *leveledLogger) Tracef(string, ...any) {}
+
+func (l *leveledLogger) Debug(msg string) { l.logger.Debug(msg) }
+func (l *leveledLogger) Debugf(format string, args ...any) {
+	l.logger.Debug(fmt.Sprintf(format, args...))
+}
+
+func (l *leveledLogger) Info(msg string) { l.logger.Debug(msg) }
+func (l *leveledLogger) Infof(format string, args ...any) {
+	l.logger.Debug(fmt.Sprintf(format, args...))
+}
+
+func (l *leveledLogger) Warn(msg string) { l.logger.Warn(msg) }
+func (l *leveledLogger) Warnf(format string, args ...any) {
+	l.logger.Warn(fmt.Sprintf(format, args...))
+}
+
+func (l *leveledLogger) Error(msg string) { l.logger.Error(msg) }
+func (l *leveledLogger) Errorf(format string, args ...any) {
+	l.logger.Error(fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,208 @@
+// Package turn runs a small embedded STUN/TURN server so browsers that
+// cannot reach each other directly (e.g. Wi-Fi with client isolation) can
+// still connect over WebRTC through this machine.
+package turn
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+
+	pionturn "github.com/pion/turn/v4"
+)
+
+const (
+	// DefaultPort is the standard STUN/TURN port
+	DefaultPort = 3478
+
+	realm = "peerdrop"
+
+	// How long credentials handed to a client stay valid
+	credentialTTL = 24 * time.Hour
+)
+
+// Credentials are short-lived TURN credentials for one client
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Server is a running STUN/TURN server
+type Server struct {
+	server  *pionturn.Server
+	port    int
+	relayIP net.IP
+	secret  string
+	logger  *slog.Logger
+}
+
+// Start listens for STUN/TURN on UDP port. With listenIPs it listens on
+// those addresses and on loopback, relaying through the address a request
+// arrived on (the first one for loopback); otherwise it listens on all
+// of them. Relayed traffic is then advertised on relayIP, which must be
+// reachable by every client; when nil the address of the default
+// outbound interface is used.
+func Start(port int, relayIP net.IP, listenIPs []net.IP, logger *slog.Logger) (*Server, error) {
+	type listener struct {
+		addr  net.IP // to listen on
+		relay net.IP // to advertise and relay through
+		bind  string // to bind relay sockets to
+	}
+	var listeners []listener
+	if len(listenIPs) == 0 {
+		if relayIP == nil {
+			relayIP = outboundIP()
+			if relayIP == nil {
+				return nil, errors.New("no usable network address for TURN relay")
+			}
+		}
+		listeners = append(listeners, listener{net.IPv4zero, relayIP, "0.0.0.0"})
+	} else {
+		for _, ip := range listenIPs {
+			relay := relayIP
+			if relay == nil {
+				relay = ip
+			}
+			listeners = append(listeners, listener{ip, relay, ip.String()})
+		}
+		first := listeners[0]
+		listeners = append(listeners, listener{net.IPv4(127, 0, 0, 1), first.relay, first.bind})
+	}
+
+	secret := make([]byte, 32)
+	rand.Read(secret)
+
+	s := &Server{
+		port:    port,
+		relayIP: listeners[0].relay,
+		secret:  hex.EncodeToString(secret),
+		logger:  logger,
+	}
+
+	var configs []pionturn.PacketConnConfig
+	closeAll := func() {
+		for _, c := range configs {
+			c.PacketConn.Close()
+		}
+	}
+	for _, l := range listeners {
+		conn, err := net.ListenPacket("udp4", net.JoinHostPort(l.addr.String(), strconv.Itoa(port)))
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		configs = append(configs, pionturn.PacketConnConfig{
+			PacketConn: conn,
+			RelayAddressGenerator: &pionturn.RelayAddressGeneratorStatic{
+				RelayAddress: l.relay,
+				Address:      l.bind,
+			},
+			PermissionHandler: s.permit,
+		})
+	}
+
+	factory := &loggerFactory{logger: logger.With("component", "turn")}
+	var err error
+	s.server, err = pionturn.NewServer(pionturn.ServerConfig{
+		Realm:             realm,
+		LoggerFactory:     factory,
+		AuthHandler:       pionturn.LongTermTURNRESTAuthHandler(s.secret, factory.NewLogger("auth")),
+		PacketConnConfigs: configs,
+	})
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+
+	logger.Info("TURN server listening", "port", port, "relayIP", s.relayIP.String())
+	return s, nil
+}
+
+// permit reports whether a client may relay to peerIP. The server is
+// there for browsers on the LAN that can't reach each other directly, so
+// only other private addresses are allowed: not the internet, and not
+// this machine, whose loopback and own addresses would let clients reach
+// services that aren't meant for the network. IsPrivate already rules out
+// loopback, unspecified and multicast addresses.
+func (s *Server) permit(clientAddr net.Addr, peerIP net.IP) bool {
+	if !peerIP.IsPrivate() || ownAddress(peerIP) {
+		s.logger.Debug("TURN peer refused", "client", clientAddr.String(), "peer", peerIP.String())
+		return false
+	}
+	return true
+}
+
+// Port returns the UDP port the server listens on
+func (s *Server) Port() int {
+	return s.port
+}
+
+// URLs returns the STUN and TURN URLs for a client that reached the web UI
+// at host
+func (s *Server) URLs(host string) (stun, turn string) {
+	addr := net.JoinHostPort(host, strconv.Itoa(s.port))
+	return "stun:" + addr, "turn:" + addr + "?transport=udp"
+}
+
+// Credentials issues time-limited credentials for clientID
+func (s *Server) Credentials(clientID string) (Credentials, error) {
+	username, password, err := pionturn.GenerateLongTermTURNRESTCredentials(s.secret, clientID, credentialTTL)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{Username: username, Password: password}, nil
+}
+
+// AllocationCount returns the number of active relay allocations
+func (s *Server) AllocationCount() int {
+	return s.server.AllocationCount()
+}
+
+// Close stops the server and releases all allocations
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// ownAddress reports whether ip is one of this machine's addresses. The
+// interfaces are read each time, as addresses come and go while running.
+func ownAddress(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		// Unknown, so assume the worst
+		return true
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// outboundIP returns the local address used to reach other networks, which
+// on a LAN is the address other devices know this machine by. No packets are
+// sent; dialing UDP only selects a route.
+func outboundIP() net.IP {
+	conn, err := net.Dial("udp4", "192.0.2.1:9")
+	if err == nil {
+		defer conn.Close()
+		if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && !addr.IP.IsLoopback() {
+			return addr.IP
+		}
+	}
+
+	// No default route: fall back to the first private interface address
+	addrs, _ := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			if ip := ipNet.IP.To4(); ip != nil && ip.IsPrivate() {
+				return ip
+			}
+		}
+	}
+	return nil
+}
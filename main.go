@@ -7,10 +7,11 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
 	"Peer-Drop/internal/config"
+	"Peer-Drop/internal/logbuf"
 	"Peer-Drop/internal/server"
 )
 
@@ -18,10 +19,47 @@ var (
 	version = "2.0.0"
 )
 
+// Log records kept for /api/admin/logs/stream
+const logBufferSize = 1000
+
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "firewall":
+			runFirewall(os.Args[2:])
+			return
+		case "export-state":
+			runExportState(os.Args[2:])
+			return
+		case "import-state":
+			runImportState(os.Args[2:])
+			return
+		case "replay":
+			runReplay(os.Args[2:])
+			return
+		case "token":
+			runToken(os.Args[2:])
+			return
+		case "password":
+			runPassword(os.Args[2:])
+			return
+		case "admin":
+			runAdmin(os.Args[2:])
+			return
+		case "trust-cert":
+			runTrustCert(os.Args[2:])
+			return
+		}
+	}
+
 	// Flags
 	port := flag.Int("port", 0, "Server port (default: 8080)")
 	verbose := flag.Bool("verbose", false, "Verbose logging")
+	useTLS := flag.Bool("tls", false, "Serve HTTPS with a certificate from a local CA")
+	useTURN := flag.Bool("turn", false, "Run a built-in STUN/TURN relay for isolated networks")
+	interfaces := flag.String("interfaces", "", "Comma-separated network interfaces to serve on (default: all)")
+	noQR := flag.Bool("no-qr", false, "Don't print a QR code of the server address at startup")
 	showVersion := flag.Bool("version", false, "Show version")
 	showHelp := flag.Bool("help", false, "Show help")
 
@@ -37,7 +75,7 @@ func main() {
 		return
 	}
 
-	runServer(*port, *verbose)
+	runServer(*port, *verbose, *useTLS, *useTURN, *interfaces, *noQR)
 }
 
 func printHelp() {
@@ -45,10 +83,24 @@ func printHelp() {
 
 Usage:
   peer-drop [flags]
+  peer-drop firewall <allow|remove|status> [-port n] [-public]
+  peer-drop export-state [-o file]
+  peer-drop import-state [-force] <file>
+  peer-drop replay [-url ws://host:port/ws] [-run name] [vector.json...]
+  peer-drop token <add [-admin] name|list|remove name>
+  peer-drop password <set|clear>
+  peer-drop admin [-url http://host:port] <stats|clients|kick|rooms|...>
+  peer-drop trust-cert
 
 Flags:
   -port int       Server port (default 8080)
   -verbose        Enable verbose logging
+  -tls            Serve HTTPS with a certificate from a local CA
+  -turn           Run a built-in STUN/TURN relay (UDP 3478) for networks
+                  with client isolation
+  -interfaces     Serve only on these network interfaces, e.g. eth0,wlan0
+                  (localhost is always served)
+  -no-qr          Don't print a QR code of the server address
   -version        Print version information
   -help           Show this help message
 
@@ -58,6 +110,37 @@ How it works:
   3. Devices on the same network automatically see each other
   4. Click on a device to send files
 
+Moving to a new machine:
+  export-state writes config, TLS certificate and persistent rooms to a
+  passphrase-encrypted archive; import-state restores it. The passphrase
+  is prompted for, or read from -passphrase-file or
+  PEERDROP_STATE_PASSPHRASE.
+
+Access tokens:
+  token add prints a new token and stores its hash in the config. Once
+  any exist, the API and signaling need "Authorization: Bearer <token>";
+  the web UI asks for it. Only tokens added with -admin may use the admin
+  API and edit groups from another machine. Reload or restart the server
+  after changes.
+
+UI password:
+  password set prompts for a password the web UI asks for before showing
+  the page, and stores a salted hash of it in the config. A plain
+  ui_password written into the config by hand is hashed the same way
+  when the config is next loaded.
+
+HTTPS:
+  -tls creates a local certificate authority and a server certificate
+  signed by it. Devices that install the CA, from /api/cert or the QR
+  code printed at startup, trust the server without a warning, even
+  after the certificate is reissued for a new address. trust-cert adds
+  it to this machine's trust store (Firefox keeps its own).
+
+Protocol conformance:
+  replay runs canned signaling conversations against a running hub and
+  checks its answers, for testing other clients' servers or this one.
+  -list shows the built-in vectors.
+
 Features:
   - Automatic peer discovery on local network
   - Works in any browser (no app needed)
@@ -65,7 +148,7 @@ Features:
   - Public rooms for sharing across networks`)
 }
 
-func runServer(port int, verbose bool) {
+func runServer(port int, verbose bool, useTLS bool, useTURN bool, interfaces string, noQR bool) {
 	// Load config
 	cfg, err := config.Load()
 	if err != nil {
@@ -73,50 +156,98 @@ func runServer(port int, verbose bool) {
 		os.Exit(1)
 	}
 
-	// Override with flags
-	if port > 0 {
-		cfg.Port = port
+	// Override with flags; a config reload applies them again
+	applyFlags := func(cfg *config.Config) {
+		if port > 0 {
+			cfg.Port = port
+		}
+		if useTLS {
+			cfg.TLS = true
+		}
+		if useTURN {
+			cfg.TURN = true
+		}
+		if interfaces != "" {
+			cfg.Interfaces = nil
+			for _, name := range strings.Split(interfaces, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					cfg.Interfaces = append(cfg.Interfaces, name)
+				}
+			}
+		}
 	}
+	applyFlags(cfg)
 
 	// Setup logger
 	logLevel := slog.LevelInfo
 	if verbose {
 		logLevel = slog.LevelDebug
 	}
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
+	logs := logbuf.New(logBufferSize)
+	logger := slog.New(logs.Handler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel})))
 
-	// Start server
-	srv := server.New(cfg.Port, logger)
+	warnIfFirewallBlocks(cfg.Port)
 
-	// Handle shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		<-sigChan
-		logger.Info("shutting down...")
-
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer shutdownCancel()
+	// Start server
+	srv, err := server.New(cfg, version, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start server: %v\n", err)
+		os.Exit(1)
+	}
+	srv.SetLogBuffer(logs)
+	srv.SetConfigOverrides(applyFlags)
 
-		srv.Shutdown(shutdownCtx)
-		os.Exit(0)
-	}()
+	// Stop on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	fmt.Printf("\n")
 	fmt.Printf("  Peer-Drop v%s\n", version)
 	fmt.Printf("  ─────────────────────────────\n")
 	fmt.Printf("  Port: %d\n", cfg.Port)
+	if turnPort := srv.TURNPort(); turnPort != 0 {
+		fmt.Printf("  TURN relay: udp/%d\n", turnPort)
+	}
 	fmt.Printf("\n")
+
+	scheme := "http"
+	if fingerprint := srv.TLSFingerprint(); fingerprint != "" {
+		scheme = "https"
+		fmt.Printf("  TLS certificate authority fingerprint (SHA-256):\n")
+		fmt.Printf("  %s\n", fingerprint)
+		fmt.Printf("  Check it matches the issuer shown in the browser before trusting it.\n")
+		fmt.Printf("  Devices that install it from /api/cert skip the warning from then on;\n")
+		fmt.Printf("  \"peer-drop trust-cert\" installs it on this machine.\n")
+		fmt.Printf("\n")
+	}
+
 	fmt.Printf("  Open in your browser:\n")
-	fmt.Printf("  → http://localhost:%d\n", cfg.Port)
+	fmt.Printf("  → %s://localhost:%d\n", scheme, cfg.Port)
 	fmt.Printf("\n")
 	fmt.Printf("  On other devices (same network):\n")
-	fmt.Printf("  → http://<this-computer-ip>:%d\n", cfg.Port)
-	fmt.Printf("\n")
+	if urls := srv.URLs(); len(urls) > 0 {
+		for _, url := range urls {
+			fmt.Printf("  → %s\n", url)
+		}
+		fmt.Printf("\n")
+		if !noQR {
+			printQR(urls[0])
+			fmt.Printf("\n")
+			if scheme == "https" {
+				fmt.Printf("  Scan to install the certificate on a phone:\n")
+				printQR(urls[0] + "/api/cert")
+				fmt.Printf("\n")
+			}
+		}
+	} else {
+		fmt.Printf("  → %s://<this-computer-ip>:%d\n", scheme, cfg.Port)
+		fmt.Printf("\n")
+	}
 
-	if err := srv.Start(); err != nil {
+	if err := srv.Run(ctx); err != nil {
 		logger.Error("server error", "error", err)
 		os.Exit(1)
 	}
+
+	logger.Info("shutdown complete")
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"Peer-Drop/internal/config"
+
+	"golang.org/x/term"
+)
+
+// runPassword implements "peer-drop password <set|clear>"
+func runPassword(args []string) {
+	if len(args) != 1 {
+		passwordUsage()
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "set":
+		in := bufio.NewReader(os.Stdin)
+		prompt := func(label string) string { return promptSecret(in, label) }
+		password := prompt("New UI password: ")
+		if password == "" {
+			fmt.Fprintln(os.Stderr, "A password is required; use \"password clear\" to remove it.")
+			os.Exit(2)
+		}
+		if prompt("Repeat password: ") != password {
+			fmt.Fprintln(os.Stderr, "Passwords do not match")
+			os.Exit(2)
+		}
+		if err := cfg.SetUIPassword(password); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to hash password: %v\n", err)
+			os.Exit(1)
+		}
+		saveConfig(cfg)
+		fmt.Println("UI password set. Reload or restart Peer-Drop to require it.")
+
+	case "clear":
+		cfg.SetUIPassword("")
+		saveConfig(cfg)
+		fmt.Println("UI password removed; the UI will be open after a reload or restart.")
+
+	default:
+		passwordUsage()
+	}
+}
+
+// promptSecret asks for a secret on stderr and reads it from in. From a
+// terminal it is read without echo; otherwise, e.g. piped in by a script,
+// it is the next line.
+func promptSecret(in *bufio.Reader, label string) string {
+	fmt.Fprint(os.Stderr, label)
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		b, _ := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(b)
+	}
+	line, _ := in.ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+func passwordUsage() {
+	fmt.Fprintln(os.Stderr, "usage: peer-drop password <set|clear>")
+	os.Exit(2)
+}
@@ -0,0 +1,217 @@
+// Package client is a Go client for a Peer-Drop server: its HTTP API and
+// the signaling connection browsers use to find peers and offer files.
+//
+//	c, err := client.New("http://192.168.1.10:8080", client.WithToken(token))
+//	info, err := c.Info(ctx)
+//	conn, err := c.Dial(ctx, client.JoinOptions{Name: "backup-box"})
+//
+// The request and message types are the server's own, so the client
+// always speaks the protocol of the server it was built with.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"Peer-Drop/internal/api"
+	"Peer-Drop/internal/config"
+	"Peer-Drop/internal/signaling"
+)
+
+// HTTP API bodies
+type (
+	Version      = api.Version
+	Info         = api.Info
+	NetInterface = api.NetInterface
+	ReloadResult = api.ReloadResult
+	PeerGroup    = config.PeerGroup
+)
+
+// Admin API bodies
+type (
+	ClientInfo = signaling.ClientInfo
+	RoomInfo   = signaling.RoomInfo
+)
+
+// Client talks to one Peer-Drop server
+type Client struct {
+	base  *url.URL
+	http  *http.Client
+	token string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithToken authenticates requests with an API token from
+// "peer-drop token add"
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient replaces http.DefaultClient, e.g. to trust the server's
+// self-signed certificate
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// New creates a client for the server at baseURL, such as
+// "http://localhost:8080"
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("server URL must be http or https: %s", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	c := &Client{base: u, http: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// StatusError is returned for responses other than 2xx
+type StatusError struct {
+	StatusCode int
+	Message    string // body of the response, as the server's http.Error wrote it
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %s", http.StatusText(e.StatusCode), e.Message)
+}
+
+// Version returns the server's version and the protocol versions it speaks
+func (c *Client) Version(ctx context.Context) (Version, error) {
+	var v Version
+	err := c.do(ctx, http.MethodGet, "/api/version", nil, &v)
+	return v, err
+}
+
+// Info returns where the server can be reached
+func (c *Client) Info(ctx context.Context) (Info, error) {
+	var info Info
+	err := c.do(ctx, http.MethodGet, "/api/info", nil, &info)
+	return info, err
+}
+
+// Stats returns the server's counters
+func (c *Client) Stats(ctx context.Context) (map[string]int, error) {
+	var stats map[string]int
+	err := c.do(ctx, http.MethodGet, "/api/stats", nil, &stats)
+	return stats, err
+}
+
+// Groups returns the named peer groups
+func (c *Client) Groups(ctx context.Context) ([]PeerGroup, error) {
+	var body api.Groups
+	err := c.do(ctx, http.MethodGet, "/api/groups", nil, &body)
+	return body.Groups, err
+}
+
+// PutGroup creates or replaces a peer group. Like the admin calls it
+// needs an admin API token unless the server is on this machine.
+func (c *Client) PutGroup(ctx context.Context, name string, members []string) error {
+	return c.do(ctx, http.MethodPut, "/api/groups/"+url.PathEscape(name), api.GroupMembers{Members: members}, nil)
+}
+
+// DeleteGroup removes a peer group, with the same access as PutGroup
+func (c *Client) DeleteGroup(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/api/groups/"+url.PathEscape(name), nil, nil)
+}
+
+// Clients lists the connected signaling clients. Like the other admin
+// calls it needs an admin token, or a client on the server's machine.
+func (c *Client) Clients(ctx context.Context) ([]ClientInfo, error) {
+	var clients []ClientInfo
+	err := c.do(ctx, http.MethodGet, "/api/admin/clients", nil, &clients)
+	return clients, err
+}
+
+// Kick disconnects a signaling client, telling it reason
+func (c *Client) Kick(ctx context.Context, id, reason string) error {
+	p := "/api/admin/clients/" + url.PathEscape(id)
+	if reason != "" {
+		p += "?reason=" + url.QueryEscape(reason)
+	}
+	return c.do(ctx, http.MethodDelete, p, nil, nil)
+}
+
+// Rooms lists the public and IP rooms
+func (c *Client) Rooms(ctx context.Context) ([]RoomInfo, error) {
+	var rooms []RoomInfo
+	err := c.do(ctx, http.MethodGet, "/api/admin/rooms", nil, &rooms)
+	return rooms, err
+}
+
+// CloseRoom closes a public room by code
+func (c *Client) CloseRoom(ctx context.Context, code string) error {
+	return c.do(ctx, http.MethodDelete, "/api/admin/rooms/"+url.PathEscape(code), nil, nil)
+}
+
+// Reload makes the server re-read its config file
+func (c *Client) Reload(ctx context.Context) (ReloadResult, error) {
+	var res ReloadResult
+	err := c.do(ctx, http.MethodPost, "/api/admin/reload", nil, &res)
+	return res, err
+}
+
+// Restart asks the server to replace itself with a fresh process
+func (c *Client) Restart(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/admin/restart", nil, nil)
+}
+
+// do sends a request with body encoded as JSON, if not nil, and decodes
+// the response into out, if not nil
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	u := *c.base
+	p, query, _ := strings.Cut(path, "?")
+	u.Path += p
+	u.RawQuery = query
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
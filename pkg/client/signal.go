@@ -0,0 +1,227 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"Peer-Drop/internal/signaling"
+)
+
+// Signaling message types
+type (
+	Message          = signaling.Message
+	PeerInfo         = signaling.PeerInfo
+	FileInfo         = signaling.FileInfo
+	TransferRequest  = signaling.TransferRequestPayload
+	TransferResponse = signaling.TransferResponsePayload
+)
+
+// Messages queued for Events before new ones are dropped
+const eventBuffer = 256
+
+// JoinOptions describe this client to the peers it meets
+type JoinOptions struct {
+	Name         string
+	Platform     string // shown next to the name, e.g. "linux"
+	Locale       string
+	Version      string // of the program using the client, informational
+	Capabilities []string
+}
+
+// Conn is a signaling connection. The hub puts it in the room of its
+// network; peers there see it like any browser.
+type Conn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+
+	mu    sync.Mutex
+	peers map[string]PeerInfo
+
+	events chan Message
+	err    error // why the connection ended, set before events is closed
+}
+
+// Dial opens a signaling connection and joins as opts describes
+func (c *Client) Dial(ctx context.Context, opts JoinOptions) (*Conn, error) {
+	u := *c.base
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	u.Path += "/ws"
+
+	dialer := *websocket.DefaultDialer
+	if t, ok := c.http.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = t.TLSClientConfig
+	}
+	header := http.Header{}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	ws, resp, err := dialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			return nil, &StatusError{StatusCode: resp.StatusCode}
+		}
+		return nil, err
+	}
+
+	conn := &Conn{
+		ws:     ws,
+		peers:  make(map[string]PeerInfo),
+		events: make(chan Message, eventBuffer),
+	}
+	err = conn.send(signaling.TypeJoin, "", signaling.JoinPayload{
+		Name:            opts.Name,
+		Platform:        opts.Platform,
+		Locale:          opts.Locale,
+		ProtocolVersion: signaling.ProtocolVersion,
+		Version:         opts.Version,
+		Capabilities:    opts.Capabilities,
+	})
+	if err != nil {
+		ws.Close()
+		return nil, err
+	}
+	go conn.readLoop()
+	return conn, nil
+}
+
+// Events returns every message the hub sends, after Conn has tracked
+// peers from it. It is closed when the connection ends; Err says why.
+// Messages are dropped while the channel is full, so keep reading it.
+func (c *Conn) Events() <-chan Message {
+	return c.events
+}
+
+// Err returns why the connection ended, once Events is closed
+func (c *Conn) Err() error {
+	return c.err
+}
+
+// Peers returns the peers currently visible, sorted by name
+func (c *Conn) Peers() []PeerInfo {
+	c.mu.Lock()
+	peers := make([]PeerInfo, 0, len(c.peers))
+	for _, p := range c.peers {
+		peers = append(peers, p)
+	}
+	c.mu.Unlock()
+
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Name < peers[j].Name })
+	return peers
+}
+
+// RequestTransfer offers files to a peer and returns the transfer's ID.
+// The answer arrives on Events as a transfer-response. Moving the data
+// itself, over WebRTC or relay chunks, is up to the caller.
+func (c *Conn) RequestTransfer(peerID string, files []FileInfo) (string, error) {
+	if len(files) == 0 {
+		return "", errors.New("no files to offer")
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	req := TransferRequest{TransferID: hex.EncodeToString(b), Files: files}
+	for _, f := range files {
+		req.TotalSize += f.Size
+	}
+	return req.TransferID, c.send(signaling.TypeTransferRequest, peerID, req)
+}
+
+// Accept accepts a transfer a peer requested
+func (c *Conn) Accept(peerID, transferID string) error {
+	return c.send(signaling.TypeTransferResponse, peerID, TransferResponse{TransferID: transferID, Accepted: true})
+}
+
+// Reject declines a transfer a peer requested
+func (c *Conn) Reject(peerID, transferID, reason string) error {
+	return c.send(signaling.TypeTransferResponse, peerID, TransferResponse{TransferID: transferID, Reason: reason})
+}
+
+// Send sends any message, for the parts of the protocol without a
+// method of their own
+func (c *Conn) Send(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+// Close ends the connection
+func (c *Conn) Close() error {
+	c.writeMu.Lock()
+	c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	c.writeMu.Unlock()
+	return c.ws.Close()
+}
+
+func (c *Conn) send(kind, targetID string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return c.Send(Message{Type: kind, TargetID: targetID, Payload: data})
+}
+
+// readLoop splits the hub's newline-batched frames into messages
+func (c *Conn) readLoop() {
+	defer close(c.events)
+	for {
+		kind, data, err := c.ws.ReadMessage()
+		if err != nil {
+			c.err = err
+			return
+		}
+		if kind != websocket.TextMessage {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			var msg Message
+			if strings.TrimSpace(line) == "" || json.Unmarshal([]byte(line), &msg) != nil {
+				continue
+			}
+			c.track(msg)
+			select {
+			case c.events <- msg:
+			default:
+			}
+		}
+	}
+}
+
+// track keeps the peer list up to date
+func (c *Conn) track(msg Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch msg.Type {
+	case signaling.TypePeers:
+		var p signaling.PeersPayload
+		if json.Unmarshal(msg.Payload, &p) == nil {
+			clear(c.peers)
+			for _, peer := range p.Peers {
+				c.peers[peer.ID] = peer
+			}
+		}
+	case signaling.TypePeerJoined:
+		var p signaling.PeerJoinedPayload
+		if json.Unmarshal(msg.Payload, &p) == nil {
+			c.peers[p.Peer.ID] = p.Peer
+		}
+	case signaling.TypePeerLeft:
+		var p signaling.PeerLeftPayload
+		if json.Unmarshal(msg.Payload, &p) == nil {
+			delete(c.peers, p.PeerID)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// printQR draws text as a QR code with half-block characters, two
+// modules per character cell. Light modules are drawn and dark ones left
+// blank, so the code reads correctly on the usual dark terminal.
+func printQR(text string) {
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		return
+	}
+
+	const quiet = 2 // modules of border
+	light := func(x, y int) bool {
+		if x < 0 || y < 0 || x >= code.Size || y >= code.Size {
+			return true
+		}
+		return !code.Black(x, y)
+	}
+
+	var b strings.Builder
+	for y := -quiet; y < code.Size+quiet; y += 2 {
+		b.WriteString("  ")
+		for x := -quiet; x < code.Size+quiet; x++ {
+			top, bottom := light(x, y), light(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	fmt.Print(b.String())
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"Peer-Drop/internal/conformance"
+)
+
+// runReplay implements "peer-drop replay [-url u] [-run name] [vector.json...]"
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	url := fs.String("url", "ws://localhost:8080/ws", "WebSocket endpoint of the hub to test")
+	only := fs.String("run", "", "Only run the vector with this name")
+	list := fs.Bool("list", false, "List the built-in vectors and exit")
+	fs.Parse(args)
+
+	vectors, err := loadVectors(fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load vectors: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *list {
+		for _, v := range vectors {
+			fmt.Printf("%-14s %s\n", v.Name, v.Description)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	failed, ran := 0, 0
+	for _, v := range vectors {
+		if *only != "" && v.Name != *only {
+			continue
+		}
+		ran++
+		start := time.Now()
+		if err := conformance.Run(ctx, *url, v); err != nil {
+			failed++
+			fmt.Printf("FAIL  %s: %v\n", v.Name, err)
+			continue
+		}
+		fmt.Printf("ok    %s (%s)\n", v.Name, time.Since(start).Round(time.Millisecond))
+	}
+
+	if ran == 0 {
+		fmt.Fprintf(os.Stderr, "no vector named %q\n", *only)
+		os.Exit(2)
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d vectors failed\n", failed, ran)
+		os.Exit(1)
+	}
+}
+
+// loadVectors reads the given vector files, or the built-in vectors when
+// there are none
+func loadVectors(files []string) ([]conformance.Vector, error) {
+	if len(files) == 0 {
+		return conformance.Builtin()
+	}
+	var vectors []conformance.Vector
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		v, err := conformance.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"Peer-Drop/internal/paths"
+	"Peer-Drop/internal/statearchive"
+)
+
+// Environment variable holding the state archive passphrase, for scripts
+const passphraseEnv = "PEERDROP_STATE_PASSPHRASE"
+
+// runExportState implements "peer-drop export-state [-o file]"
+func runExportState(args []string) {
+	fs := flag.NewFlagSet("export-state", flag.ExitOnError)
+	out := fs.String("o", "peer-drop-state.pdstate", "Archive to write")
+	passFile := fs.String("passphrase-file", "", "Read the passphrase from this file")
+	fs.Parse(args)
+
+	passphrase := readPassphrase(*passFile, true)
+
+	var buf bytes.Buffer
+	if err := statearchive.Export(&buf, stateDirs(), passphrase); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to export state: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write archive: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("State exported to %s\n", *out)
+	fmt.Println("Run 'peer-drop import-state' with it on the new machine.")
+}
+
+// runImportState implements "peer-drop import-state [-force] <file>"
+func runImportState(args []string) {
+	fs := flag.NewFlagSet("import-state", flag.ExitOnError)
+	force := fs.Bool("force", false, "Replace existing config and state files")
+	passFile := fs.String("passphrase-file", "", "Read the passphrase from this file")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: peer-drop import-state [-force] [-passphrase-file f] <archive>")
+		os.Exit(2)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open archive: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	passphrase := readPassphrase(*passFile, false)
+	written, err := statearchive.Import(f, stateDirs(), passphrase, *force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to import state: %v\n", err)
+		if !*force && !errors.Is(err, statearchive.ErrBadPassphrase) {
+			fmt.Fprintln(os.Stderr, "Use -force to replace existing files.")
+		}
+		os.Exit(1)
+	}
+	for _, p := range written {
+		fmt.Printf("  restored %s\n", p)
+	}
+	fmt.Printf("Imported %d file(s); restart Peer-Drop to use them.\n", len(written))
+}
+
+// stateDirs returns the directories that make up the application state
+func stateDirs() statearchive.Dirs {
+	return statearchive.Dirs{Config: paths.ConfigDir(), State: paths.StateDir()}
+}
+
+// readPassphrase takes the passphrase from file, the environment or a
+// prompt, in that order. New archives are prompted for twice.
+func readPassphrase(file string, confirm bool) string {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read passphrase: %v\n", err)
+			os.Exit(1)
+		}
+		return strings.TrimRight(string(data), "\r\n")
+	}
+	if p := os.Getenv(passphraseEnv); p != "" {
+		return p
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	prompt := func(label string) string { return promptSecret(in, label) }
+
+	passphrase := prompt("Passphrase: ")
+	if passphrase == "" {
+		fmt.Fprintln(os.Stderr, "A passphrase is required")
+		os.Exit(2)
+	}
+	if confirm && prompt("Repeat passphrase: ") != passphrase {
+		fmt.Fprintln(os.Stderr, "Passphrases do not match")
+		os.Exit(2)
+	}
+	return passphrase
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"Peer-Drop/internal/apitoken"
+	"Peer-Drop/internal/config"
+)
+
+// runToken implements "peer-drop token <add|list|remove>"
+func runToken(args []string) {
+	if len(args) == 0 {
+		tokenUsage()
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("token add", flag.ExitOnError)
+		admin := fs.Bool("admin", false, "Also allow the admin API and group changes")
+		fs.Usage = tokenUsage
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			tokenUsage()
+		}
+		name := fs.Arg(0)
+		if slices.ContainsFunc(cfg.APITokens, func(t config.APIToken) bool { return t.Name == name }) {
+			fmt.Fprintf(os.Stderr, "A token named %q already exists.\n", name)
+			os.Exit(1)
+		}
+		token, hash := apitoken.Generate()
+		cfg.APITokens = append(cfg.APITokens, config.APIToken{Name: name, Hash: hash, Created: time.Now().UTC(), Admin: *admin})
+		saveConfig(cfg)
+		fmt.Println(token)
+		fmt.Fprintln(os.Stderr, "Store this token now; it can't be shown again.")
+		fmt.Fprintln(os.Stderr, "Reload or restart Peer-Drop to require it.")
+
+	case "list":
+		if len(cfg.APITokens) == 0 {
+			fmt.Println("No tokens; the API is open to anyone who can reach it.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSCOPE\tCREATED")
+		for _, t := range cfg.APITokens {
+			scope := "user"
+			if t.Admin {
+				scope = "admin"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, scope, t.Created.Local().Format(time.DateTime))
+		}
+		w.Flush()
+
+	case "remove":
+		if len(args) != 2 {
+			tokenUsage()
+		}
+		n := len(cfg.APITokens)
+		cfg.APITokens = slices.DeleteFunc(cfg.APITokens, func(t config.APIToken) bool { return t.Name == args[1] })
+		if len(cfg.APITokens) == n {
+			fmt.Fprintf(os.Stderr, "No token named %q.\n", args[1])
+			os.Exit(1)
+		}
+		saveConfig(cfg)
+		fmt.Printf("Removed %s.\n", args[1])
+		if len(cfg.APITokens) == 0 {
+			fmt.Println("No tokens left; the API will be open after a reload or restart.")
+		}
+
+	default:
+		tokenUsage()
+	}
+}
+
+func saveConfig(cfg *config.Config) {
+	if err := cfg.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save config: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func tokenUsage() {
+	fmt.Fprintln(os.Stderr, "usage: peer-drop token <add [-admin] name|list|remove name>")
+	os.Exit(2)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"Peer-Drop/internal/server"
+	"Peer-Drop/internal/truststore"
+)
+
+// runTrustCert implements "peer-drop trust-cert", which adds the local
+// CA to this machine's trust store. The CA is created if -tls hasn't
+// been used yet.
+func runTrustCert(args []string) {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: peer-drop trust-cert")
+		os.Exit(2)
+	}
+
+	path, err := server.CAFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load certificate authority: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := truststore.Install(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to install %s: %v\n", path, err)
+		if errors.Is(err, truststore.ErrUnsupported) {
+			fmt.Fprintln(os.Stderr, "Import it into the browser's certificate settings instead.")
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("Installed %s\n", path)
+	fmt.Println("Restart the browser to pick it up. Firefox keeps its own store; import it there by hand.")
+}
@@ -0,0 +1,81 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestEscape runs the UI's escapeHtml and jsArg under Node with file
+// names a sender could pick to attack the receiver's UI
+func TestEscape(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not installed")
+	}
+	src, err := Assets.ReadFile("static/js/escape.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{
+		`<script>alert(1)</script>.txt`,
+		`"><img src=x onerror=alert(1)>.png`,
+		`'); alert(1); ('.pdf`,
+		`\"); alert(1); //.sh`,
+		"invoice\u202efdp.exe",
+		"\u2067isolated\u2069 \u202a\u202b\u202c\u202d\u2066\u2068.doc",
+		"🎉 party & <🎂>.zip",
+		"&amp; already escaped.txt",
+	}
+
+	script := string(src) + `
+const names = JSON.parse(require('fs').readFileSync(0, 'utf8'));
+process.stdout.write(JSON.stringify(names.map(n => [escapeHtml(n), jsArg(n)])));
+`
+	input, _ := json.Marshal(names)
+	cmd := exec.Command(node, "-e", script)
+	cmd.Stdin = bytes.NewReader(input)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("node: %v", err)
+	}
+	var results [][2]string
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatalf("parse node output %q: %v", out, err)
+	}
+
+	bidi := strings.NewReplacer(
+		"\u202a", "\ufffd", "\u202b", "\ufffd", "\u202c", "\ufffd", "\u202d", "\ufffd", "\u202e", "\ufffd",
+		"\u2066", "\ufffd", "\u2067", "\ufffd", "\u2068", "\ufffd", "\u2069", "\ufffd",
+	)
+
+	for i, name := range names {
+		escaped, arg := results[i][0], results[i][1]
+		want := bidi.Replace(name)
+
+		// Safe as element content and inside quoted attributes, and reads
+		// back as the name with bidi controls made visible
+		if strings.ContainsAny(escaped, "<>\"'\u202a\u202b\u202c\u202d\u202e\u2066\u2067\u2068\u2069") {
+			t.Errorf("escapeHtml(%q) = %q, has characters left unescaped", name, escaped)
+		}
+		if got := html.UnescapeString(escaped); got != want {
+			t.Errorf("escapeHtml(%q) reads back as %q, want %q", name, got, want)
+		}
+
+		// Inside onclick="f(...)" the attribute can't be closed, and the
+		// handler sees a single string literal holding the name
+		if strings.ContainsAny(arg, "<>\"'") {
+			t.Errorf("jsArg(%q) = %q, has characters left unescaped", name, arg)
+		}
+		var literal string
+		if err := json.Unmarshal([]byte(html.UnescapeString(arg)), &literal); err != nil {
+			t.Errorf("jsArg(%q) = %q, not a single string literal: %v", name, arg, err)
+		} else if literal != want {
+			t.Errorf("jsArg(%q) passes %q, want %q", name, literal, want)
+		}
+	}
+}
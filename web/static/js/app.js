@@ -10,6 +10,23 @@ let peers = new Map();
 let selectedPeer = null;
 let selectedFiles = [];
 let publicRoomCode = null;
+let pendingTransferId = null; // outgoing transfer awaiting acceptance
+let groups = []; // [{ name, members: [device names] }]
+let selectedGroup = null;
+let groupSend = null; // aggregate state of a send to a group
+let chatSeen = new Set(); // keys of rendered chat messages, so history isn't shown twice
+let boardPosts = new Map(); // board post ID -> post
+let boardFile = null; // file picked for the next board post
+let clipboardMode = localStorage.getItem('peerdrop-clipboard-mode') || 'off'; // off, ask or auto
+
+// Longest chat message the server relays, in UTF-8 bytes
+const CHAT_MAX_BYTES = 4096;
+
+// Largest clipboard text the server relays, in UTF-8 bytes
+const CLIPBOARD_MAX_BYTES = 64 * 1024;
+
+// Largest file the server accepts on a room's board
+const BOARD_MAX_FILE_BYTES = 128 * 1024;
 
 // Module instances
 let webrtcManager = null;
@@ -20,6 +37,8 @@ document.addEventListener('DOMContentLoaded', () => {
     initializeDevice();
     initializeModules();
     setupUI();
+    checkServerVersion();
+    loadGroups();
     connect();
 });
 
@@ -57,6 +76,51 @@ function initializeModules() {
     setupFileTransferListeners();
 }
 
+/**
+ * Compare our protocol version with the server's and warn on skew
+ */
+async function checkServerVersion() {
+    try {
+        const res = await fetch('/api/version');
+        if (res.status === 401) {
+            // The session ended (password changed or server restarted)
+            const login = res.headers.get('X-Login-URL');
+            if (login) {
+                location.href = login;
+            } else {
+                requestAccessToken();
+            }
+            return;
+        }
+        const info = await res.json();
+        console.log('[App] Server version:', info);
+        wsManager.serverCapabilities = info.capabilities || [];
+
+        if (PROTOCOL_VERSION < info.minProtocolVersion) {
+            showNotification('This page is outdated, please reload', 'error');
+        } else if (PROTOCOL_VERSION > info.protocolVersion) {
+            showNotification(`Server v${info.version} is older than this page, some features may not work`, 'error');
+        }
+    } catch (err) {
+        console.error('[App] Failed to fetch server version:', err);
+    }
+}
+
+/**
+ * Ask for the server's access token and keep it in a cookie, which the
+ * browser sends with API requests and the WebSocket upgrade alike
+ */
+function requestAccessToken() {
+    const token = prompt('This Peer-Drop server needs an access token.\nAsk whoever runs it, or create one with "peer-drop token add".');
+    if (!token) {
+        showNotification('Not connected: an access token is required', 'error');
+        return;
+    }
+    const secure = location.protocol === 'https:' ? '; Secure' : '';
+    document.cookie = `peerdrop_token=${encodeURIComponent(token.trim())}; path=/; max-age=31536000; SameSite=Strict${secure}`;
+    location.reload();
+}
+
 /**
  * Connect to server
  */
@@ -81,6 +145,16 @@ function setupWebSocketListeners() {
         updatePeerList();
     });
 
+    wsManager.addEventListener('protocol-rejected', (e) => {
+        console.log('[App] Protocol rejected:', e.detail.reason);
+        showNotification(e.detail.reason || 'This page is outdated, please reload', 'error');
+    });
+
+    wsManager.addEventListener('kicked', (e) => {
+        console.log('[App] Disconnected by admin:', e.detail.reason);
+        showNotification(e.detail.reason || 'Disconnected by the server admin', 'error');
+    });
+
     wsManager.addEventListener('peers', (e) => {
         console.log('[App] Received peer list:', e.detail.payload);
         const peerList = e.detail.payload?.peers || [];
@@ -117,7 +191,7 @@ function setupWebSocketListeners() {
     wsManager.addEventListener('room-created', (e) => {
         console.log('[App] Room created:', e.detail.payload);
         publicRoomCode = e.detail.payload?.code;
-        showRoomCode(publicRoomCode);
+        showRoomCode(publicRoomCode, e.detail.payload?.alias);
     });
 
     wsManager.addEventListener('room-joined', (e) => {
@@ -132,6 +206,62 @@ function setupWebSocketListeners() {
         closeRoomModal();
     });
 
+    wsManager.addEventListener('nudge', (e) => {
+        handleNudge(e.detail.peerId, e.detail.payload || {});
+    });
+
+    wsManager.addEventListener('nudge-rejected', (e) => {
+        const { retryAfter } = e.detail.payload;
+        showNotification(`Please wait ${retryAfter}s before nudging again`, 'error');
+    });
+
+    wsManager.addEventListener('chat', (e) => {
+        const message = { peerId: e.detail.peerId, ...e.detail.payload };
+        appendChatMessage(message);
+        if (document.hidden) {
+            showDesktopNotification(`Message from ${message.name || 'Unknown'}`, message.text);
+        }
+    });
+
+    wsManager.addEventListener('chat-history', (e) => {
+        for (const message of e.detail.payload?.messages || []) {
+            appendChatMessage(message);
+        }
+    });
+
+    wsManager.addEventListener('board', (e) => {
+        for (const post of e.detail.payload?.posts || []) {
+            boardPosts.set(post.id, post);
+        }
+        updateBoard();
+    });
+
+    wsManager.addEventListener('board-post', (e) => {
+        const post = e.detail.payload;
+        boardPosts.set(post.id, post);
+        updateBoard();
+    });
+
+    wsManager.addEventListener('board-delete', (e) => {
+        boardPosts.delete(e.detail.payload?.id);
+        updateBoard();
+    });
+
+    wsManager.addEventListener('clipboard', (e) => {
+        handleIncomingClipboard(e.detail.payload || {});
+    });
+
+    wsManager.addEventListener('room-closed', (e) => {
+        const { code, reason } = e.detail.payload;
+        if (code === publicRoomCode) publicRoomCode = null;
+        const why = {
+            idle: 'after being idle',
+            expired: 'as it reached its time limit',
+            closed: 'by the server admin'
+        }[reason] || '';
+        showNotification(`Room ${code} closed ${why}`.trim());
+    });
+
     wsManager.addEventListener('room-error', (e) => {
         console.log('[App] Room error:', e.detail.payload);
         showNotification(e.detail.payload?.error || 'Room error', 'error');
@@ -143,30 +273,51 @@ function setupWebSocketListeners() {
  */
 function setupFileTransferListeners() {
     fileTransferManager.addEventListener('receive-request', (e) => {
-        const { transferId, peerId, files, totalSize } = e.detail;
+        const { transferId, peerId, files, totalSize, summary, previews } = e.detail;
         const peer = peers.get(peerId) || { name: 'Unknown' };
-        showIncomingTransfer(transferId, peer.name, files, totalSize);
+        const canPause = wsManager.peerSupports(peerId, CAPABILITY_RESUME);
+        showIncomingTransfer(transferId, peer.name, files, totalSize, summary, previews, canPause);
     });
 
     fileTransferManager.addEventListener('send-pending', (e) => {
+        if (groupSend) return; // fires before the transfer is added to the group
+        pendingTransferId = e.detail.transferId;
         updateSendProgress(0, 'Waiting for acceptance...');
+        document.getElementById('nudge-btn').classList.remove('hidden');
     });
 
     fileTransferManager.addEventListener('send-accepted', (e) => {
+        if (updateGroupSend(e.detail.transferId, 'accepted')) return;
+        clearPendingTransfer();
         updateSendProgress(0, 'Connecting...');
     });
 
     fileTransferManager.addEventListener('send-rejected', (e) => {
-        updateSendProgress(0, 'Transfer rejected');
+        if (updateGroupSend(e.detail.transferId, 'rejected')) return;
+        clearPendingTransfer();
+        const { reason } = e.detail;
+        updateSendProgress(0, reason ? `Transfer rejected: ${reason}` : 'Transfer rejected');
         setTimeout(closeSendModal, 1500);
     });
 
+    fileTransferManager.addEventListener('send-paused', (e) => {
+        if (isGroupTransfer(e.detail.transferId)) return;
+        document.getElementById('progress-text').textContent = 'Paused by receiver';
+    });
+
+    fileTransferManager.addEventListener('send-resumed', (e) => {
+        if (isGroupTransfer(e.detail.transferId)) return;
+        document.getElementById('progress-text').textContent = 'Resuming...';
+    });
+
     fileTransferManager.addEventListener('send-progress', (e) => {
+        if (updateGroupSend(e.detail.transferId, 'sending', e.detail.progress)) return;
         const { progress } = e.detail;
         updateSendProgress(progress * 100, `Sending... ${Math.round(progress * 100)}%`);
     });
 
     fileTransferManager.addEventListener('send-complete', (e) => {
+        if (updateGroupSend(e.detail.transferId, 'completed', 1)) return;
         updateSendProgress(100, 'Complete!');
         setTimeout(closeSendModal, 1500);
     });
@@ -181,6 +332,33 @@ function setupFileTransferListeners() {
         removeIncomingTransfer(transferId);
         showNotification('File received!');
     });
+
+    // Relayed transfers pass through the server: say whether it can read
+    // them, and give a fingerprint to compare with the other device
+    fileTransferManager.addEventListener('send-relay-security', (e) => {
+        const { transferId, encrypted, fingerprint } = e.detail;
+        if (!isGroupTransfer(transferId)) {
+            showRelaySecurity(document.getElementById('relay-security'), encrypted, fingerprint);
+        }
+        if (!encrypted) {
+            showNotification('Sending through the server without encryption: it can read these files', 'error');
+        }
+    });
+
+    fileTransferManager.addEventListener('receive-relay-security', (e) => {
+        const { transferId, encrypted, fingerprint } = e.detail;
+        const card = document.getElementById(`transfer-${transferId}`);
+        showRelaySecurity(card?.querySelector('.relay-security'), encrypted, fingerprint);
+        if (!encrypted) {
+            showNotification('Receiving through the server without encryption: it can read these files', 'error');
+        }
+    });
+
+    fileTransferManager.addEventListener('receive-failed', (e) => {
+        const { transferId, reason } = e.detail;
+        removeIncomingTransfer(transferId);
+        showNotification(reason || 'Transfer failed', 'error');
+    });
 }
 
 /**
@@ -191,9 +369,7 @@ function setupUI() {
     setupFileInput();
 
     // Room buttons
-    document.getElementById('create-room-btn')?.addEventListener('click', () => {
-        wsManager.createRoom();
-    });
+    document.getElementById('create-room-btn')?.addEventListener('click', createRoom);
 
     document.getElementById('join-room-btn')?.addEventListener('click', () => {
         openJoinRoomModal();
@@ -201,6 +377,35 @@ function setupUI() {
 
     // Edit device name
     document.getElementById('device-name')?.addEventListener('click', editDeviceName);
+
+    // Clipboard sharing; receiving is off until the user opts in
+    document.getElementById('share-clipboard-btn')?.addEventListener('click', () => shareClipboard());
+    const modeSelect = document.getElementById('clipboard-mode');
+    if (modeSelect) {
+        modeSelect.value = clipboardMode;
+        modeSelect.addEventListener('change', () => {
+            clipboardMode = modeSelect.value;
+            localStorage.setItem('peerdrop-clipboard-mode', clipboardMode);
+        });
+    }
+
+    document.getElementById('board-form')?.addEventListener('submit', (e) => {
+        e.preventDefault();
+        postToBoard();
+    });
+    document.getElementById('board-file-input')?.addEventListener('change', (e) => {
+        boardFile = e.target.files[0] || null;
+        document.getElementById('board-file-name').textContent = boardFile ? boardFile.name : '';
+    });
+
+    document.getElementById('chat-form')?.addEventListener('submit', (e) => {
+        e.preventDefault();
+        sendChatMessage();
+    });
+
+    // Browsers only allow asking for notification permission from a user
+    // gesture, so ask on the first click anywhere
+    document.addEventListener('click', requestNotificationPermission, { once: true });
 }
 
 /**
@@ -212,6 +417,7 @@ function updatePeerList() {
 
     if (peers.size === 0) {
         container.innerHTML = '<p class="empty-state">Searching for devices...</p>';
+        updateGroupList();
         return;
     }
 
@@ -221,14 +427,21 @@ function updatePeerList() {
                 <div class="peer-icon">${getPlatformIcon(peer.platform)}</div>
                 <div class="peer-details">
                     <h3>${escapeHtml(peer.name)}</h3>
-                    <p>${peer.platform || 'unknown'}</p>
+                    <p>${escapeHtml(peer.platform || 'unknown')}</p>
                 </div>
             </div>
-            <button class="btn btn-primary" onclick="openSendModal('${escapeHtml(peer.id)}')">
-                Send
-            </button>
+            <div class="peer-actions">
+                <button class="btn btn-secondary" onclick="shareClipboard(${jsArg(peer.id)})" title="Send your clipboard text">
+                    Clipboard
+                </button>
+                <button class="btn btn-primary" onclick="openSendModal(${jsArg(peer.id)})">
+                    Send
+                </button>
+            </div>
         </div>
     `).join('');
+
+    updateGroupList();
 }
 
 /**
@@ -239,22 +452,35 @@ function openSendModal(peerId) {
     if (!peer) return;
 
     selectedPeer = peer;
+    selectedGroup = null;
+    showSendModal(peer.name);
+}
+
+/**
+ * Reset and show the send modal for a peer or group
+ */
+function showSendModal(targetName) {
     selectedFiles = [];
 
-    document.getElementById('send-to-name').textContent = peer.name;
+    document.getElementById('send-to-name').textContent = targetName;
     document.getElementById('send-modal').classList.remove('hidden');
     document.getElementById('selected-files').classList.add('hidden');
     document.getElementById('send-progress').classList.add('hidden');
+    document.getElementById('relay-security').classList.add('hidden');
     document.getElementById('send-btn').disabled = true;
     document.getElementById('file-input').value = '';
+    document.getElementById('folder-input').value = '';
 }
 
 /**
  * Close send modal
  */
 function closeSendModal() {
+    clearPendingTransfer();
     document.getElementById('send-modal').classList.add('hidden');
     selectedPeer = null;
+    selectedGroup = null;
+    groupSend = null;
     selectedFiles = [];
 }
 
@@ -274,13 +500,52 @@ function setupDropZone() {
         dropZone.classList.remove('dragover');
     });
 
-    dropZone.addEventListener('drop', (e) => {
+    dropZone.addEventListener('drop', async (e) => {
         e.preventDefault();
         dropZone.classList.remove('dragover');
-        handleFiles(e.dataTransfer.files);
+
+        // Walk dropped folders when the browser exposes entries
+        const entries = Array.from(e.dataTransfer.items || [])
+            .map(item => item.webkitGetAsEntry?.())
+            .filter(Boolean);
+
+        if (entries.some(entry => entry.isDirectory)) {
+            const files = [];
+            for (const entry of entries) {
+                await collectEntryFiles(entry, '', files);
+            }
+            handleFiles(files);
+        } else {
+            handleFiles(e.dataTransfer.files);
+        }
     });
 }
 
+/**
+ * Recursively collect files from a dropped file system entry,
+ * recording each file's path relative to the dropped folder
+ */
+async function collectEntryFiles(entry, prefix, files) {
+    if (entry.isFile) {
+        const file = await new Promise((resolve, reject) => entry.file(resolve, reject));
+        setRelativePath(file, prefix + entry.name);
+        files.push(file);
+        return;
+    }
+
+    if (entry.isDirectory) {
+        const reader = entry.createReader();
+        // readEntries returns results in batches until empty
+        let batch;
+        do {
+            batch = await new Promise((resolve, reject) => reader.readEntries(resolve, reject));
+            for (const child of batch) {
+                await collectEntryFiles(child, prefix + entry.name + '/', files);
+            }
+        } while (batch.length > 0);
+    }
+}
+
 /**
  * Set up file input
  */
@@ -291,6 +556,10 @@ function setupFileInput() {
     input.addEventListener('change', (e) => {
         handleFiles(e.target.files);
     });
+
+    document.getElementById('folder-input')?.addEventListener('change', (e) => {
+        handleFiles(e.target.files);
+    });
 }
 
 /**
@@ -319,7 +588,7 @@ function updateSelectedFiles() {
     container.classList.remove('hidden');
     fileCount.textContent = selectedFiles.length;
     fileList.innerHTML = selectedFiles.map(f =>
-        `<li>${escapeHtml(f.name)} (${formatSize(f.size)})</li>`
+        `<li>${escapeHtml(getRelativePath(f) || f.name)} (${formatSize(f.size)})</li>`
     ).join('');
     sendBtn.disabled = false;
 }
@@ -328,6 +597,10 @@ function updateSelectedFiles() {
  * Send files to selected peer
  */
 async function sendFiles() {
+    if (selectedGroup) {
+        await sendFilesToGroup();
+        return;
+    }
     if (!selectedPeer || selectedFiles.length === 0) return;
 
     document.getElementById('send-progress').classList.remove('hidden');
@@ -336,6 +609,207 @@ async function sendFiles() {
     await fileTransferManager.sendFiles(selectedPeer.id, selectedFiles);
 }
 
+/**
+ * Load peer groups from the server
+ */
+async function loadGroups() {
+    try {
+        const res = await fetch('/api/groups');
+        groups = (await res.json()).groups || [];
+    } catch (err) {
+        console.error('[App] Failed to load groups:', err);
+        groups = [];
+    }
+    updateGroupList();
+}
+
+/**
+ * Online peers whose device name is in a group
+ */
+function onlineGroupMembers(group) {
+    return Array.from(peers.values()).filter(peer => group.members.includes(peer.name));
+}
+
+/**
+ * Update group list UI
+ */
+function updateGroupList() {
+    const container = document.getElementById('groups-list');
+    if (!container) return;
+
+    if (groups.length === 0) {
+        container.innerHTML = '<p class="empty-state">No groups yet</p>';
+        return;
+    }
+
+    container.innerHTML = groups.map((group, i) => {
+        const matched = onlineGroupMembers(group);
+        const online = matched.length;
+        const names = matched.map(peer => peer.name).join(', ');
+        return `
+        <div class="peer-card">
+            <div class="peer-info">
+                <div class="peer-icon">👥</div>
+                <div class="peer-details">
+                    <h3>${escapeHtml(group.name)}</h3>
+                    <p>${online} of ${group.members.length} online${online ? ': ' + escapeHtml(names) : ''}</p>
+                </div>
+            </div>
+            <div class="transfer-actions">
+                <button class="btn btn-primary" onclick="openGroupSendModal(${i})" ${online === 0 ? 'disabled' : ''}>Send</button>
+                <button class="btn btn-danger btn-small" onclick="deleteGroup(${i})">Delete</button>
+            </div>
+        </div>
+    `;
+    }).join('');
+}
+
+/**
+ * Open the group editor with the current peers as candidates
+ */
+function openGroupModal() {
+    const names = [...new Set(Array.from(peers.values()).map(p => p.name))];
+    const container = document.getElementById('group-members');
+
+    container.innerHTML = names.length === 0
+        ? '<p class="text-muted">No devices online to add</p>'
+        : names.map(name => `
+            <label><input type="checkbox" value="${escapeHtml(name)}"> ${escapeHtml(name)}</label>
+        `).join('');
+
+    document.getElementById('group-name-input').value = '';
+    document.getElementById('group-modal').classList.remove('hidden');
+    document.getElementById('group-name-input').focus();
+}
+
+function closeGroupModal() {
+    document.getElementById('group-modal').classList.add('hidden');
+}
+
+/**
+ * Save the group being edited
+ */
+async function saveGroup() {
+    const name = document.getElementById('group-name-input').value.trim();
+    const members = Array.from(document.querySelectorAll('#group-members input:checked')).map(el => el.value);
+
+    if (!name || members.length === 0) {
+        showNotification('Enter a name and pick at least one device', 'error');
+        return;
+    }
+
+    try {
+        const res = await fetch(`/api/groups/${encodeURIComponent(name)}`, {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ members })
+        });
+        if (!res.ok) throw new Error(await res.text());
+    } catch (err) {
+        showNotification(`Failed to save group: ${err.message}`, 'error');
+        return;
+    }
+
+    closeGroupModal();
+    await loadGroups();
+}
+
+/**
+ * Delete a group
+ */
+async function deleteGroup(index) {
+    const group = groups[index];
+    if (!group || !confirm(`Delete group "${group.name}"?`)) return;
+
+    try {
+        const res = await fetch(`/api/groups/${encodeURIComponent(group.name)}`, { method: 'DELETE' });
+        if (!res.ok) throw new Error(await res.text());
+    } catch (err) {
+        showNotification(`Failed to delete group: ${err.message}`, 'error');
+    }
+    await loadGroups();
+}
+
+/**
+ * Open send modal for a group
+ */
+function openGroupSendModal(index) {
+    const group = groups[index];
+    if (!group) return;
+
+    selectedPeer = null;
+    selectedGroup = group;
+    showSendModal(group.name);
+}
+
+/**
+ * Send the selected files to every online member of the selected group
+ */
+async function sendFilesToGroup() {
+    const members = onlineGroupMembers(selectedGroup);
+    if (members.length === 0 || selectedFiles.length === 0) return;
+
+    // Members are matched by device name, which every device picks for
+    // itself, so show who is about to receive the files
+    const counts = new Map();
+    members.forEach(peer => counts.set(peer.name, (counts.get(peer.name) || 0) + 1));
+    const list = members.map(peer => {
+        const duplicate = counts.get(peer.name) > 1 ? ' - more than one device has this name' : '';
+        return `  ${peer.name} (${peer.platform || 'unknown'})${duplicate}`;
+    }).join('\n');
+    if (!confirm(`Send to these devices in "${selectedGroup.name}"?\n\n${list}\n\nDevices are matched by name, which any device can choose.`)) {
+        return;
+    }
+
+    document.getElementById('send-progress').classList.remove('hidden');
+    document.getElementById('send-btn').disabled = true;
+
+    groupSend = { transfers: new Map() };
+    updateSendProgress(0, `Waiting for ${members.length} device(s) to accept...`);
+
+    for (const peer of members) {
+        const transferId = await fileTransferManager.sendFiles(peer.id, selectedFiles);
+        groupSend.transfers.set(transferId, { name: peer.name, status: 'pending', progress: 0 });
+    }
+}
+
+function isGroupTransfer(transferId) {
+    return groupSend !== null && groupSend.transfers.has(transferId);
+}
+
+/**
+ * Record a status change for one transfer of a group send and refresh the
+ * aggregate progress. Returns false if the transfer isn't part of one.
+ */
+function updateGroupSend(transferId, status, progress) {
+    if (!isGroupTransfer(transferId)) return false;
+
+    const entry = groupSend.transfers.get(transferId);
+    entry.status = status;
+    if (progress !== undefined) entry.progress = progress;
+
+    const entries = Array.from(groupSend.transfers.values());
+    const count = (s) => entries.filter(e => e.status === s).length;
+    const completed = count('completed');
+    const rejected = count('rejected');
+    const active = entries.filter(e => e.status !== 'rejected');
+    const overall = active.length ? active.reduce((sum, e) => sum + e.progress, 0) / active.length : 0;
+
+    if (completed + rejected === entries.length) {
+        const summary = `Sent to ${completed} of ${entries.length} device(s)` +
+            (rejected ? ` (${rejected} declined)` : '');
+        updateSendProgress(100, summary);
+        showNotification(summary, completed > 0 ? 'success' : 'error');
+        groupSend = null;
+        setTimeout(closeSendModal, 2000);
+    } else {
+        updateSendProgress(overall * 100,
+            `Sending to ${entries.length} device(s)... ${Math.round(overall * 100)}% ` +
+            `(${completed} done, ${count('pending')} waiting)`);
+    }
+    return true;
+}
+
 /**
  * Update send progress UI
  */
@@ -344,26 +818,107 @@ function updateSendProgress(percent, text) {
     document.getElementById('progress-text').textContent = text;
 }
 
+/**
+ * Show whether a relayed transfer is end-to-end encrypted. Both devices
+ * should show the same key; if they don't, the server swapped the keys
+ * and can read the files.
+ */
+function showRelaySecurity(el, encrypted, fingerprint) {
+    if (!el) return;
+    el.textContent = encrypted
+        ? `Relayed, end-to-end encrypted. Key ${fingerprint}: check the other device shows the same.`
+        : 'Relayed without encryption: the server can read these files.';
+    el.classList.toggle('insecure', !encrypted);
+    el.classList.remove('hidden');
+}
+
+/**
+ * Hide the nudge button once the transfer is answered or abandoned
+ */
+function clearPendingTransfer() {
+    pendingTransferId = null;
+    document.getElementById('nudge-btn').classList.add('hidden');
+}
+
+/**
+ * Poke the selected peer about the transfer waiting for acceptance
+ */
+function nudgePeer() {
+    if (!selectedPeer) return;
+    wsManager.sendNudge(selectedPeer.id, pendingTransferId);
+    showNotification(`Nudged ${selectedPeer.name}`);
+}
+
+/**
+ * Alert the user that a peer is waiting on them
+ */
+function handleNudge(peerId, payload) {
+    const peer = peers.get(peerId) || { name: 'Someone' };
+    const message = `${peer.name} is waiting for you to accept a transfer`;
+
+    showNotification(message);
+    showDesktopNotification('Peer-Drop', message);
+
+    // Highlight the transfer they are nudging about, or all pending ones
+    const cards = payload.transferId
+        ? [document.getElementById(`transfer-${payload.transferId}`)]
+        : document.querySelectorAll('.transfer-card');
+    cards.forEach(card => {
+        if (!card) return;
+        card.classList.remove('nudged');
+        void card.offsetWidth; // restart the animation
+        card.classList.add('nudged');
+        card.scrollIntoView({ behavior: 'smooth', block: 'center' });
+    });
+}
+
+/**
+ * Ask once for permission to show desktop notifications
+ */
+function requestNotificationPermission() {
+    if ('Notification' in window && Notification.permission === 'default') {
+        Notification.requestPermission();
+    }
+}
+
+/**
+ * Show a desktop notification when the page is in the background
+ */
+function showDesktopNotification(title, body) {
+    if (!('Notification' in window) || Notification.permission !== 'granted') return;
+    if (document.visibilityState === 'visible') return;
+
+    const notification = new Notification(title, { body, tag: 'peerdrop-nudge' });
+    notification.onclick = () => {
+        window.focus();
+        notification.close();
+    };
+}
+
 /**
  * Show incoming transfer
  */
-function showIncomingTransfer(transferId, senderName, files, totalSize) {
+function showIncomingTransfer(transferId, senderName, files, totalSize, summary, previews = [], canPause = true) {
     const container = document.getElementById('transfers-list');
     const emptyState = container.querySelector('.empty-state');
     if (emptyState) {
         emptyState.remove();
     }
 
-    const fileNames = files.map(f => f.name).join(', ');
+    const fileNames = files.map(f => f.path || f.name).join(', ');
+    const id = jsArg(transferId);
 
     const html = `
-        <div class="transfer-card" id="transfer-${transferId}">
+        <div class="transfer-card" id="transfer-${escapeHtml(transferId)}">
             <div class="transfer-info">
                 <div class="peer-icon">📥</div>
                 <div class="transfer-details">
                     <h3>From ${escapeHtml(senderName)}</h3>
                     <p>${files.length} file(s) - ${formatSize(totalSize)}</p>
+                    ${summary ? renderTransferSummary(summary) : ''}
+                    ${renderPreviews(files, previews)}
                     <p class="file-names">${escapeHtml(fileNames)}</p>
+                    <p class="relay-security hidden"></p>
                     <div class="transfer-progress hidden">
                         <div class="progress-bar">
                             <div class="progress-fill" style="width: 0%"></div>
@@ -372,8 +927,11 @@ function showIncomingTransfer(transferId, senderName, files, totalSize) {
                 </div>
             </div>
             <div class="transfer-actions">
-                <button class="btn btn-success" onclick="acceptTransfer('${transferId}')">Accept</button>
-                <button class="btn btn-danger" onclick="rejectTransfer('${transferId}')">Reject</button>
+                <button class="btn btn-success" onclick="acceptTransfer(${id})">Accept</button>
+                <button class="btn btn-danger" onclick="rejectTransfer(${id})">Reject</button>
+            </div>
+            <div class="transfer-controls hidden">
+                ${canPause ? `<button class="btn btn-secondary btn-small pause-btn" onclick="togglePauseTransfer(${id})">Pause</button>` : ''}
             </div>
         </div>
     `;
@@ -381,16 +939,63 @@ function showIncomingTransfer(transferId, senderName, files, totalSize) {
     container.insertAdjacentHTML('beforeend', html);
 }
 
+/**
+ * Thumbnails the sender attached for offered images
+ */
+function renderPreviews(files, previews) {
+    // The hub checks previews; this guards the data: URL all the same
+    const valid = previews.filter(p =>
+        /^image\/(jpeg|png|webp)$/.test(p.type) && /^[A-Za-z0-9+/=]+$/.test(p.data) && files[p.fileIndex]);
+    if (valid.length === 0) return '';
+
+    const images = valid.map(p => `<img src="data:${p.type};base64,${p.data}" alt="Preview">`).join('');
+    return `<div class="transfer-previews">${images}</div>`;
+}
+
+/**
+ * Largest file and per-type breakdown of a multi-file request
+ */
+function renderTransferSummary(summary) {
+    const types = summary.types
+        .map(t => `${t.count} ${escapeHtml(t.category)} (${formatSize(t.size)})`)
+        .join(', ');
+
+    return `
+        <p class="transfer-summary">Largest: ${escapeHtml(summary.largestName)} (${formatSize(summary.largestSize)})</p>
+        <p class="transfer-summary">${types}</p>
+    `;
+}
+
 /**
  * Accept incoming transfer
  */
-function acceptTransfer(transferId) {
-    fileTransferManager.acceptTransfer(transferId);
+async function acceptTransfer(transferId) {
+    // Must run inside the click handler so the folder picker may open
+    await fileTransferManager.acceptTransfer(transferId);
 
     const card = document.getElementById(`transfer-${transferId}`);
     if (card) {
         card.querySelector('.transfer-actions').classList.add('hidden');
         card.querySelector('.transfer-progress').classList.remove('hidden');
+        card.querySelector('.transfer-controls').classList.remove('hidden');
+    }
+}
+
+/**
+ * Pause or resume an incoming transfer
+ */
+function togglePauseTransfer(transferId) {
+    const card = document.getElementById(`transfer-${transferId}`);
+    if (!card) return;
+
+    const button = card.querySelector('.pause-btn');
+    const paused = card.classList.toggle('paused');
+    if (paused) {
+        fileTransferManager.pauseTransfer(transferId);
+        button.textContent = 'Resume';
+    } else {
+        fileTransferManager.resumeTransfer(transferId);
+        button.textContent = 'Pause';
     }
 }
 
@@ -431,12 +1036,13 @@ function removeIncomingTransfer(transferId) {
 }
 
 /**
- * Show room code
+ * Show room code and word alias
  */
-function showRoomCode(code) {
+function showRoomCode(code, alias) {
     const modal = document.getElementById('room-code-modal');
     if (modal) {
         document.getElementById('room-code-display').textContent = code;
+        document.getElementById('room-alias-display').textContent = alias || '';
         modal.classList.remove('hidden');
     } else {
         showNotification(`Room code: ${code}`);
@@ -468,8 +1074,10 @@ function closeRoomModal() {
  */
 function submitRoomCode() {
     const input = document.getElementById('room-code-input');
-    const code = input.value.trim().toUpperCase();
-    if (code.length === 5) {
+    const code = input.value.trim();
+    // Either a room code (4-16 characters, length set by the server) or
+    // an alias like "swift-amber-otter-417"
+    if (code.length >= 4 || code.includes('-')) {
         wsManager.joinRoom(code);
     }
 }
@@ -489,6 +1097,211 @@ function editDeviceName() {
     }
 }
 
+/**
+ * Create a public room, asking for an optional memorable name
+ */
+function createRoom() {
+    const alias = prompt('Room name, e.g. marketing-standup (leave empty for a random one):', '');
+    if (alias === null) return;
+
+    // Named rooms are usually meant to be reused, so offer to keep them
+    // where the server allows it
+    const name = alias.trim().toLowerCase();
+    const persistent = name !== '' &&
+        wsManager.serverCapabilities.includes(CAPABILITY_PERSISTENT_ROOMS) &&
+        confirm(`Keep "${name}" open after everyone leaves?`);
+    wsManager.createRoom(name, persistent);
+}
+
+/**
+ * Send the chat input to the room and show it in our own list
+ */
+function sendChatMessage() {
+    const input = document.getElementById('chat-input');
+    const text = input.value.trim();
+    if (!text) return;
+
+    // The server drops longer messages
+    if (new TextEncoder().encode(text).length > CHAT_MAX_BYTES) {
+        showNotification('Message is too long, send it as a file instead', 'error');
+        return;
+    }
+
+    wsManager.sendChat(text);
+    appendChatMessage({ peerId: deviceId, name: deviceName, text, time: Date.now() }, true);
+    input.value = '';
+}
+
+/**
+ * Add a chat message to the list, skipping ones already shown
+ */
+function appendChatMessage(message, own = false) {
+    const list = document.getElementById('chat-list');
+    if (!list) return;
+
+    const key = `${message.peerId}|${message.time}|${message.text}`;
+    if (chatSeen.has(key)) return;
+    chatSeen.add(key);
+
+    list.querySelector('.empty-state')?.remove();
+
+    const time = new Date(message.time || Date.now()).toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+    const item = document.createElement('div');
+    item.className = `chat-message${own ? ' own' : ''}`;
+    item.innerHTML = `
+        <div class="chat-meta">
+            <span class="chat-name">${escapeHtml(own ? 'You' : message.name || 'Unknown')}</span>
+            ${message.direct ? '<span class="chat-direct">private</span>' : ''}
+            <span class="chat-time">${time}</span>
+        </div>
+        <div class="chat-text">${linkify(message.text)}</div>
+    `;
+    list.appendChild(item);
+    list.scrollTop = list.scrollHeight;
+}
+
+/**
+ * Escape text and turn http(s) URLs in it into links
+ */
+function linkify(text) {
+    // Entities other than &amp; end a URL, so an escaped quote can't
+    // carry it out of the href
+    return escapeHtml(text).replace(/https?:\/\/(?:[^\s<&]|&amp;)+/g, (url) =>
+        `<a href="${url}" target="_blank" rel="noopener noreferrer">${url}</a>`);
+}
+
+/**
+ * Post the board note and picked file to the room's board
+ */
+async function postToBoard() {
+    const input = document.getElementById('board-input');
+    const text = input.value.trim();
+    if (!text && !boardFile) return;
+
+    let file = null;
+    if (boardFile) {
+        if (boardFile.size > BOARD_MAX_FILE_BYTES) {
+            showNotification(`Board files are limited to ${formatSize(BOARD_MAX_FILE_BYTES)}`, 'error');
+            return;
+        }
+        const buffer = await boardFile.arrayBuffer();
+        file = { name: boardFile.name, type: boardFile.type, data: fileTransferManager.arrayBufferToBase64(buffer) };
+    }
+
+    wsManager.postToBoard(text, file);
+    input.value = '';
+    boardFile = null;
+    document.getElementById('board-file-input').value = '';
+    document.getElementById('board-file-name').textContent = '';
+}
+
+/**
+ * Render the board, dropping posts that have expired
+ */
+function updateBoard() {
+    const list = document.getElementById('board-list');
+    if (!list) return;
+
+    const now = Date.now();
+    for (const [id, post] of boardPosts) {
+        if (post.expires && post.expires <= now) boardPosts.delete(id);
+    }
+
+    if (boardPosts.size === 0) {
+        list.innerHTML = '<p class="empty-state">Pin notes or small files for everyone in the room</p>';
+        return;
+    }
+
+    list.innerHTML = Array.from(boardPosts.values()).map(post => {
+        const time = new Date(post.time).toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+        const id = jsArg(post.id);
+        return `
+            <div class="chat-message board-post">
+                <div class="chat-meta">
+                    <span class="chat-name">${escapeHtml(post.own ? 'You' : post.name || 'Unknown')}</span>
+                    <span class="chat-time">${time}</span>
+                    ${post.own ? `<button class="board-delete" onclick="wsManager.deleteBoardPost(${id})" title="Remove">&times;</button>` : ''}
+                </div>
+                ${post.text ? `<div class="chat-text">${linkify(post.text)}</div>` : ''}
+                ${post.file ? `<a href="#" class="board-file" onclick="downloadBoardFile(${id}); return false;">📎 ${escapeHtml(post.file.name)}</a>` : ''}
+            </div>
+        `;
+    }).join('');
+}
+
+/**
+ * Save the file attached to a board post
+ */
+function downloadBoardFile(id) {
+    const file = boardPosts.get(id)?.file;
+    if (!file) return;
+
+    // Always a generic type, so a posted HTML file can't run as our page
+    const blob = new Blob([fileTransferManager.base64ToArrayBuffer(file.data)], { type: 'application/octet-stream' });
+    const url = URL.createObjectURL(blob);
+    const a = document.createElement('a');
+    a.href = url;
+    a.download = file.name;
+    a.click();
+    setTimeout(() => URL.revokeObjectURL(url), 1000);
+}
+
+/**
+ * Send our clipboard text to a peer, or to everyone in the room
+ */
+async function shareClipboard(peerId = null) {
+    let text;
+    try {
+        text = await navigator.clipboard.readText();
+    } catch (err) {
+        // Reading needs HTTPS or localhost, and the user's permission
+        console.warn('[App] Clipboard read failed:', err);
+        showNotification('Could not read your clipboard', 'error');
+        return;
+    }
+
+    if (!text) {
+        showNotification('Your clipboard has no text', 'error');
+        return;
+    }
+    if (new TextEncoder().encode(text).length > CLIPBOARD_MAX_BYTES) {
+        showNotification('Clipboard text is too large, send it as a file instead', 'error');
+        return;
+    }
+
+    wsManager.sendClipboard(text, peerId);
+    const target = peerId ? peers.get(peerId)?.name || 'peer' : 'everyone here';
+    showNotification(`Clipboard sent to ${target}`, 'success');
+}
+
+/**
+ * Apply clipboard text from a peer according to the receive setting
+ */
+async function handleIncomingClipboard({ text, name }) {
+    if (!text) return;
+    const sender = name || 'Someone';
+
+    if (clipboardMode === 'off') {
+        showNotification(`${sender} shared clipboard text. Turn on receiving clipboard to get it.`);
+        return;
+    }
+
+    if (clipboardMode === 'ask') {
+        const preview = text.length > 200 ? text.slice(0, 200) + '…' : text;
+        if (!confirm(`${sender} shared clipboard text:\n\n${preview}\n\nCopy it to your clipboard?`)) return;
+    }
+
+    try {
+        await navigator.clipboard.writeText(text);
+        showNotification(`Copied clipboard text from ${sender}`, 'success');
+    } catch (err) {
+        // Writing fails in an unfocused tab; keep the text reachable in chat
+        console.warn('[App] Clipboard write failed:', err);
+        appendChatMessage({ peerId: '', name: `${sender} (clipboard)`, text, time: Date.now() });
+        showNotification(`Could not copy ${sender}'s clipboard text, it is shown in the chat`, 'error');
+    }
+}
+
 /**
  * Update connection status UI
  */
@@ -571,8 +1384,56 @@ function formatSize(bytes) {
     return parseFloat((bytes / Math.pow(k, i)).toFixed(1)) + ' ' + sizes[i];
 }
 
-function escapeHtml(text) {
-    const div = document.createElement('div');
-    div.textContent = text;
-    return div.innerHTML;
+/**
+ * Broad category of a MIME type, used in transfer summaries
+ */
+function fileCategory(type) {
+    if (!type) return 'other';
+    const [major, minor = ''] = type.split('/');
+    if (['image', 'video', 'audio'].includes(major)) return major;
+    if (major === 'text' || /pdf|document|msword|spreadsheet|presentation|rtf/.test(minor)) return 'document';
+    if (/zip|tar|rar|7z|gzip|bzip|xz|compressed/.test(minor)) return 'archive';
+    return 'other';
+}
+
+/**
+ * Aggregate file count, total size, largest file and a per-category
+ * breakdown for a transfer request. fileInfos are { name, size, type, path }.
+ */
+function summarizeFiles(fileInfos) {
+    const byCategory = new Map();
+    let largest = { name: '', size: 0 };
+    let totalSize = 0;
+
+    for (const f of fileInfos) {
+        totalSize += f.size;
+        if (f.size > largest.size || !largest.name) {
+            largest = { name: f.path || f.name, size: f.size };
+        }
+
+        const category = fileCategory(f.type);
+        const entry = byCategory.get(category) || { category, count: 0, size: 0 };
+        entry.count++;
+        entry.size += f.size;
+        byCategory.set(category, entry);
+    }
+
+    return {
+        fileCount: fileInfos.length,
+        totalSize,
+        largestName: largest.name,
+        largestSize: largest.size,
+        types: Array.from(byCategory.values()).sort((a, b) => b.size - a.size)
+    };
+}
+
+/**
+ * Relative path of a file inside a selected or dropped folder, if any
+ */
+function getRelativePath(file) {
+    return file.relativePath || file.webkitRelativePath || '';
+}
+
+function setRelativePath(file, path) {
+    Object.defineProperty(file, 'relativePath', { value: path });
 }
@@ -0,0 +1,27 @@
+/**
+ * Escaping for strings that peers control, shared by the UI scripts
+ */
+
+// Bidirectional overrides and isolates, which let a name like
+// "invoice\u202Efdp.exe" display as "invoiceexe.pdf"
+const BIDI_CONTROLS = /[\u202A-\u202E\u2066-\u2069]/g;
+
+const HTML_ESCAPES = { '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' };
+
+/**
+ * Escape text for HTML content and quoted attributes alike. Peers control
+ * names, file names and IDs, so everything they send goes through here
+ * (or jsArg) before reaching innerHTML.
+ */
+function escapeHtml(text) {
+    return String(text ?? '')
+        .replace(BIDI_CONTROLS, '\uFFFD')
+        .replace(/[&<>"']/g, c => HTML_ESCAPES[c]);
+}
+
+/**
+ * Quote a value as a string argument inside an inline event handler
+ */
+function jsArg(value) {
+    return escapeHtml(JSON.stringify(String(value ?? '')));
+}
@@ -17,6 +17,25 @@ class FileTransferManager extends EventTarget {
         this.CHUNK_SIZE = 64 * 1024; // 64KB chunks
         this.MAX_BUFFER = 16 * 1024 * 1024; // 16MB buffer threshold
 
+        // Relay integrity
+        this.MAX_NACK_ROUNDS = 3; // re-requests before failing a relayed transfer
+        this.NACK_TIMEOUT = 5000; // wait for retransmits before asking again
+        this.RELAY_RETAIN_MS = 60000; // sender keeps finished relay transfers for nacks
+
+        // Relay flow control
+        this.RELAY_WINDOW = 32; // unacknowledged relay chunks a sender may have in flight
+        this.RELAY_ACK_EVERY = 8; // receiver acks after this many new chunks
+        this.RELAY_ACK_TIMEOUT = 10000; // wait for an ack before sending anyway
+
+        // Relay encryption
+        this.RELAY_KEY_TIMEOUT = 5000; // wait for the receiver's key before relaying in the clear
+
+        // Image previews in transfer requests (the hub drops larger ones)
+        this.PREVIEW_MAX_COUNT = 8;
+        this.PREVIEW_MAX_BYTES = 16 * 1024;
+        this.PREVIEW_DIMENSION = 160; // longest side in pixels
+        this.PREVIEW_SOURCE_MAX = 50 * 1024 * 1024; // skip decoding larger images
+
         // Message types for binary protocol
         this.MSG_METADATA = 0x01;
         this.MSG_CHUNK = 0x02;
@@ -45,6 +64,29 @@ class FileTransferManager extends EventTarget {
             this.handleRelayChunk(e.detail.peerId, e.detail.payload);
         });
 
+        // Handle re-requests for missing or corrupted relay chunks
+        this.wsManager.addEventListener('relay-nack', (e) => {
+            this.handleRelayNack(e.detail.peerId, e.detail.payload);
+        });
+
+        // Handle the receiver pausing or resuming one of our transfers
+        this.wsManager.addEventListener('transfer-pause', (e) => {
+            this.handleTransferPause(e.detail.peerId, e.detail.payload, true);
+        });
+        this.wsManager.addEventListener('transfer-resume', (e) => {
+            this.handleTransferPause(e.detail.peerId, e.detail.payload, false);
+        });
+
+        // Handle end-to-end key exchange for relayed transfers
+        this.wsManager.addEventListener('relay-key', (e) => {
+            this.handleRelayKey(e.detail.peerId, e.detail.payload);
+        });
+
+        // Handle receiver progress for relay flow control
+        this.wsManager.addEventListener('relay-ack', (e) => {
+            this.handleRelayAck(e.detail.peerId, e.detail.payload);
+        });
+
         // Handle DataChannel messages
         this.webrtcManager.addEventListener('datachannel-message', (e) => {
             this.handleDataChannelMessage(e.detail.peerId, e.detail.data);
@@ -80,7 +122,8 @@ class FileTransferManager extends EventTarget {
         this.outgoingTransfers.set(transferId, transfer);
 
         // Send transfer request via WebSocket
-        this.wsManager.sendTransferRequest(peerId, transferId, transfer.files);
+        const previews = await this.createPreviews(transfer.files);
+        this.wsManager.sendTransferRequest(peerId, transferId, transfer.files, previews);
 
         this.dispatchEvent(new CustomEvent('send-pending', {
             detail: { transferId, peerId, files: transfer.files }
@@ -99,7 +142,7 @@ class FileTransferManager extends EventTarget {
         if (!payload.accepted) {
             transfer.status = 'rejected';
             this.dispatchEvent(new CustomEvent('send-rejected', {
-                detail: { transferId: payload.transferId }
+                detail: { transferId: payload.transferId, reason: payload.reason }
             }));
             this.outgoingTransfers.delete(payload.transferId);
             return;
@@ -124,6 +167,8 @@ class FileTransferManager extends EventTarget {
         } catch (err) {
             console.log('[Transfer] WebRTC failed, using relay fallback:', err.message);
             transfer.useRelay = true;
+            await this.setupRelayEncryption(transfer);
+            this.reportRelaySecurity(transfer, 'send');
             await this.sendViaRelay(transfer);
         }
     }
@@ -188,6 +233,7 @@ class FileTransferManager extends EventTarget {
                 transferId: transfer.id,
                 fileIndex,
                 name: file.name,
+                path: getRelativePath(file) || undefined,
                 size: file.size,
                 type: file.type,
                 totalChunks: Math.ceil(file.size / this.CHUNK_SIZE)
@@ -199,6 +245,8 @@ class FileTransferManager extends EventTarget {
             let chunkIndex = 0;
 
             while (offset < file.size) {
+                await this.waitWhilePaused(transfer);
+
                 // Backpressure handling
                 while (channel.bufferedAmount > this.MAX_BUFFER) {
                     await this.sleep(50);
@@ -242,35 +290,28 @@ class FileTransferManager extends EventTarget {
      */
     async sendViaRelay(transfer) {
         transfer.status = 'transferring';
+        transfer.relaySent = 0;
+        transfer.relayAcked = 0;
+        transfer.relayWindow = true; // dropped if the receiver never acks
+
+        // isLast marks the final chunk of the last non-empty file
+        let lastFileIndex = -1;
+        transfer.files.forEach((f, i) => { if (f.size > 0) lastFileIndex = i; });
 
         for (let fileIndex = 0; fileIndex < transfer.files.length; fileIndex++) {
             const file = transfer.files[fileIndex];
             transfer.currentFileIndex = fileIndex;
 
-            let offset = 0;
-            let chunkIndex = 0;
             const totalChunks = Math.ceil(file.size / this.CHUNK_SIZE);
 
-            while (offset < file.size) {
-                const chunk = file.slice(offset, offset + this.CHUNK_SIZE);
-                const arrayBuffer = await chunk.arrayBuffer();
-                const base64 = this.arrayBufferToBase64(arrayBuffer);
-
-                const isLast = (fileIndex === transfer.files.length - 1) &&
-                    (offset + this.CHUNK_SIZE >= file.size);
+            for (let chunkIndex = 0; chunkIndex < totalChunks; chunkIndex++) {
+                await this.waitWhilePaused(transfer);
+                await this.waitForRelayWindow(transfer);
 
-                this.wsManager.sendRelayChunk(
-                    transfer.peerId,
-                    transfer.id,
-                    fileIndex,
-                    chunkIndex,
-                    base64,
-                    isLast
-                );
-
-                offset += this.CHUNK_SIZE;
-                chunkIndex++;
-                transfer.bytesSent += arrayBuffer.byteLength;
+                const isLast = fileIndex === lastFileIndex && chunkIndex === totalChunks - 1;
+                const size = await this.sendRelayChunkAt(transfer, fileIndex, chunkIndex, isLast);
+                transfer.bytesSent += size;
+                transfer.relaySent++;
 
                 // Emit progress
                 this.dispatchEvent(new CustomEvent('send-progress', {
@@ -281,9 +322,6 @@ class FileTransferManager extends EventTarget {
                         totalBytes: transfer.totalBytes
                     }
                 }));
-
-                // Rate limit relay to avoid overwhelming WebSocket
-                await this.sleep(10);
             }
         }
 
@@ -292,7 +330,256 @@ class FileTransferManager extends EventTarget {
             detail: { transferId: transfer.id }
         }));
 
-        this.outgoingTransfers.delete(transfer.id);
+        // Keep the files around for a while so the receiver can re-request gaps
+        setTimeout(() => this.outgoingTransfers.delete(transfer.id), this.RELAY_RETAIN_MS);
+    }
+
+    /**
+     * Pause or resume an outgoing transfer at the receiver's request
+     */
+    handleTransferPause(peerId, payload, paused) {
+        const transfer = this.outgoingTransfers.get(payload.transferId);
+        if (!transfer || transfer.peerId !== peerId) return;
+
+        transfer.paused = paused;
+        if (!paused && transfer.resumeWaiter) transfer.resumeWaiter();
+
+        this.dispatchEvent(new CustomEvent(paused ? 'send-paused' : 'send-resumed', {
+            detail: { transferId: transfer.id }
+        }));
+    }
+
+    /**
+     * Block the send loop while the receiver has the transfer paused.
+     * The loop keeps its position, so it continues from the same offset.
+     */
+    waitWhilePaused(transfer) {
+        if (!transfer.paused) return Promise.resolve();
+        return new Promise(resolve => {
+            transfer.resumeWaiter = () => {
+                transfer.resumeWaiter = null;
+                resolve();
+            };
+        });
+    }
+
+    /**
+     * Ask the sender to stop sending an incoming transfer for now
+     */
+    pauseTransfer(transferId) {
+        this.setIncomingPaused(transferId, true);
+    }
+
+    /**
+     * Ask the sender to continue a paused incoming transfer
+     */
+    resumeTransfer(transferId) {
+        this.setIncomingPaused(transferId, false);
+    }
+
+    setIncomingPaused(transferId, paused) {
+        const transfer = this.incomingTransfers.get(transferId);
+        if (!transfer || transfer.paused === paused) return;
+
+        transfer.paused = paused;
+        if (paused) {
+            this.wsManager.sendTransferPause(transfer.peerId, transferId);
+        } else {
+            this.wsManager.sendTransferResume(transfer.peerId, transferId);
+        }
+    }
+
+    /**
+     * Block until the receiver has acknowledged enough chunks to keep at
+     * most RELAY_WINDOW in flight. Receivers that never ack (older pages)
+     * get the old fixed pacing instead.
+     */
+    async waitForRelayWindow(transfer) {
+        if (!transfer.relayWindow) {
+            // Rate limit relay to avoid overwhelming WebSocket
+            await this.sleep(10);
+            return;
+        }
+
+        while (transfer.relaySent - transfer.relayAcked >= this.RELAY_WINDOW) {
+            const acked = await this.waitForRelayAck(transfer);
+            if (acked) continue;
+
+            if (transfer.relayAcked === 0) {
+                console.log(`[Transfer] No relay acks for ${transfer.id}, falling back to fixed pacing`);
+                transfer.relayWindow = false;
+            } else {
+                console.warn(`[Transfer] Relay acks stalled for ${transfer.id}, sending anyway`);
+            }
+            return;
+        }
+    }
+
+    /**
+     * Resolve true on the next ack for transfer, or false after a timeout
+     */
+    waitForRelayAck(transfer) {
+        return new Promise(resolve => {
+            const timer = setTimeout(() => {
+                transfer.ackWaiter = null;
+                resolve(false);
+            }, this.RELAY_ACK_TIMEOUT);
+
+            transfer.ackWaiter = () => {
+                clearTimeout(timer);
+                transfer.ackWaiter = null;
+                resolve(true);
+            };
+        });
+    }
+
+    /**
+     * Record how far the receiver has got and reopen the send window
+     */
+    handleRelayAck(peerId, payload) {
+        const transfer = this.outgoingTransfers.get(payload.transferId);
+        if (!transfer || transfer.peerId !== peerId || !transfer.useRelay) return;
+
+        transfer.relayAcked = Math.max(transfer.relayAcked || 0, payload.received);
+        if (transfer.ackWaiter) transfer.ackWaiter();
+    }
+
+    /**
+     * Agree on an end-to-end key with the receiver before relaying. Without
+     * a key from both sides the transfer is relayed in the clear.
+     */
+    async setupRelayEncryption(transfer) {
+        if (!this.wsManager.peerSupports(transfer.peerId, CAPABILITY_E2E)) {
+            console.warn(`[Transfer] Receiver doesn't support relay encryption, relaying ${transfer.id} in the clear`);
+            return;
+        }
+
+        const pair = await relayCrypto.generateKeyPair();
+        if (!pair) {
+            console.warn(`[Transfer] Relay encryption unavailable, relaying ${transfer.id} in the clear`);
+            return;
+        }
+
+        const reply = new Promise(resolve => {
+            const timer = setTimeout(() => resolve(null), this.RELAY_KEY_TIMEOUT);
+            transfer.keyWaiter = (key) => {
+                clearTimeout(timer);
+                resolve(key);
+            };
+        });
+        this.wsManager.sendRelayKey(transfer.peerId, transfer.id, pair.publicKey);
+
+        const peerKey = await reply;
+        transfer.keyWaiter = null;
+        if (!peerKey) {
+            console.warn(`[Transfer] Receiver can't decrypt, relaying ${transfer.id} in the clear`);
+            return;
+        }
+        transfer.relayKey = await relayCrypto.deriveKey(pair.keyPair.privateKey, peerKey, transfer.id);
+        transfer.relayFingerprint = await relayCrypto.fingerprint(pair.publicKey, peerKey);
+    }
+
+    /**
+     * Tell the UI whether a relayed transfer is end-to-end encrypted, with
+     * the key fingerprint to compare with the other device when it is
+     */
+    reportRelaySecurity(transfer, direction) {
+        transfer.relaySecurityReported = true;
+        this.dispatchEvent(new CustomEvent(`${direction}-relay-security`, {
+            detail: {
+                transferId: transfer.id,
+                encrypted: Boolean(transfer.relayKey),
+                fingerprint: transfer.relayFingerprint || null
+            }
+        }));
+    }
+
+    /**
+     * Handle a relay key: the receiver's reply to our key, or the sender's
+     * key for a transfer we are receiving
+     */
+    async handleRelayKey(peerId, payload) {
+        const outgoing = this.outgoingTransfers.get(payload.transferId);
+        if (outgoing && outgoing.peerId === peerId) {
+            if (outgoing.keyWaiter) outgoing.keyWaiter(payload.publicKey || null);
+            return;
+        }
+
+        const incoming = this.incomingTransfers.get(payload.transferId);
+        if (!incoming || incoming.peerId !== peerId || !payload.publicKey) return;
+
+        const pair = await relayCrypto.generateKeyPair();
+        if (pair) {
+            try {
+                incoming.relayKey = await relayCrypto.deriveKey(pair.keyPair.privateKey, payload.publicKey, incoming.id);
+                incoming.relayFingerprint = await relayCrypto.fingerprint(payload.publicKey, pair.publicKey);
+            } catch (err) {
+                console.warn('[Transfer] Bad relay key from sender:', err.message);
+            }
+        }
+        this.wsManager.sendRelayKey(peerId, incoming.id, incoming.relayKey ? pair.publicKey : null);
+        if (incoming.relayKey) this.reportRelaySecurity(incoming, 'receive');
+    }
+
+    /**
+     * Send a single relay chunk with its CRC, returning its size in bytes.
+     * The CRC covers the bytes on the wire, ciphertext when encrypted.
+     */
+    async sendRelayChunkAt(transfer, fileIndex, chunkIndex, isLast) {
+        const file = transfer.files[fileIndex];
+        const offset = chunkIndex * this.CHUNK_SIZE;
+        const arrayBuffer = await file.slice(offset, offset + this.CHUNK_SIZE).arrayBuffer();
+
+        let data = arrayBuffer;
+        if (transfer.relayKey) {
+            data = await relayCrypto.encrypt(transfer.relayKey, fileIndex, chunkIndex, arrayBuffer);
+        }
+
+        const chunk = {
+            transferId: transfer.id,
+            fileIndex,
+            chunkIndex,
+            totalChunks: Math.ceil(file.size / this.CHUNK_SIZE),
+            crc: this.crc32(data),
+            isLast,
+            encrypted: Boolean(transfer.relayKey)
+        };
+
+        if (this.wsManager.canSendBinaryRelay()) {
+            this.wsManager.sendRelayChunkBinary(transfer.peerId, chunk, data);
+        } else {
+            chunk.data = this.arrayBufferToBase64(data);
+            this.wsManager.sendRelayChunk(transfer.peerId, chunk);
+        }
+
+        return arrayBuffer.byteLength;
+    }
+
+    /**
+     * Resend relay chunks the receiver reported missing or corrupted
+     */
+    async handleRelayNack(peerId, payload) {
+        const transfer = this.outgoingTransfers.get(payload.transferId);
+        if (!transfer || transfer.peerId !== peerId || !transfer.useRelay) return;
+
+        // Flatten ranges, clamped to each file's real chunk count
+        const resend = [];
+        for (const range of payload.missing || []) {
+            const file = transfer.files[range.fileIndex];
+            if (!file) continue;
+            const totalChunks = Math.ceil(file.size / this.CHUNK_SIZE);
+            for (let i = Math.max(0, range.start); i <= Math.min(range.end, totalChunks - 1); i++) {
+                resend.push([range.fileIndex, i]);
+            }
+        }
+
+        console.log(`[Transfer] Resending ${resend.length} relay chunk(s) for ${transfer.id}`);
+
+        for (let i = 0; i < resend.length; i++) {
+            const [fileIndex, chunkIndex] = resend[i];
+            await this.sendRelayChunkAt(transfer, fileIndex, chunkIndex, i === resend.length - 1);
+            await this.sleep(10);
+        }
     }
 
     /**
@@ -318,18 +605,66 @@ class FileTransferManager extends EventTarget {
                 transferId: payload.transferId,
                 peerId,
                 files: payload.files,
-                totalSize: payload.totalSize
+                totalSize: payload.totalSize,
+                summary: payload.summary,
+                previews: payload.previews || []
             }
         }));
     }
 
+    /**
+     * Thumbnails of the first few images, so the receiver can see what is
+     * offered before accepting. Re-encoding also drops photo metadata.
+     */
+    async createPreviews(files) {
+        const previews = [];
+        for (let i = 0; i < files.length && previews.length < this.PREVIEW_MAX_COUNT; i++) {
+            const file = files[i];
+            if (!file.type?.startsWith('image/') || file.size > this.PREVIEW_SOURCE_MAX) continue;
+            try {
+                const data = await this.createPreview(file);
+                if (data) previews.push({ fileIndex: i, type: 'image/jpeg', data });
+            } catch (err) {
+                // Formats the browser can't decode just go without
+                console.warn('[Transfer] No preview for', file.name, err);
+            }
+        }
+        return previews;
+    }
+
+    /**
+     * Downscale an image to a base64 JPEG, or null if it comes out too large
+     */
+    async createPreview(file) {
+        const bitmap = await createImageBitmap(file);
+        const scale = Math.min(1, this.PREVIEW_DIMENSION / Math.max(bitmap.width, bitmap.height));
+        const canvas = document.createElement('canvas');
+        canvas.width = Math.max(1, Math.round(bitmap.width * scale));
+        canvas.height = Math.max(1, Math.round(bitmap.height * scale));
+        canvas.getContext('2d').drawImage(bitmap, 0, 0, canvas.width, canvas.height);
+        bitmap.close();
+
+        const blob = await new Promise(resolve => canvas.toBlob(resolve, 'image/jpeg', 0.7));
+        if (!blob || blob.size > this.PREVIEW_MAX_BYTES) return null;
+        return this.arrayBufferToBase64(await blob.arrayBuffer());
+    }
+
     /**
      * Accept an incoming transfer
      */
-    acceptTransfer(transferId) {
+    async acceptTransfer(transferId) {
         const transfer = this.incomingTransfers.get(transferId);
         if (!transfer) return;
 
+        // Folder transfers can recreate their tree in a directory the user picks
+        if (transfer.files.some(f => f.path) && window.showDirectoryPicker) {
+            try {
+                transfer.directoryHandle = await window.showDirectoryPicker({ mode: 'readwrite' });
+            } catch (err) {
+                console.log('[Transfer] No directory picked, saving as downloads:', err.message);
+            }
+        }
+
         transfer.status = 'accepted';
         this.wsManager.sendTransferResponse(transfer.peerId, transferId, true);
 
@@ -434,6 +769,7 @@ class FileTransferManager extends EventTarget {
     handleChunk(peerId, fileIndex, chunkIndex, chunkData) {
         const transfer = this.findTransferByPeer(peerId);
         if (!transfer) return;
+        if (!this.withinDeclaredSize(transfer, fileIndex, chunkData.byteLength)) return;
 
         transfer.currentFileData.push(chunkData);
         transfer.bytesReceived += chunkData.byteLength;
@@ -456,24 +792,61 @@ class FileTransferManager extends EventTarget {
     }
 
     /**
-     * Handle relay chunk (WebSocket fallback)
+     * Handle relay chunk (WebSocket fallback). Chunks are processed one at
+     * a time so decryption can't reorder them.
      */
     handleRelayChunk(peerId, payload) {
-        let transfer = this.incomingTransfers.get(payload.transferId);
-        if (!transfer) return;
+        const transfer = this.incomingTransfers.get(payload.transferId);
+        if (!transfer || transfer.peerId !== peerId) return;
+
+        transfer.relayChain = (transfer.relayChain || Promise.resolve())
+            .then(() => this.processRelayChunk(transfer, peerId, payload))
+            .catch(err => console.error('[Transfer] Relay chunk failed:', err));
+    }
+
+    /**
+     * Store one relay chunk, decrypting it first when encrypted
+     */
+    async processRelayChunk(transfer, peerId, payload) {
+        if (!this.incomingTransfers.has(transfer.id)) return;
 
-        const chunkData = this.base64ToArrayBuffer(payload.data);
+        // Without a key exchange first, the transfer is in the clear
+        if (!transfer.relaySecurityReported) this.reportRelaySecurity(transfer, 'receive');
 
-        // Initialize file data array if needed
+        // Binary frames carry raw bytes, JSON chunks carry base64
+        const chunkData = payload.bytes || this.base64ToArrayBuffer(payload.data);
+
+        // Initialize relay state if needed
         if (!transfer.fileChunks) {
             transfer.fileChunks = [];
+            transfer.expectedChunks = [];
+            transfer.nackRounds = 0;
         }
         if (!transfer.fileChunks[payload.fileIndex]) {
             transfer.fileChunks[payload.fileIndex] = [];
         }
+        if (payload.totalChunks !== undefined) {
+            transfer.expectedChunks[payload.fileIndex] = payload.totalChunks;
+        }
 
-        transfer.fileChunks[payload.fileIndex][payload.chunkIndex] = chunkData;
-        transfer.bytesReceived += chunkData.byteLength;
+        // Corrupted chunks are dropped and re-requested as gaps
+        if (payload.crc !== undefined && this.crc32(chunkData) !== payload.crc) {
+            console.warn(`[Transfer] CRC mismatch on file ${payload.fileIndex} chunk ${payload.chunkIndex}`);
+        } else if (!transfer.fileChunks[payload.fileIndex][payload.chunkIndex]) {
+            // Chunks that fail to decrypt are dropped and re-requested too
+            const plaintext = await this.openRelayChunk(transfer, payload, chunkData);
+            if (plaintext && !this.withinDeclaredSize(transfer, payload.fileIndex, plaintext.byteLength)) return;
+            if (plaintext) {
+                transfer.fileChunks[payload.fileIndex][payload.chunkIndex] = plaintext;
+                transfer.bytesReceived += plaintext.byteLength;
+                transfer.relayReceived = (transfer.relayReceived || 0) + 1;
+
+                // Let the sender move its window forward
+                if (transfer.relayReceived % this.RELAY_ACK_EVERY === 0 || payload.isLast) {
+                    this.wsManager.sendRelayAck(peerId, transfer.id, transfer.relayReceived);
+                }
+            }
+        }
         transfer.status = 'transferring';
 
         // Emit progress
@@ -486,10 +859,132 @@ class FileTransferManager extends EventTarget {
             }
         }));
 
-        // If this is the last chunk, save all files
-        if (payload.isLast) {
+        // Check for gaps at the end of the stream or of a retransmit batch
+        if (payload.isLast || transfer.nackRounds > 0) {
+            this.checkRelayComplete(transfer, payload.isLast);
+        }
+    }
+
+    /**
+     * Return a relay chunk's plaintext, or null if it must be dropped. Once
+     * a key is agreed, chunks sent in the clear are refused too.
+     */
+    async openRelayChunk(transfer, payload, data) {
+        if (!payload.encrypted) {
+            if (!transfer.relayKey) return data;
+            console.warn(`[Transfer] Dropping unencrypted chunk for encrypted transfer ${transfer.id}`);
+            return null;
+        }
+        if (!transfer.relayKey) {
+            console.warn(`[Transfer] Dropping encrypted chunk, no key for ${transfer.id}`);
+            return null;
+        }
+
+        try {
+            return await relayCrypto.decrypt(transfer.relayKey, payload.fileIndex, payload.chunkIndex, data);
+        } catch (err) {
+            console.warn(`[Transfer] Failed to decrypt file ${payload.fileIndex} chunk ${payload.chunkIndex}`);
+            return null;
+        }
+    }
+
+    /**
+     * Save relayed files once every chunk is present, otherwise
+     * re-request the gaps when the sender has finished a batch
+     */
+    checkRelayComplete(transfer, endOfBatch) {
+        const missing = this.findMissingChunks(transfer);
+        if (missing.length === 0) {
+            clearTimeout(transfer.nackTimer);
             this.saveAllRelayedFiles(transfer);
+            return;
         }
+
+        if (endOfBatch) {
+            this.requestMissingChunks(transfer, missing);
+        }
+    }
+
+    /**
+     * List missing chunks as inclusive ranges per file
+     */
+    findMissingChunks(transfer) {
+        const ranges = [];
+
+        for (let fileIndex = 0; fileIndex < transfer.files.length; fileIndex++) {
+            const expected = transfer.expectedChunks[fileIndex] ??
+                Math.ceil(transfer.files[fileIndex].size / this.CHUNK_SIZE);
+            const chunks = transfer.fileChunks[fileIndex] || [];
+
+            let start = -1;
+            for (let i = 0; i <= expected; i++) {
+                const present = i === expected || chunks[i];
+                if (!present && start < 0) {
+                    start = i;
+                } else if (present && start >= 0) {
+                    ranges.push({ fileIndex, start, end: i - 1 });
+                    start = -1;
+                }
+            }
+        }
+
+        return ranges;
+    }
+
+    /**
+     * Ask the sender to resend missing chunks, failing the transfer
+     * when gaps persist after MAX_NACK_ROUNDS attempts
+     */
+    requestMissingChunks(transfer, missing) {
+        clearTimeout(transfer.nackTimer);
+
+        if (transfer.nackRounds >= this.MAX_NACK_ROUNDS) {
+            this.failTransfer(transfer, 'Relay transfer incomplete: chunks still missing');
+            return;
+        }
+
+        transfer.nackRounds++;
+        console.log(`[Transfer] Requesting ${missing.length} missing range(s), round ${transfer.nackRounds}`);
+        this.wsManager.sendRelayNack(transfer.peerId, transfer.id, missing);
+
+        // Ask again if the retransmit never arrives
+        transfer.nackTimer = setTimeout(() => {
+            if (!this.incomingTransfers.has(transfer.id)) return;
+            const stillMissing = this.findMissingChunks(transfer);
+            if (stillMissing.length > 0) {
+                this.requestMissingChunks(transfer, stillMissing);
+            }
+        }, this.NACK_TIMEOUT);
+    }
+
+    /**
+     * Count bytes received for a file and give up on the transfer once the
+     * sender goes past the size it declared, which is what the user
+     * accepted and what a small device may have room for
+     */
+    withinDeclaredSize(transfer, fileIndex, bytes) {
+        transfer.fileBytes = transfer.fileBytes || [];
+        transfer.fileBytes[fileIndex] = (transfer.fileBytes[fileIndex] || 0) + bytes;
+
+        const declared = transfer.files[fileIndex]?.size;
+        if (declared !== undefined && transfer.fileBytes[fileIndex] <= declared) return true;
+
+        console.warn(`[Transfer] File ${fileIndex} of ${transfer.id} exceeds its declared size`);
+        this.failTransfer(transfer, 'The sender sent more data than it declared');
+        return false;
+    }
+
+    /**
+     * Abandon an incoming transfer
+     */
+    failTransfer(transfer, reason) {
+        clearTimeout(transfer.nackTimer);
+        transfer.status = 'failed';
+        this.incomingTransfers.delete(transfer.id);
+
+        this.dispatchEvent(new CustomEvent('receive-failed', {
+            detail: { transferId: transfer.id, reason }
+        }));
     }
 
     /**
@@ -500,7 +995,7 @@ class FileTransferManager extends EventTarget {
             const fileInfo = transfer.files[i];
             const chunks = transfer.fileChunks[i] || [];
             const blob = new Blob(chunks, { type: fileInfo.type });
-            this.downloadBlob(blob, fileInfo.name);
+            this.saveFile(transfer, fileInfo, blob);
         }
 
         transfer.status = 'completed';
@@ -523,8 +1018,8 @@ class FileTransferManager extends EventTarget {
             blob
         });
 
-        // Download the file
-        this.downloadBlob(blob, metadata.name);
+        // Save the file, keeping its folder path when there is one
+        this.saveFile(transfer, transfer.files[metadata.fileIndex] || metadata, blob);
 
         // Reset for next file
         transfer.currentFileData = [];
@@ -558,6 +1053,45 @@ class FileTransferManager extends EventTarget {
         return null;
     }
 
+    /**
+     * Save a received file. Files from a folder transfer are written into
+     * the picked directory, recreating subfolders; otherwise they are
+     * downloaded with the folder path flattened into the name.
+     */
+    async saveFile(transfer, fileInfo, blob) {
+        const parts = this.sanitizePath(fileInfo.path);
+
+        if (parts.length > 0 && transfer.directoryHandle) {
+            try {
+                let dir = transfer.directoryHandle;
+                for (const part of parts.slice(0, -1)) {
+                    dir = await dir.getDirectoryHandle(part, { create: true });
+                }
+                const handle = await dir.getFileHandle(parts[parts.length - 1], { create: true });
+                const writable = await handle.createWritable();
+                await writable.write(blob);
+                await writable.close();
+                return;
+            } catch (err) {
+                console.error('[Transfer] Failed to write into folder, downloading instead:', err);
+            }
+        }
+
+        const name = parts.length > 0 ? parts.join('_') : fileInfo.name;
+        this.downloadBlob(blob, name);
+    }
+
+    /**
+     * Split a relative path into safe components, dropping anything that
+     * could escape the destination folder
+     */
+    sanitizePath(path) {
+        if (!path) return [];
+        return path.replace(/\\/g, '/')
+            .split('/')
+            .filter(part => part && part !== '.' && part !== '..' && !/[\x00-\x1f:]/.test(part));
+    }
+
     /**
      * Download a blob as a file
      */
@@ -621,6 +1155,29 @@ class FileTransferManager extends EventTarget {
         return buffer;
     }
 
+    /**
+     * CRC-32 (IEEE) of an ArrayBuffer, matching Go's hash/crc32
+     */
+    crc32(buffer) {
+        if (!this.crcTable) {
+            this.crcTable = new Uint32Array(256);
+            for (let n = 0; n < 256; n++) {
+                let c = n;
+                for (let k = 0; k < 8; k++) {
+                    c = c & 1 ? 0xEDB88320 ^ (c >>> 1) : c >>> 1;
+                }
+                this.crcTable[n] = c >>> 0;
+            }
+        }
+
+        const bytes = new Uint8Array(buffer);
+        let crc = 0xFFFFFFFF;
+        for (let i = 0; i < bytes.length; i++) {
+            crc = this.crcTable[(crc ^ bytes[i]) & 0xFF] ^ (crc >>> 8);
+        }
+        return (crc ^ 0xFFFFFFFF) >>> 0;
+    }
+
     /**
      * Convert ArrayBuffer to Base64
      */
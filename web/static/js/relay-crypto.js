@@ -0,0 +1,88 @@
+/**
+ * End-to-end encryption for relayed transfers
+ * Peers exchange ephemeral X25519 keys over signaling and encrypt relay
+ * chunks with AES-GCM, so the hub only ever forwards ciphertext
+ */
+
+const RELAY_KEY_INFO = new TextEncoder().encode('peerdrop relay chunk');
+
+const relayCrypto = {
+    /**
+     * Generate an ephemeral key pair, or return null when this browser
+     * can't (WebCrypto needs HTTPS or localhost, and X25519 support)
+     */
+    async generateKeyPair() {
+        if (!window.crypto?.subtle) return null;
+        try {
+            const keyPair = await crypto.subtle.generateKey({ name: 'X25519' }, false, ['deriveBits']);
+            const raw = await crypto.subtle.exportKey('raw', keyPair.publicKey);
+            return { keyPair, publicKey: this.toBase64(raw) };
+        } catch (err) {
+            console.warn('[Crypto] X25519 unavailable:', err.message);
+            return null;
+        }
+    },
+
+    /**
+     * Derive the AES-GCM key for a transfer from our private key and the
+     * peer's public key. The transfer ID salts the derivation.
+     */
+    async deriveKey(privateKey, peerPublicKey, transferId) {
+        const publicKey = await crypto.subtle.importKey(
+            'raw', this.fromBase64(peerPublicKey), { name: 'X25519' }, false, []);
+        const secret = await crypto.subtle.deriveBits({ name: 'X25519', public: publicKey }, privateKey, 256);
+        const hkdfKey = await crypto.subtle.importKey('raw', secret, 'HKDF', false, ['deriveKey']);
+
+        return crypto.subtle.deriveKey(
+            { name: 'HKDF', hash: 'SHA-256', salt: new TextEncoder().encode(transferId), info: RELAY_KEY_INFO },
+            hkdfKey,
+            { name: 'AES-GCM', length: 256 },
+            false,
+            ['encrypt', 'decrypt']
+        );
+    },
+
+    /**
+     * IV for a chunk. Keys are per transfer and each chunk position is
+     * encrypted from the same bytes every time, so a resend never reuses
+     * an IV with different plaintext.
+     */
+    chunkIV(fileIndex, chunkIndex) {
+        const iv = new Uint8Array(12);
+        const view = new DataView(iv.buffer);
+        view.setUint32(0, fileIndex);
+        view.setUint32(4, chunkIndex);
+        return iv;
+    },
+
+    encrypt(key, fileIndex, chunkIndex, data) {
+        return crypto.subtle.encrypt({ name: 'AES-GCM', iv: this.chunkIV(fileIndex, chunkIndex) }, key, data);
+    },
+
+    /**
+     * Decrypt a chunk; rejects if it was tampered with or misplaced
+     */
+    decrypt(key, fileIndex, chunkIndex, data) {
+        return crypto.subtle.decrypt({ name: 'AES-GCM', iv: this.chunkIV(fileIndex, chunkIndex) }, key, data);
+    },
+
+    /**
+     * Short fingerprint of a transfer's two public keys, the same on both
+     * devices. Keys travel through the hub, so a hub that swapped them
+     * would leave each side with a different fingerprint.
+     */
+    async fingerprint(keyA, keyB) {
+        const keys = new TextEncoder().encode([keyA, keyB].sort().join(':'));
+        const digest = new Uint8Array(await crypto.subtle.digest('SHA-256', keys));
+        const hex = Array.from(digest.slice(0, 8), b => b.toString(16).padStart(2, '0')).join('');
+        return hex.match(/.{4}/g).join(' ');
+    },
+
+    toBase64(buffer) {
+        return btoa(String.fromCharCode(...new Uint8Array(buffer)));
+    },
+
+    fromBase64(str) {
+        return Uint8Array.from(atob(str), c => c.charCodeAt(0));
+    }
+};
@@ -10,11 +10,13 @@ class WebRTCManager extends EventTarget {
         this.dataChannels = new Map(); // peerId -> RTCDataChannel
         this.pendingCandidates = new Map(); // peerId -> ICE candidates waiting for connection
 
+        this.defaultIceServers = [
+            { urls: 'stun:stun.l.google.com:19302' },
+            { urls: 'stun:stun1.l.google.com:19302' }
+        ];
+
         this.rtcConfig = {
-            iceServers: [
-                { urls: 'stun:stun.l.google.com:19302' },
-                { urls: 'stun:stun1.l.google.com:19302' }
-            ]
+            iceServers: this.defaultIceServers
         };
 
         this.setupSignalingListeners();
@@ -35,6 +37,21 @@ class WebRTCManager extends EventTarget {
         this.wsManager.addEventListener('ice-candidate', (e) => {
             this.handleIceCandidate(e.detail.peerId, e.detail.payload);
         });
+
+        this.wsManager.addEventListener('ice-servers', (e) => {
+            this.setIceServers(e.detail.payload.iceServers || []);
+        });
+    }
+
+    /**
+     * Use servers advertised by the hub ahead of the public STUN defaults,
+     * so a local TURN relay is tried before falling back to relay chunks
+     */
+    setIceServers(servers) {
+        this.rtcConfig = {
+            iceServers: [...servers, ...this.defaultIceServers]
+        };
+        console.log(`[WebRTC] Using ${servers.length} ICE server(s) from hub`);
     }
 
     /**
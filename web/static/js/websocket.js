@@ -2,86 +2,239 @@
  * WebSocket connection manager for Peer-Drop
  * Handles connection, reconnection, and message routing
  */
+
+// Signaling protocol version spoken by this client
+const PROTOCOL_VERSION = 1;
+
+// Close code used by the hub when our protocol version is too old
+const CLOSE_UNSUPPORTED_PROTOCOL = 4001;
+const CLOSE_KICKED = 4002;
+
+// Optional protocol features this client supports. binary-relay is for
+// the hub; the others tell peers what they can rely on.
+const CAPABILITY_BINARY_RELAY = 'binary-relay';
+const CAPABILITY_RESUME = 'resume';
+const CAPABILITY_E2E = 'e2e';
+const CLIENT_CAPABILITIES = [CAPABILITY_BINARY_RELAY, CAPABILITY_RESUME]
+    .concat(window.crypto?.subtle ? [CAPABILITY_E2E] : []);
+
+// Announced by servers that keep rooms open after everyone leaves
+const CAPABILITY_PERSISTENT_ROOMS = 'persistent-rooms';
+
+// Binary relay chunk frame (see internal/signaling/binary.go)
+const FRAME_RELAY_CHUNK = 0x01;
+const FLAG_LAST_CHUNK = 0x01;
+const FLAG_ENCRYPTED = 0x02;
+
 class WebSocketManager extends EventTarget {
     constructor() {
         super();
         this.ws = null;
         this.url = null;
+        this.transport = 'websocket'; // or 'http' once WebSocket has failed
+        this.wsWorked = false;
+        this.stream = null; // EventSource for the HTTP fallback
+        this.session = null;
+        this.postQueue = Promise.resolve();
         this.reconnectAttempts = 0;
         this.maxReconnectAttempts = 10;
         this.reconnectDelay = 1000;
         this.messageQueue = [];
         this.isConnected = false;
         this.pingInterval = null;
+        this.serverCapabilities = []; // filled in from /api/version
+        this.peerFeatures = new Map(); // peer ID -> { protocolVersion, capabilities }
     }
 
     /**
-     * Connect to WebSocket server
+     * Connect to the server, over WebSocket unless it has been found not to
+     * work on this network
      */
     connect() {
+        if (this.transport === 'http') {
+            this.connectHttp();
+            return;
+        }
+
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
         this.url = `${protocol}//${window.location.host}/ws`;
 
-        this.ws = new WebSocket(this.url);
+        const ws = new WebSocket(this.url);
+        ws.binaryType = 'arraybuffer';
+        this.ws = ws;
+        let opened = false;
 
-        this.ws.onopen = () => {
+        ws.onopen = () => {
             console.log('[WS] Connected');
-            this.isConnected = true;
-            this.reconnectAttempts = 0;
-            this.reconnectDelay = 1000;
-
-            // Send queued messages
-            while (this.messageQueue.length > 0) {
-                const msg = this.messageQueue.shift();
-                this.ws.send(JSON.stringify(msg));
-            }
-
-            // Start ping interval
-            this.startPing();
-
-            this.dispatchEvent(new CustomEvent('open'));
+            opened = true;
+            this.wsWorked = true;
+            this.handleOpen();
         };
 
-        this.ws.onclose = (event) => {
-            console.log('[WS] Disconnected', event.code, event.reason);
-            this.isConnected = false;
-            this.stopPing();
-
-            this.dispatchEvent(new CustomEvent('close', { detail: { code: event.code } }));
-
-            // Attempt reconnection
-            if (this.reconnectAttempts < this.maxReconnectAttempts) {
-                setTimeout(() => {
-                    this.reconnectAttempts++;
-                    console.log(`[WS] Reconnecting... (attempt ${this.reconnectAttempts})`);
-                    this.connect();
-                }, this.reconnectDelay);
-
-                // Exponential backoff
-                this.reconnectDelay = Math.min(this.reconnectDelay * 1.5, 30000);
+        ws.onclose = (event) => {
+            // Some proxies refuse the upgrade; if WebSocket never worked,
+            // switch to the HTTP fallback instead of retrying
+            if (!opened && !this.wsWorked) {
+                console.log('[WS] WebSocket unavailable, using HTTP fallback');
+                this.transport = 'http';
+                this.connectHttp();
+                return;
             }
+            this.handleClose(event.code, event.reason);
         };
 
-        this.ws.onerror = (error) => {
+        ws.onerror = (error) => {
             console.error('[WS] Error:', error);
             this.dispatchEvent(new CustomEvent('error', { detail: error }));
         };
 
-        this.ws.onmessage = (event) => {
+        ws.onmessage = (event) => {
+            this.handleMessage(event.data);
+        };
+    }
+
+    /**
+     * Connect over the HTTP fallback: an event stream for incoming messages
+     * and POSTs for outgoing ones
+     */
+    connectHttp() {
+        const stream = new EventSource('/signal/stream');
+        this.stream = stream;
+        let closed = false;
+
+        const finish = (code, reason) => {
+            if (closed) return;
+            closed = true;
+            stream.close();
+            this.session = null;
+            this.handleClose(code, reason);
+        };
+
+        stream.addEventListener('session', (event) => {
+            console.log('[WS] Connected (HTTP fallback)');
+            this.session = JSON.parse(event.data).session;
+            this.handleOpen();
+        });
+
+        stream.addEventListener('close', (event) => {
+            const { code, reason } = JSON.parse(event.data);
+            finish(code, reason || '');
+        });
+
+        stream.onmessage = (event) => {
             this.handleMessage(event.data);
         };
+
+        // EventSource would reconnect on its own, but a new stream is a new
+        // session, so go through the normal reconnect and rejoin instead
+        stream.onerror = () => finish(1006, '');
+    }
+
+    /**
+     * Connection established on either transport
+     */
+    handleOpen() {
+        this.isConnected = true;
+        this.reconnectAttempts = 0;
+        this.reconnectDelay = 1000;
+
+        // Send queued messages
+        while (this.messageQueue.length > 0) {
+            this.sendRaw(this.messageQueue.shift());
+        }
+
+        // Start ping interval
+        this.startPing();
+
+        this.dispatchEvent(new CustomEvent('open'));
+    }
+
+    /**
+     * Connection lost on either transport
+     */
+    handleClose(code, reason) {
+        console.log('[WS] Disconnected', code, reason);
+        this.isConnected = false;
+        this.stopPing();
+
+        this.dispatchEvent(new CustomEvent('close', { detail: { code } }));
+
+        // Reconnecting won't help if the hub rejected our protocol version
+        if (code === CLOSE_UNSUPPORTED_PROTOCOL) {
+            this.dispatchEvent(new CustomEvent('protocol-rejected', {
+                detail: { reason }
+            }));
+            return;
+        }
+
+        // Nor if an admin disconnected us on purpose
+        if (code === CLOSE_KICKED) {
+            this.dispatchEvent(new CustomEvent('kicked', { detail: { reason } }));
+            return;
+        }
+
+        // Attempt reconnection
+        if (this.reconnectAttempts < this.maxReconnectAttempts) {
+            setTimeout(() => {
+                this.reconnectAttempts++;
+                console.log(`[WS] Reconnecting... (attempt ${this.reconnectAttempts})`);
+                this.connect();
+            }, this.reconnectDelay);
+
+            // Exponential backoff
+            this.reconnectDelay = Math.min(this.reconnectDelay * 1.5, 30000);
+        }
+    }
+
+    /**
+     * Whether messages can be sent right now
+     */
+    isOpen() {
+        if (!this.isConnected) return false;
+        if (this.transport === 'http') return this.session !== null;
+        return this.ws.readyState === WebSocket.OPEN;
+    }
+
+    /**
+     * Send a message over the current transport. POSTs are chained so
+     * messages reach the server in order.
+     */
+    sendRaw(msg) {
+        if (this.transport !== 'http') {
+            this.ws.send(JSON.stringify(msg));
+            return;
+        }
+
+        const url = `/signal/send?session=${encodeURIComponent(this.session)}`;
+        const body = JSON.stringify(msg);
+        this.postQueue = this.postQueue
+            .then(() => fetch(url, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body
+            }))
+            .then((res) => {
+                if (!res.ok) console.warn('[WS] Send rejected:', res.status);
+            })
+            .catch((err) => console.error('[WS] Send failed:', err));
     }
 
     /**
      * Handle incoming message
      */
     handleMessage(data) {
+        if (data instanceof ArrayBuffer) {
+            this.handleBinaryMessage(data);
+            return;
+        }
+
         try {
             // Handle multiple messages (newline separated)
             const messages = data.split('\n').filter(m => m.trim());
 
             for (const msgStr of messages) {
                 const msg = JSON.parse(msgStr);
+                this.trackPeerFeatures(msg);
 
                 // Dispatch event based on message type
                 this.dispatchEvent(new CustomEvent(msg.type, {
@@ -91,12 +244,71 @@ class WebSocketManager extends EventTarget {
                         payload: msg.payload
                     }
                 }));
+
+                // Custom messages are also dispatched as "custom:<namespace>"
+                if (msg.type === 'custom' && msg.payload?.namespace) {
+                    this.dispatchEvent(new CustomEvent(`custom:${msg.payload.namespace}`, {
+                        detail: { peerId: msg.peerId, targetId: msg.targetId, data: msg.payload.data }
+                    }));
+                }
             }
         } catch (err) {
             console.error('[WS] Failed to parse message:', err, data);
         }
     }
 
+    /**
+     * Remember what each peer announced it supports, from the peer lists
+     */
+    trackPeerFeatures(msg) {
+        const remember = (peer) => {
+            this.peerFeatures.set(peer.id, {
+                protocolVersion: peer.protocolVersion || 0,
+                capabilities: peer.capabilities || null
+            });
+        };
+        switch (msg.type) {
+            case 'peers':
+                this.peerFeatures.clear();
+                (msg.payload?.peers || []).forEach(remember);
+                break;
+            case 'room-joined':
+                (msg.payload?.peers || []).forEach(remember);
+                break;
+            case 'peer-joined':
+                if (msg.payload?.peer) remember(msg.payload.peer);
+                break;
+            case 'peer-left':
+                this.peerFeatures.delete(msg.payload?.peerId);
+                break;
+        }
+    }
+
+    /**
+     * Whether a peer may support a capability. Peers from before
+     * capabilities were passed on announce none, and are given the
+     * benefit of the doubt so the old fallbacks still apply.
+     */
+    peerSupports(peerId, capability) {
+        const capabilities = this.peerFeatures.get(peerId)?.capabilities;
+        return !capabilities || capabilities.includes(capability);
+    }
+
+    /**
+     * Handle a binary relay chunk frame
+     */
+    handleBinaryMessage(buffer) {
+        const frame = this.decodeRelayFrame(buffer);
+        if (!frame) {
+            console.warn('[WS] Ignoring malformed binary frame');
+            return;
+        }
+
+        this.dispatchEvent(new CustomEvent('relay-chunk', {
+            detail: { peerId: frame.peerId, payload: frame.chunk }
+        }));
+    }
+
     /**
      * Send a message to the server
      */
@@ -105,8 +317,8 @@ class WebSocketManager extends EventTarget {
         if (payload) msg.payload = payload;
         if (targetId) msg.targetId = targetId;
 
-        if (this.isConnected && this.ws.readyState === WebSocket.OPEN) {
-            this.ws.send(JSON.stringify(msg));
+        if (this.isOpen()) {
+            this.sendRaw(msg);
         } else {
             // Queue message for later
             this.messageQueue.push(msg);
@@ -117,21 +329,31 @@ class WebSocketManager extends EventTarget {
      * Join with device info
      */
     join(name, platform) {
-        this.send('join', { name, platform });
+        this.send('join', {
+            name,
+            platform,
+            locale: navigator.language,
+            protocolVersion: PROTOCOL_VERSION,
+            capabilities: CLIENT_CAPABILITIES
+        });
     }
 
     /**
-     * Create a public room
+     * Create a public room, optionally under a chosen alias. Persistent
+     * rooms outlive their occupants and server restarts.
      */
-    createRoom() {
-        this.send('create-room');
+    createRoom(alias = '', persistent = false) {
+        const payload = {};
+        if (alias) payload.alias = alias;
+        if (persistent) payload.persistent = true;
+        this.send('create-room', Object.keys(payload).length ? payload : null);
     }
 
     /**
-     * Join a public room by code
+     * Join a public room by code or word alias
      */
     joinRoom(code) {
-        this.send('join-room', { code: code.toUpperCase() });
+        this.send('join-room', { code });
     }
 
     /**
@@ -163,13 +385,14 @@ class WebSocketManager extends EventTarget {
     }
 
     /**
-     * Send transfer request to peer
+     * Send transfer request to peer, with optional image previews
      */
-    sendTransferRequest(targetId, transferId, files) {
+    sendTransferRequest(targetId, transferId, files, previews = []) {
         const fileInfos = files.map(f => ({
             name: f.name,
             size: f.size,
-            type: f.type || 'application/octet-stream'
+            type: f.type || 'application/octet-stream',
+            path: getRelativePath(f) || undefined
         }));
 
         const totalSize = files.reduce((sum, f) => sum + f.size, 0);
@@ -177,7 +400,9 @@ class WebSocketManager extends EventTarget {
         this.send('transfer-request', {
             transferId,
             files: fileInfos,
-            totalSize
+            totalSize,
+            summary: fileInfos.length > 1 ? summarizeFiles(fileInfos) : undefined,
+            previews: previews.length ? previews : undefined
         }, targetId);
     }
 
@@ -188,17 +413,169 @@ class WebSocketManager extends EventTarget {
         this.send('transfer-response', { transferId, accepted }, targetId);
     }
 
+    /**
+     * Tell the sender how many distinct relay chunks have arrived
+     */
+    sendRelayAck(targetId, transferId, received) {
+        this.send('relay-ack', { transferId, received }, targetId);
+    }
+
+    /**
+     * Ask a peer's user to look at a pending transfer
+     */
+    sendNudge(targetId, transferId) {
+        this.send('nudge', { transferId }, targetId);
+    }
+
+    /**
+     * Send a chat message to everyone in our room, or to one peer
+     */
+    sendChat(text, targetId = null) {
+        this.send('chat', { text }, targetId);
+    }
+
+    /**
+     * Pin a note and/or small file ({ name, type, data } with base64
+     * data) to our room's board
+     */
+    postToBoard(text, file = null) {
+        const payload = {};
+        if (text) payload.text = text;
+        if (file) payload.file = file;
+        this.send('board-post', payload);
+    }
+
+    /**
+     * Remove one of our own board posts
+     */
+    deleteBoardPost(id) {
+        this.send('board-delete', { id });
+    }
+
+    /**
+     * Push clipboard text to one peer, or to everyone in our room
+     */
+    sendClipboard(text, targetId = null) {
+        this.send('clipboard', { text }, targetId);
+    }
+
+    /**
+     * Send an application-defined message, relayed by the hub as is, to
+     * one peer or everyone in our room. Namespaces look like
+     * "com.example.polls"; listen for "custom:<namespace>" events.
+     */
+    sendCustom(namespace, data, targetId = null) {
+        this.send('custom', { namespace, data }, targetId);
+    }
+
+    /**
+     * Ask the sender to pause a transfer we are receiving
+     */
+    sendTransferPause(targetId, transferId) {
+        this.send('transfer-pause', { transferId }, targetId);
+    }
+
+    /**
+     * Ask the sender to resume a paused transfer
+     */
+    sendTransferResume(targetId, transferId) {
+        this.send('transfer-resume', { transferId }, targetId);
+    }
+
     /**
      * Send relay chunk (fallback when WebRTC fails)
+     * chunk: { transferId, fileIndex, chunkIndex, totalChunks, data (base64), crc, isLast }
      */
-    sendRelayChunk(targetId, transferId, fileIndex, chunkIndex, data, isLast) {
-        this.send('relay-chunk', {
-            transferId,
-            fileIndex,
-            chunkIndex,
-            data, // base64 encoded
-            isLast
-        }, targetId);
+    sendRelayChunk(targetId, chunk) {
+        this.send('relay-chunk', chunk, targetId);
+    }
+
+    /**
+     * Whether relay chunks can be sent as binary frames right now
+     */
+    canSendBinaryRelay() {
+        return this.serverCapabilities.includes(CAPABILITY_BINARY_RELAY) &&
+            this.transport === 'websocket' && this.isOpen();
+    }
+
+    /**
+     * Send relay chunk as a binary frame, avoiding base64 overhead
+     * chunk: { transferId, fileIndex, chunkIndex, totalChunks, crc, isLast }
+     */
+    sendRelayChunkBinary(targetId, chunk, data) {
+        this.ws.send(this.encodeRelayFrame(targetId, chunk, data));
+    }
+
+    /**
+     * Build a binary relay chunk frame
+     */
+    encodeRelayFrame(peerId, chunk, data) {
+        const transferId = new TextEncoder().encode(chunk.transferId);
+        const headerSize = 19 + transferId.length + 16;
+        const frame = new Uint8Array(headerSize + data.byteLength);
+        const view = new DataView(frame.buffer);
+
+        frame[0] = FRAME_RELAY_CHUNK;
+        frame[1] = (chunk.isLast ? FLAG_LAST_CHUNK : 0) | (chunk.encrypted ? FLAG_ENCRYPTED : 0);
+        for (let i = 0; i < 16; i++) {
+            frame[2 + i] = parseInt(peerId.substr(i * 2, 2), 16);
+        }
+        frame[18] = transferId.length;
+        frame.set(transferId, 19);
+
+        let offset = 19 + transferId.length;
+        view.setUint32(offset, chunk.fileIndex);
+        view.setUint32(offset + 4, chunk.chunkIndex);
+        view.setUint32(offset + 8, chunk.totalChunks);
+        view.setUint32(offset + 12, chunk.crc);
+        frame.set(new Uint8Array(data), headerSize);
+
+        return frame.buffer;
+    }
+
+    /**
+     * Parse a binary relay chunk frame, or return null if malformed
+     */
+    decodeRelayFrame(buffer) {
+        const frame = new Uint8Array(buffer);
+        if (frame.length < 19 || frame[0] !== FRAME_RELAY_CHUNK) return null;
+
+        const idLength = frame[18];
+        const headerSize = 19 + idLength + 16;
+        if (frame.length < headerSize) return null;
+
+        const view = new DataView(buffer);
+        const offset = 19 + idLength;
+        const peerId = Array.from(frame.subarray(2, 18), b => b.toString(16).padStart(2, '0')).join('');
+
+        return {
+            peerId,
+            chunk: {
+                transferId: new TextDecoder().decode(frame.subarray(19, offset)),
+                fileIndex: view.getUint32(offset),
+                chunkIndex: view.getUint32(offset + 4),
+                totalChunks: view.getUint32(offset + 8),
+                crc: view.getUint32(offset + 12),
+                isLast: (frame[1] & FLAG_LAST_CHUNK) !== 0,
+                encrypted: (frame[1] & FLAG_ENCRYPTED) !== 0,
+                bytes: buffer.slice(headerSize)
+            }
+        };
+    }
+
+    /**
+     * Send our ephemeral key for an end-to-end encrypted relay. A null key
+     * tells the peer we cannot decrypt.
+     */
+    sendRelayKey(targetId, transferId, publicKey) {
+        this.send('relay-key', { transferId, publicKey: publicKey || undefined }, targetId);
+    }
+
+    /**
+     * Ask the sender to resend missing relay chunk ranges
+     */
+    sendRelayNack(targetId, transferId, missing) {
+        this.send('relay-nack', { transferId, missing }, targetId);
     }
 
     /**
@@ -232,6 +609,9 @@ class WebSocketManager extends EventTarget {
         if (this.ws) {
             this.ws.close();
         }
+        if (this.stream) {
+            this.stream.close();
+        }
     }
 }
 